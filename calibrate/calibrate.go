@@ -0,0 +1,141 @@
+package calibrate
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// DefaultTargets are the minimum precisions required for the confidence 5, 4,
+// 3 and 2 bands. The confidence 1 band is always sized to reach full recall.
+var DefaultTargets = []float64{0.99, 0.95, 0.90, 0.75}
+
+// Sample is a single reviewed image pair.
+type Sample struct {
+	Distance  float64
+	Duplicate bool
+}
+
+// Point holds the classification statistics for pairs with a distance below
+// Threshold.
+type Point struct {
+	Threshold         float64
+	TP, FP, FN, TN    int
+	Precision         float64
+	Recall            float64
+	FalsePositiveRate float64
+}
+
+// Evaluate computes the classification statistics for a single threshold.
+func Evaluate(samples []Sample, threshold float64) Point {
+	p := Point{Threshold: threshold}
+	for _, s := range samples {
+		switch {
+		case s.Distance < threshold && s.Duplicate:
+			p.TP++
+		case s.Distance < threshold:
+			p.FP++
+		case s.Duplicate:
+			p.FN++
+		default:
+			p.TN++
+		}
+	}
+	p.Precision = ratio(p.TP, p.TP+p.FP)
+	p.Recall = ratio(p.TP, p.TP+p.FN)
+	p.FalsePositiveRate = ratio(p.FP, p.FP+p.TN)
+	return p
+}
+
+// Curve evaluates the samples at every multiple of step up to and including
+// the first threshold which contains all samples.
+func Curve(samples []Sample, step float64) []Point {
+	if step <= 0 || len(samples) == 0 {
+		return nil
+	}
+	max := 0.0
+	for _, s := range samples {
+		max = math.Max(max, s.Distance)
+	}
+	points := make([]Point, 0)
+	for t := step; ; t += step {
+		points = append(points, Evaluate(samples, t))
+		if t > max {
+			break
+		}
+	}
+	return points
+}
+
+// SuggestBands returns the upper distance boundaries for confidence 5 down to
+// confidence 1. The boundary for each of the first len(targets) bands is the
+// largest threshold which still meets the corresponding precision target. The
+// final band ends halfway past the most distant confirmed duplicate so that
+// every confirmed duplicate is included. Boundaries never decrease from one
+// band to the next.
+func SuggestBands(samples []Sample, targets []float64) ([]int, error) {
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Distance < sorted[j].Distance })
+
+	maxDupe := -1.0
+	for _, s := range sorted {
+		if s.Duplicate {
+			maxDupe = s.Distance
+		}
+	}
+	if maxDupe < 0 {
+		return nil, errors.New("no confirmed duplicates in the reviewed pairs")
+	}
+
+	// Candidate thresholds sit halfway between each distinct distance and the
+	// next one so that the pair at that distance is included by the "less
+	// than" comparison.
+	distances := make([]float64, 0, len(sorted))
+	for i, s := range sorted {
+		if i == 0 || s.Distance != sorted[i-1].Distance {
+			distances = append(distances, s.Distance)
+		}
+	}
+	candidates := make([]float64, len(distances))
+	for i := range distances {
+		candidates[i] = boundaryAbove(distances, i)
+	}
+	lastDupe := sort.SearchFloat64s(distances, maxDupe)
+
+	bands := make([]int, 0, len(targets)+1)
+	for _, target := range targets {
+		best := 0.0
+		for _, c := range candidates {
+			p := Evaluate(sorted, c)
+			if p.TP > 0 && p.Precision >= target {
+				best = c
+			}
+		}
+		bands = append(bands, int(best))
+	}
+	bands = append(bands, int(boundaryAbove(distances, lastDupe)))
+
+	for i := 1; i < len(bands); i++ {
+		if bands[i] < bands[i-1] {
+			bands[i] = bands[i-1]
+		}
+	}
+	return bands, nil
+}
+
+// boundaryAbove returns the whole number threshold halfway between distances[i]
+// and the next distance, or just above distances[i] if it is the largest.
+func boundaryAbove(distances []float64, i int) float64 {
+	if i == len(distances)-1 {
+		return math.Floor(distances[i]) + 1
+	}
+	return math.Max(math.Floor(distances[i])+1, math.Ceil((distances[i]+distances[i+1])/2))
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
@@ -0,0 +1,82 @@
+package calibrate
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	samples := []Sample{
+		{Distance: 100, Duplicate: true},
+		{Distance: 900, Duplicate: true},
+		{Distance: 1500, Duplicate: false},
+		{Distance: 3000, Duplicate: true},
+		{Distance: 6000, Duplicate: false},
+	}
+
+	p := Evaluate(samples, 2000)
+	if p.TP != 2 || p.FP != 1 || p.FN != 1 || p.TN != 1 {
+		t.Errorf("wrong counts at threshold 2000: %+v", p)
+	}
+	if p.Precision != 2.0/3.0 {
+		t.Error("precision should be 2/3. is", p.Precision)
+	}
+	if p.Recall != 2.0/3.0 {
+		t.Error("recall should be 2/3. is", p.Recall)
+	}
+	if p.FalsePositiveRate != 0.5 {
+		t.Error("false positive rate should be 0.5. is", p.FalsePositiveRate)
+	}
+
+	// A threshold below every distance should not divide by zero
+	p = Evaluate(samples, 0)
+	if p.Precision != 0 || p.Recall != 0 {
+		t.Errorf("empty selection should have zero precision and recall: %+v", p)
+	}
+}
+
+func TestCurve(t *testing.T) {
+	samples := []Sample{
+		{Distance: 100, Duplicate: true},
+		{Distance: 2500, Duplicate: false},
+	}
+	points := Curve(samples, 1000)
+	if len(points) != 3 {
+		t.Fatal("curve should stop at the first threshold above every sample. length =", len(points))
+	}
+	if points[2].Threshold != 3000 || points[2].FP != 1 {
+		t.Errorf("last point should include every sample: %+v", points[2])
+	}
+	if Curve(samples, 0) != nil {
+		t.Error("a zero step should return no points")
+	}
+}
+
+func TestSuggestBands(t *testing.T) {
+	samples := []Sample{
+		{Distance: 100, Duplicate: true},
+		{Distance: 200, Duplicate: true},
+		{Distance: 1000, Duplicate: true},
+		{Distance: 1200, Duplicate: false},
+		{Distance: 4000, Duplicate: true},
+		{Distance: 5000, Duplicate: false},
+		{Distance: 6000, Duplicate: false},
+		{Distance: 9000, Duplicate: true},
+	}
+	bands, err := SuggestBands(samples, []float64{1, 0.8, 0.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []int{1100, 4500, 9001, 9001}
+	if len(bands) != len(expect) {
+		t.Fatalf("expected %d bands. got %v", len(expect), bands)
+	}
+	for i := range expect {
+		if bands[i] != expect[i] {
+			t.Errorf("band %d should be %d. got %v", i, expect[i], bands)
+		}
+	}
+
+	// Without any confirmed duplicates there is nothing to calibrate against
+	_, err = SuggestBands([]Sample{{Distance: 100}}, DefaultTargets)
+	if err == nil {
+		t.Error("suggesting bands without duplicates should return an error")
+	}
+}
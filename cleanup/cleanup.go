@@ -0,0 +1,253 @@
+// Package cleanup removes the duplicate images confirmed in a results file. It
+// is shared by the delete-duplicates and move-duplicates commands and the
+// review GUI so that every way of acting on a decision makes the same safety
+// checks before a file is touched.
+package cleanup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mike-lloyd03/dedugo/metadata"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/trash"
+)
+
+// File is a duplicate image chosen for removal.
+type File struct {
+	Path string
+	Size int64
+	// Root is the directory the scan found the file in.
+	Root string
+	// Hash is the hash of the file's contents when the scan found it. It is
+	// empty for results files which didn't record it.
+	Hash string
+	// Keep is the image kept in place of this one.
+	Keep string
+	// Reference is set if the file is the reference image of its pair, which
+	// is only removed when the reviewer chose to keep the duplicate.
+	Reference bool
+}
+
+// Skip is a duplicate image which won't be removed and the reason why.
+type Skip struct {
+	Path   string
+	Reason string
+}
+
+// Plan lists the files removing the duplicates of a set of pairs would touch.
+type Plan struct {
+	Files   []File
+	Skipped []Skip
+	// Bytes is the total size of Files.
+	Bytes int64
+}
+
+// Outcome is the result of removing one file. Dest is where a moved or trashed
+// file was moved to and Info is the trash info file of a trashed file.
+type Outcome struct {
+	File File
+	Dest string
+	Info string
+	// Hash is the hash of the file's contents, read before it was removed.
+	Hash string
+	Err  error
+}
+
+// Confirmed returns the indices of the pairs marked as duplicates. If all is
+// set, pairs which have not been marked as not duplicates are included too.
+func Confirmed(results review.Results, all bool) []int {
+	var indices []int
+	for i, p := range results.ImagePairs {
+		if p.Review == review.Duplicate || (all && p.Review != review.NotDuplicate) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// NewPlan checks the image each of the given pairs would remove, usually its
+// duplicate image, and returns the ones which are safe to remove. Pairs which
+// keep both images are left out. An image is skipped if it no longer exists,
+// if the image kept in its place no longer exists or is the same file, if
+// either image has changed since the scan, or if removing it would remove the
+// last copy because the kept image is itself being removed in favour of this
+// one.
+func NewPlan(results review.Results, indices []int) Plan {
+	var plan Plan
+	// keepOf maps each file being removed to the image kept in its place
+	keepOf := make(map[string]string)
+	for _, i := range indices {
+		p := results.ImagePairs[i]
+		path, keep := p.Removed(), p.Kept()
+		if path == "" {
+			continue
+		}
+		if _, ok := keepOf[path]; ok {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, Skip{path, "it no longer exists"})
+			continue
+		}
+		keepInfo, err := os.Stat(keep)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, Skip{path, fmt.Sprintf("the image kept in its place, %s, no longer exists", keep)})
+			continue
+		}
+		if os.SameFile(info, keepInfo) {
+			plan.Skipped = append(plan.Skipped, Skip{path, fmt.Sprintf("it is the same file as %s", keep)})
+			continue
+		}
+		// The pair was only confirmed for the images as they were scanned
+		state := p.FileOf(path)
+		if state != nil && state.Check(path) != nil {
+			plan.Skipped = append(plan.Skipped, Skip{path, "it has changed since the scan"})
+			continue
+		}
+		if keepState := p.FileOf(keep); keepState != nil && keepState.Check(keep) != nil {
+			plan.Skipped = append(plan.Skipped, Skip{path, fmt.Sprintf("the image kept in its place, %s, has changed since the scan", keep)})
+			continue
+		}
+		if removesLastCopy(keepOf, path, keep) {
+			plan.Skipped = append(plan.Skipped, Skip{path, fmt.Sprintf("%s is already being removed in favour of it", keep)})
+			continue
+		}
+
+		keepOf[path] = keep
+		reference := inReference(results, p, path)
+		root := results.EvalDir
+		if reference {
+			root = results.RefDir
+		}
+		f := File{Path: path, Size: info.Size(), Root: root, Keep: keep, Reference: reference}
+		if state != nil {
+			f.Hash = state.Hash
+		}
+		plan.Files = append(plan.Files, f)
+		plan.Bytes += info.Size()
+	}
+	return plan
+}
+
+// inReference reports whether the pair's image at path was found in the
+// reference directory. The directories are compared rather than the image's
+// place in the pair, which a results file doesn't guarantee. If both
+// directories hold the image, as when one is inside the other or a directory
+// was compared with itself, the more specific directory wins and then the
+// image's place in the pair.
+func inReference(results review.Results, p review.Pair, path string) bool {
+	refRel, inRef := relative(results.RefDir, path)
+	evalRel, inEval := relative(results.EvalDir, path)
+	switch {
+	case inRef && inEval && len(refRel) != len(evalRel):
+		return len(refRel) < len(evalRel)
+	case inRef != inEval:
+		return inRef
+	}
+	return path == p.RefImage
+}
+
+// FromReference returns the files in the plan which are reference images.
+func (p Plan) FromReference() []File {
+	var files []File
+	for _, f := range p.Files {
+		if f.Reference {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// removesLastCopy reports whether removing path would leave no copy of the
+// image, by following the chain of kept images from keep until it reaches one
+// which isn't being removed.
+func removesLastCopy(keepOf map[string]string, path, keep string) bool {
+	for {
+		if keep == path {
+			return true
+		}
+		next, ok := keepOf[keep]
+		if !ok {
+			return false
+		}
+		keep = next
+	}
+}
+
+// Recorder is called with the outcome of removing each file just before the
+// file is removed, once where it is going is known. The file is left in place
+// if it returns an error.
+type Recorder func(Outcome) error
+
+// Delete removes every file in the plan. record may be nil.
+func Delete(plan Plan, record Recorder) []Outcome {
+	outcomes := make([]Outcome, len(plan.Files))
+	for i, f := range plan.Files {
+		outcomes[i] = hashed(f)
+		if outcomes[i].Err == nil {
+			outcomes[i].Err = recorded(record, outcomes[i])
+		}
+		if outcomes[i].Err == nil {
+			outcomes[i].Err = os.Remove(f.Path)
+		}
+	}
+	return outcomes
+}
+
+// Trash moves every file in the plan to the trash, from where it can be
+// restored. record may be nil.
+func Trash(plan Plan, t trash.Trash, record Recorder) []Outcome {
+	outcomes := make([]Outcome, len(plan.Files))
+	for i, f := range plan.Files {
+		outcomes[i] = hashed(f)
+		if outcomes[i].Err != nil {
+			continue
+		}
+		item, err := t.Reserve(f.Path)
+		if err != nil {
+			outcomes[i].Err = err
+			continue
+		}
+		outcomes[i].Dest, outcomes[i].Info = item.File, item.Info
+		if err := recorded(record, outcomes[i]); err != nil {
+			item.Release()
+			outcomes[i].Err = err
+			continue
+		}
+		if err := item.Move(); err != nil {
+			item.Release()
+			outcomes[i].Err = err
+		}
+	}
+	return outcomes
+}
+
+func recorded(record Recorder, o Outcome) error {
+	if record == nil {
+		return nil
+	}
+	return record(o)
+}
+
+// hashed returns the outcome of removing f with the hash of its contents. A
+// file which can't be read, or has changed since the scan, is never removed.
+func hashed(f File) Outcome {
+	hash, err := metadata.Hash(f.Path)
+	if err == nil && f.Hash != "" && hash != f.Hash {
+		err = fmt.Errorf("%s has changed since the scan", f.Path)
+	}
+	return Outcome{File: f, Hash: hash, Err: err}
+}
+
+// Reclaimed counts the files which were removed and their total size.
+func Reclaimed(outcomes []Outcome) (files int, bytes int64) {
+	for _, o := range outcomes {
+		if o.Err == nil {
+			files++
+			bytes += o.File.Size
+		}
+	}
+	return files, bytes
+}
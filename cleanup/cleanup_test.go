@@ -0,0 +1,268 @@
+package cleanup
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/trash"
+)
+
+// writeFiles creates files of the given sizes in a temporary directory and
+// returns their paths by name.
+func writeFiles(t *testing.T, sizes map[string]int) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make(map[string]string)
+	for name, size := range sizes {
+		paths[name] = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(paths[name], make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func pair(ref, dupe string, state review.State) review.Pair {
+	return review.Pair{RefImage: ref, DupeImage: dupe, Review: state}
+}
+
+func TestConfirmed(t *testing.T) {
+	results := review.Results{ImagePairs: []review.Pair{
+		pair("a", "b", review.Duplicate),
+		pair("c", "d", review.NotDuplicate),
+		pair("e", "f", review.Unreviewed),
+		pair("g", "h", review.Skipped),
+	}}
+	if got := Confirmed(results, false); len(got) != 1 || got[0] != 0 {
+		t.Error("expected only the confirmed pair. got", got)
+	}
+	if got := Confirmed(results, true); len(got) != 3 || got[1] != 2 {
+		t.Error("expected every pair not marked as not a duplicate. got", got)
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20, "c": 30, "d": 40, "e": 50, "x": 60})
+	missing := filepath.Join(filepath.Dir(f["a"]), "missing")
+	results := review.Results{ImagePairs: []review.Pair{
+		pair(f["a"], f["b"], review.Duplicate),
+		// b is only removed once
+		pair(f["c"], f["b"], review.Duplicate),
+		pair(f["a"], missing, review.Duplicate),
+		pair(missing, f["c"], review.Duplicate),
+		pair(f["d"], f["d"], review.Duplicate),
+		// Removing a in favour of b would remove both copies
+		pair(f["b"], f["a"], review.Duplicate),
+		// e can go in favour of b because b's copy a is kept
+		pair(f["b"], f["e"], review.Duplicate),
+	}}
+	plan := NewPlan(results, Confirmed(results, false))
+
+	if len(plan.Files) != 2 || plan.Files[0].Path != f["b"] || plan.Files[1].Path != f["e"] {
+		t.Fatalf("expected b and e to be removed. got %+v", plan.Files)
+	}
+	if plan.Bytes != 70 || plan.Files[0].Keep != f["a"] {
+		t.Errorf("unexpected plan totals %+v", plan)
+	}
+	want := []string{missing, f["c"], f["d"], f["a"]}
+	if len(plan.Skipped) != len(want) {
+		t.Fatalf("expected %d skipped files. got %+v", len(want), plan.Skipped)
+	}
+	for i, s := range plan.Skipped {
+		if s.Path != want[i] || s.Reason == "" {
+			t.Errorf("skip %d: expected %s with a reason. got %+v", i, want[i], s)
+		}
+	}
+}
+
+func TestNewPlanKeep(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20, "c": 30, "d": 40})
+	keepDupe := pair(f["a"], f["b"], review.Duplicate)
+	keepDupe.Keep = review.KeepDuplicate
+	keepBoth := pair(f["c"], f["d"], review.Duplicate)
+	keepBoth.Keep = review.KeepBoth
+	results := review.Results{ImagePairs: []review.Pair{keepDupe, keepBoth}}
+
+	plan := NewPlan(results, []int{0, 1})
+	if len(plan.Files) != 1 || plan.Files[0].Path != f["a"] || plan.Files[0].Keep != f["b"] {
+		t.Fatalf("expected only the reference image a to be removed. got %+v", plan)
+	}
+	if refs := plan.FromReference(); len(refs) != 1 || !refs[0].Reference {
+		t.Error("removing a reference image should be flagged. got", refs)
+	}
+	if len(plan.Skipped) != 0 {
+		t.Error("pairs keeping both images should not be reported as skipped. got", plan.Skipped)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20, "c": 30})
+	tr := trash.Trash{Home: filepath.Join(t.TempDir(), "Trash"), UID: 1000}
+	results := review.Results{ImagePairs: []review.Pair{
+		pair(f["a"], f["b"], review.Duplicate),
+		pair(f["a"], f["c"], review.Duplicate),
+	}}
+	failed := errors.New("journal full")
+	var recorded []Outcome
+	record := func(o Outcome) error {
+		if _, err := os.Stat(o.File.Path); err != nil {
+			t.Errorf("%s should be recorded before it is removed", o.File.Path)
+		}
+		if o.File.Path == f["c"] {
+			return failed
+		}
+		recorded = append(recorded, o)
+		return nil
+	}
+
+	outcomes := Trash(NewPlan(results, []int{0, 1}), tr, record)
+	if len(recorded) != 1 || recorded[0].Dest != outcomes[0].Dest || recorded[0].Info == "" || recorded[0].Hash == "" {
+		t.Errorf("expected b to be recorded with where it went. got %+v", recorded)
+	}
+	if outcomes[0].Err != nil || outcomes[1].Err != failed {
+		t.Errorf("expected only b to be trashed. got %+v", outcomes)
+	}
+	if _, err := os.Stat(f["c"]); err != nil {
+		t.Error("a file which could not be recorded should be left in place.", err)
+	}
+	if _, err := os.Stat(filepath.Join(tr.Home, "info", "c.trashinfo")); !os.IsNotExist(err) {
+		t.Error("the trash info file of a file left in place should be removed")
+	}
+}
+
+func TestNewPlanChanged(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20, "c": 30, "d": 40, "e": 50, "f": 60})
+	scanned := func(ref, dupe string) review.Pair {
+		p := pair(f[ref], f[dupe], review.Duplicate)
+		refState, err := review.Snapshot(f[ref])
+		if err != nil {
+			t.Fatal(err)
+		}
+		dupeState, err := review.Snapshot(f[dupe])
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.RefFile, p.DupeFile = &refState, &dupeState
+		return p
+	}
+	results := review.Results{ImagePairs: []review.Pair{scanned("a", "b"), scanned("c", "d"), scanned("e", "f")}}
+	ioutil.WriteFile(f["d"], []byte("edited"), 0644)
+	ioutil.WriteFile(f["e"], []byte("replaced"), 0644)
+
+	plan := NewPlan(results, []int{0, 1, 2})
+	if len(plan.Files) != 1 || plan.Files[0].Path != f["b"] || plan.Files[0].Hash != results.ImagePairs[0].DupeFile.Hash {
+		t.Fatalf("expected only the unchanged pair to be removed. got %+v", plan.Files)
+	}
+	if len(plan.Skipped) != 2 || plan.Skipped[0].Path != f["d"] || plan.Skipped[1].Path != f["f"] {
+		t.Fatalf("expected d and f to be skipped. got %+v", plan.Skipped)
+	}
+	if !strings.Contains(plan.Skipped[1].Reason, f["e"]) {
+		t.Error("the reason should name the changed reference image. got", plan.Skipped[1].Reason)
+	}
+
+	// A file changed after the plan was made is still not removed
+	ioutil.WriteFile(f["b"], []byte("changed after planning"), 0644)
+	if outcomes := Delete(plan, nil); outcomes[0].Err == nil {
+		t.Error("a file which changed since the scan should not be deleted")
+	}
+	if _, err := os.Stat(f["b"]); err != nil {
+		t.Error("the changed file should be kept.", err)
+	}
+}
+
+func TestNewPlanResolvedCluster(t *testing.T) {
+	ref, eval := t.TempDir(), t.TempDir()
+	paths := map[string]string{
+		"a": filepath.Join(ref, "a.jpg"),
+		"b": filepath.Join(eval, "sub", "b.jpg"),
+		"c": filepath.Join(eval, "sub", "c.jpg"),
+		"d": filepath.Join(ref, "d.jpg"),
+	}
+	os.Mkdir(filepath.Join(eval, "sub"), 0755)
+	for _, path := range paths {
+		if err := ioutil.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scanned := func(refImg, dupeImg string) review.Pair {
+		p := pair(refImg, dupeImg, review.Unreviewed)
+		refState, _ := review.Snapshot(refImg)
+		dupeState, _ := review.Snapshot(dupeImg)
+		p.RefFile, p.DupeFile = &refState, &dupeState
+		return p
+	}
+	results := review.Results{RefDir: ref, EvalDir: eval, ImagePairs: []review.Pair{
+		scanned(paths["a"], paths["b"]),
+		// A pair found the other way round
+		scanned(paths["c"], paths["d"]),
+	}}
+	results.ResolveCluster(results.Cluster(0), paths["b"], []string{paths["a"]}, "obi")
+	results.ResolveCluster(results.Cluster(1), paths["c"], []string{paths["d"]}, "obi")
+
+	plan := NewPlan(results, Confirmed(results, false))
+	if len(plan.Files) != 2 || len(plan.Skipped) != 0 {
+		t.Fatalf("expected both reference images to be removed. got %+v", plan)
+	}
+	for _, f := range plan.Files {
+		if !f.Reference || f.Root != ref || f.Hash == "" {
+			t.Errorf("%s should be flagged as a reference image with its state checked. got %+v", f.Path, f)
+		}
+	}
+	if refs := plan.FromReference(); len(refs) != 2 {
+		t.Error("removing the reference images should be warned about. got", refs)
+	}
+	dest := t.TempDir()
+	dests := Destinations(plan, dest, MoveOptions{Mirror: true})
+	if dests[0] != filepath.Join(dest, "a.jpg") || dests[1] != filepath.Join(dest, "d.jpg") {
+		t.Errorf("the images should be mirrored relative to the reference directory. got %v", dests)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20, "c": 30})
+	results := review.Results{ImagePairs: []review.Pair{
+		pair(f["a"], f["b"], review.Duplicate),
+		pair(f["a"], f["c"], review.Duplicate),
+	}}
+	plan := NewPlan(results, []int{0, 1})
+	os.Remove(f["c"])
+
+	outcomes := Delete(plan, nil)
+	if outcomes[0].Err != nil || outcomes[1].Err == nil {
+		t.Errorf("expected only the file which still exists to be deleted. got %+v", outcomes)
+	}
+	if _, err := os.Stat(f["b"]); !os.IsNotExist(err) {
+		t.Error("b should have been deleted")
+	}
+	if _, err := os.Stat(f["a"]); err != nil {
+		t.Error("the reference image should be kept.", err)
+	}
+	if files, bytes := Reclaimed(outcomes); files != 1 || bytes != 20 {
+		t.Errorf("expected 1 file and 20 bytes reclaimed. got %d and %d", files, bytes)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20})
+	tr := trash.Trash{Home: filepath.Join(t.TempDir(), "Trash"), UID: 1000}
+	results := review.Results{ImagePairs: []review.Pair{pair(f["a"], f["b"], review.Duplicate)}}
+
+	outcomes := Trash(NewPlan(results, []int{0}), tr, nil)
+	if outcomes[0].Err != nil || outcomes[0].Dest != filepath.Join(tr.Home, "files", "b") {
+		t.Fatalf("expected b to be moved to the trash. got %+v", outcomes)
+	}
+	if _, err := os.Stat(f["b"]); !os.IsNotExist(err) {
+		t.Error("b should have been moved")
+	}
+	if outcomes[0].Info != filepath.Join(tr.Home, "info", "b.trashinfo") || outcomes[0].Hash == "" {
+		t.Errorf("expected the trash info file and hash to be recorded. got %+v", outcomes[0])
+	}
+	if _, err := os.Stat(outcomes[0].Info); err != nil {
+		t.Error("b should be restorable from the trash.", err)
+	}
+}
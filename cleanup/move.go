@@ -0,0 +1,175 @@
+package cleanup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mike-lloyd03/dedugo/metadata"
+)
+
+// MoveOptions controls where Move puts each file.
+type MoveOptions struct {
+	// Mirror keeps each file's path relative to the directory the scan found
+	// it in, so that files with the same name in different directories don't
+	// collide. Files outside that directory are moved to the top of the
+	// destination.
+	Mirror bool
+	// Rename gives a file a numbered name, such as "IMG_0001.2.jpg", when its
+	// destination already exists. Otherwise the file is left in place.
+	Rename bool
+}
+
+// rename is replaced in tests to move files as if across devices.
+var rename = os.Rename
+
+// Destinations returns where Move would move each file in the plan. Files are
+// given numbered names in the order of the plan, so the same plan and
+// destination always give the same names.
+func Destinations(plan Plan, dir string, opts MoveOptions) []string {
+	dests := make([]string, len(plan.Files))
+	taken := make(map[string]bool)
+	for i, f := range plan.Files {
+		dest := filepath.Join(dir, filepath.Base(f.Path))
+		if opts.Mirror {
+			if rel, ok := relative(f.Root, f.Path); ok {
+				dest = filepath.Join(dir, rel)
+			}
+		}
+		if opts.Rename {
+			first := dest
+			for n := 2; taken[dest] || exists(dest); n++ {
+				dest = numbered(first, n)
+			}
+		}
+		taken[dest] = true
+		dests[i] = dest
+	}
+	return dests
+}
+
+// Move moves every file in the plan into dir, creating any directories
+// needed. Files are never moved over an existing file. record may be nil.
+func Move(plan Plan, dir string, opts MoveOptions, record Recorder) []Outcome {
+	outcomes := make([]Outcome, len(plan.Files))
+	for i, dest := range Destinations(plan, dir, opts) {
+		outcomes[i] = hashed(plan.Files[i])
+		outcomes[i].Dest = dest
+		if outcomes[i].Err == nil {
+			outcomes[i].Err = recorded(record, outcomes[i])
+		}
+		if outcomes[i].Err == nil {
+			outcomes[i].Err = MoveFile(plan.Files[i].Path, dest, outcomes[i].Hash)
+		}
+	}
+	return outcomes
+}
+
+// MoveFile moves the file at src to dest, creating dest's directory if
+// needed. It never replaces an existing file. If dest is on another device the
+// file is copied with its permissions and modification time, the copy is
+// checked against hash, the hash of src's contents, and only then is src
+// removed.
+func MoveFile(src, dest, hash string) error {
+	if exists(dest) {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	err := rename(src, dest)
+	if err == nil || !crossDevice(err) {
+		return err
+	}
+	if err := copyFile(src, dest, hash); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to dest through a temporary file in dest's directory so
+// that dest never holds a partial copy.
+func copyFile(src, dest, hash string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fail(err)
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return fail(err)
+	}
+	if copied, err := metadata.Hash(tmp.Name()); err != nil {
+		return fail(err)
+	} else if copied != hash {
+		return fail(fmt.Errorf("the copy of %s at %s does not match the original", src, dest))
+	}
+	if exists(dest) {
+		return fail(fmt.Errorf("%s already exists", dest))
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// relative returns the path of path within root, or false if it isn't inside
+// root.
+func relative(root, path string) (string, bool) {
+	if root == "" {
+		return "", false
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// numbered returns path with n added before its extension, for example
+// "IMG_0001.2.jpg".
+func numbered(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
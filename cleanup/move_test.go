@@ -0,0 +1,132 @@
+package cleanup
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+func TestMove(t *testing.T) {
+	f := writeFiles(t, map[string]int{"a": 10, "b": 20})
+	other := writeFiles(t, map[string]int{"b": 1, "c": 30})
+	dest := t.TempDir()
+	results := review.Results{ImagePairs: []review.Pair{
+		pair(f["a"], f["b"], review.Duplicate),
+		pair(f["a"], other["c"], review.Duplicate),
+		pair(f["a"], other["b"], review.Duplicate),
+	}}
+
+	outcomes := Move(NewPlan(results, []int{0, 1, 2}), dest, MoveOptions{}, nil)
+	if outcomes[0].Err != nil || outcomes[0].Dest != filepath.Join(dest, "b") || outcomes[1].Err != nil {
+		t.Errorf("expected b and c to be moved. got %+v", outcomes)
+	}
+	if outcomes[2].Err == nil {
+		t.Error("a file should never be moved over an existing one")
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dest, "b")); len(data) != 20 {
+		t.Error("the first b moved should not have been replaced")
+	}
+	if _, err := os.Stat(other["b"]); err != nil {
+		t.Error("a file which could not be moved should be left in place.", err)
+	}
+}
+
+func TestMoveMirrorRename(t *testing.T) {
+	ref, eval := t.TempDir(), t.TempDir()
+	paths := map[string]string{
+		"ref":    filepath.Join(ref, "IMG_0001.jpg"),
+		"first":  filepath.Join(eval, "2020", "IMG_0001.jpg"),
+		"second": filepath.Join(eval, "2021", "IMG_0001.jpg"),
+		"third":  filepath.Join(eval, "2021", "copy", "IMG_0001.jpg"),
+	}
+	for _, p := range paths {
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := review.Results{RefDir: ref, EvalDir: eval, ImagePairs: []review.Pair{
+		pair(paths["ref"], paths["first"], review.Duplicate),
+		pair(paths["ref"], paths["second"], review.Duplicate),
+		pair(paths["ref"], paths["third"], review.Duplicate),
+	}}
+	plan := NewPlan(results, []int{0, 1, 2})
+
+	dest := t.TempDir()
+	flat := Destinations(plan, dest, MoveOptions{Rename: true})
+	want := []string{"IMG_0001.jpg", "IMG_0001.2.jpg", "IMG_0001.3.jpg"}
+	for i, d := range flat {
+		if d != filepath.Join(dest, want[i]) {
+			t.Errorf("expected %s. got %s", want[i], d)
+		}
+	}
+
+	os.MkdirAll(filepath.Join(dest, "2021"), 0755)
+	ioutil.WriteFile(filepath.Join(dest, "2021", "IMG_0001.jpg"), nil, 0644)
+	outcomes := Move(plan, dest, MoveOptions{Mirror: true, Rename: true}, nil)
+	want = []string{
+		filepath.Join("2020", "IMG_0001.jpg"),
+		filepath.Join("2021", "IMG_0001.2.jpg"),
+		filepath.Join("2021", "copy", "IMG_0001.jpg"),
+	}
+	for i, o := range outcomes {
+		if o.Err != nil || o.Dest != filepath.Join(dest, want[i]) {
+			t.Errorf("expected %s to be moved to %s. got %+v", o.File.Path, want[i], o)
+		}
+		if data, _ := ioutil.ReadFile(o.Dest); string(data) != o.File.Path {
+			t.Errorf("%s should hold %s", o.Dest, o.File.Path)
+		}
+	}
+}
+
+func TestMoveFileAcrossDevices(t *testing.T) {
+	rename = func(string, string) error { return &os.LinkError{Op: "rename", Err: errCrossDevice} }
+	defer func() { rename = os.Rename }()
+
+	f := writeFiles(t, map[string]int{"a": 10})
+	mtime := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	os.Chmod(f["a"], 0600)
+	os.Chtimes(f["a"], mtime, mtime)
+	h := hashed(File{Path: f["a"]})
+	dest := filepath.Join(t.TempDir(), "new", "a")
+
+	if err := MoveFile(f["a"], dest, "wrong"); err == nil {
+		t.Error("a copy which doesn't match the hash should fail")
+	}
+	if _, err := os.Stat(f["a"]); err != nil {
+		t.Fatal("the original should be kept if the copy doesn't match.", err)
+	}
+	if files, _ := ioutil.ReadDir(filepath.Dir(dest)); len(files) != 0 {
+		t.Error("a failed copy should be removed")
+	}
+
+	if err := MoveFile(f["a"], dest, h.Hash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f["a"]); !os.IsNotExist(err) {
+		t.Error("the original should be removed once it has been copied")
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 || !info.ModTime().Equal(mtime) {
+		t.Errorf("the copy should keep the permissions and modification time. got %v %v", info.Mode(), info.ModTime())
+	}
+}
+
+func TestMoveFileOtherErrors(t *testing.T) {
+	failed := errors.New("failed")
+	rename = func(string, string) error { return failed }
+	defer func() { rename = os.Rename }()
+
+	f := writeFiles(t, map[string]int{"a": 10})
+	if err := MoveFile(f["a"], filepath.Join(t.TempDir(), "a"), ""); err != failed {
+		t.Error("only a move across devices should be copied. got", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package cleanup
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// crossDevice reports whether a rename failed because the destination is on
+// another device.
+func crossDevice(err error) bool {
+	return errors.Is(err, unix.EXDEV)
+}
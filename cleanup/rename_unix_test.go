@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package cleanup
+
+import "golang.org/x/sys/unix"
+
+var errCrossDevice = unix.EXDEV
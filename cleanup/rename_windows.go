@@ -0,0 +1,13 @@
+package cleanup
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// crossDevice reports whether a rename failed because the destination is on
+// another volume.
+func crossDevice(err error) bool {
+	return errors.Is(err, windows.ERROR_NOT_SAME_DEVICE)
+}
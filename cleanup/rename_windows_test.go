@@ -0,0 +1,5 @@
+package cleanup
+
+import "golang.org/x/sys/windows"
+
+var errCrossDevice = windows.ERROR_NOT_SAME_DEVICE
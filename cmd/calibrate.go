@@ -0,0 +1,133 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/mike-lloyd03/dedugo/calibrate"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	calibrateStep int
+	calibrateAll  bool
+	writeConfig   bool
+)
+
+// calibrateCmd represents the calibrate command
+var calibrateCmd = &cobra.Command{
+	Args:  cobra.MinimumNArgs(1),
+	Use:   "calibrate results_file [results_file...]",
+	Short: "Tune the confidence bands using reviewed results",
+	Long: `Reads one or more results files which have been reviewed with "dedugo check-results" and uses the confirmed pairs as labeled data. Precision, recall and false positive rate are printed for a range of distance thresholds along with suggested distance boundaries for each confidence score.
+
+Only pairs which have been reviewed are used. Recall is measured against the duplicates present in the results files, so duplicates which "find-duplicates" never reported are not accounted for.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		calibrateBands(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+
+	calibrateCmd.Flags().IntVarP(&calibrateStep, "step", "s", 1000, "distance between each threshold in the table")
+	calibrateCmd.Flags().BoolVar(&calibrateAll, "all", false, "treat every undecided pair as not a duplicate")
+	calibrateCmd.Flags().BoolVar(&writeConfig, "write-config", false, "write the suggested confidence bands to the config file")
+}
+
+func calibrateBands(paths []string) {
+	samples := make([]calibrate.Sample, 0)
+	for _, path := range paths {
+		results := readResultsFile(path)
+		if !hasDistances(results) {
+			fmt.Printf("Skipping %s. It was created before distances were recorded.\n", path)
+			continue
+		}
+		for _, p := range reviewedPairs(results, calibrateAll) {
+			samples = append(samples, calibrate.Sample{Distance: float64(p.Distance), Duplicate: p.Review == review.Duplicate})
+		}
+	}
+	if len(samples) == 0 {
+		log.Fatal("No reviewed pairs with a recorded distance were found.")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Threshold\tTP\tFP\tFN\tTN\tPrecision\tRecall\tFP Rate\t")
+	for _, p := range calibrate.Curve(samples, float64(calibrateStep)) {
+		fmt.Fprintf(tw, "%.0f\t%d\t%d\t%d\t%d\t%.3f\t%.3f\t%.3f\t\n", p.Threshold, p.TP, p.FP, p.FN, p.TN, p.Precision, p.Recall, p.FalsePositiveRate)
+	}
+	tw.Flush()
+
+	bands, err := calibrate.SuggestBands(samples, calibrate.DefaultTargets)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\nCalibrated from %d reviewed pairs.\n", len(samples))
+	fmt.Println("Suggested confidence bands (distance less than):")
+	for i, b := range bands {
+		fmt.Printf("  Confidence %d: %d\n", len(bands)-i, b)
+	}
+
+	if writeConfig {
+		viper.Set("confidence-bands", bands)
+		path := viper.ConfigFileUsed()
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				log.Fatal(err)
+			}
+			path = filepath.Join(home, ".dedugo.yaml")
+		}
+		if err := viper.WriteConfigAs(path); err != nil {
+			log.Fatal("Error writing config file.", err)
+		}
+		fmt.Println("Wrote confidence bands to", path)
+	}
+}
+
+// reviewedPairs returns the pairs which the user has marked as duplicates or
+// not duplicates. If all is set, pairs which have not been decided are
+// included as well.
+func reviewedPairs(results review.Results, all bool) []review.Pair {
+	if all {
+		return results.ImagePairs
+	}
+	pairs := make([]review.Pair, 0)
+	for _, p := range results.ImagePairs {
+		if p.Review == review.Duplicate || p.Review == review.NotDuplicate {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+// hasDistances reports whether the results were written by a version of
+// find-duplicates which records the distance of each pair.
+func hasDistances(results review.Results) bool {
+	for _, p := range results.ImagePairs {
+		if p.Distance != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -16,19 +16,61 @@ limitations under the License.
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"os/exec"
+	"strings"
+	"time"
 
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/session"
+	"github.com/mike-lloyd03/dedugo/thumbcache"
 	"github.com/spf13/cobra"
 )
 
+var (
+	reviewer            string
+	useTui              bool
+	usePrompt           bool
+	graphics            string
+	sortBy              string
+	filterStates        []string
+	filterMinConfidence int
+	filterMaxConfidence int
+	filterPathPrefix    string
+	filterMaxDistance   float32
+	clearFilter         bool
+	prefetchPairs       int
+	cacheSize           int64
+	displaySize         int
+	quarantineDir       string
+	similarWindow       time.Duration
+)
+
 // checkResultsCmd represents the checkResults command
 var checkResultsCmd = &cobra.Command{
 	Aliases: []string{"check", "c"},
 	Use:     "check-results",
 	Short:   "Check each of the image pairs found in the \"find-duplicates\" command",
-	Long:    `Check each of the image pairs by opening both of them in the system default image application. The user will be prompted to confirm if the file is a duplicate or not. All confirmed duplicates can subsequently be deleted with the "delete" command.`,
+	Long: `Check each of the image pairs side by side in a review window and decide whether they are duplicates. Use --tui to review them inside the terminal instead, or --prompt to open both images of each pair in the system default image application and answer a prompt for each. All confirmed duplicates can subsequently be deleted with the "delete" command.
+
+The review queue can be sorted and filtered with the --sort, --state, --min-confidence, --max-confidence, --max-distance and --path-prefix flags. The filter is saved in the results file and used again the next time the results are reviewed until it is changed or removed with --clear-filter.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if reviewer == "" {
+			reviewer = currentUser()
+		}
+		updateFilter(cmd)
+		if usePrompt {
+			checkResults(resultsPath, os.Stdin)
+			return
+		}
+		if useTui {
+			checkResultsTui()
+			return
+		}
 		checkResultsGui()
 	},
 }
@@ -36,48 +78,151 @@ var checkResultsCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(checkResultsCmd)
 
-	checkResultsCmd.Flags().StringVarP(&results_path, "input", "i", "dedugo_results.yaml", "input file to read results from")
+	checkResultsCmd.Flags().StringVarP(&resultsPath, "input", "i", "dedugo_results.yaml", "input file to read results from")
+	checkResultsCmd.Flags().StringVar(&reviewer, "reviewer", "", "name recorded with each decision (default is the current user)")
+	checkResultsCmd.Flags().BoolVar(&useTui, "tui", false, "review pairs inside the terminal instead of opening a window")
+	checkResultsCmd.Flags().BoolVar(&usePrompt, "prompt", false, "open each pair in the system image viewer and ask about it on the command line")
+	checkResultsCmd.Flags().StringVar(&graphics, "graphics", "auto", "terminal graphics protocol for --tui: auto, kitty, iterm, sixel or blocks")
+	checkResultsCmd.Flags().StringVar(&sortBy, "sort", "", "order to review pairs in: none, confidence, directory or filename")
+	checkResultsCmd.Flags().StringSliceVar(&filterStates, "state", nil, "only review pairs in these states: unreviewed, duplicate, not-duplicate or skipped")
+	checkResultsCmd.Flags().IntVar(&filterMinConfidence, "min-confidence", 0, "only review pairs with at least this confidence")
+	checkResultsCmd.Flags().IntVar(&filterMaxConfidence, "max-confidence", 0, "only review pairs with at most this confidence (0 for no limit)")
+	checkResultsCmd.Flags().Float32Var(&filterMaxDistance, "max-distance", 0, "only review pairs whose images are at most this distance apart (0 for no limit)")
+	checkResultsCmd.Flags().StringVar(&filterPathPrefix, "path-prefix", "", "only review pairs where either image path starts with this prefix")
+	checkResultsCmd.Flags().BoolVar(&clearFilter, "clear-filter", false, "remove the saved sort order and filter")
+	checkResultsCmd.Flags().IntVar(&prefetchPairs, "prefetch", session.DefaultPrefetch, "number of pairs either side of the current one to load in the background")
+	checkResultsCmd.Flags().Int64Var(&cacheSize, "cache-size", 512, "memory in MB that loaded images may use (0 for no limit)")
+	checkResultsCmd.Flags().StringVar(&quarantineDir, "quarantine", "", "directory the review window moves duplicates to (default is dedugo_quarantine next to the results file)")
+	checkResultsCmd.Flags().BoolVar(&permanent, "permanent", false, "delete files permanently from the review window instead of moving them to the trash")
+	checkResultsCmd.Flags().DurationVar(&similarWindow, "similar-window", time.Hour, "maximum gap between capture times for pending pairs in the same folders to count as similar to a decided pair")
+	checkResultsCmd.Flags().IntVar(&displaySize, "display-size", int(thumbcache.XXLarge), "longest edge in pixels to decode images at when fit to the window (0 for full resolution)")
+}
+
+// updateFilter saves any sort or filter flags given on the command line to the
+// results file. Flags which are not given keep their saved values.
+func updateFilter(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	changed := clearFilter
+	for _, name := range []string{"sort", "state", "min-confidence", "max-confidence", "max-distance", "path-prefix"} {
+		changed = changed || flags.Changed(name)
+	}
+	if !changed {
+		return
+	}
+
+	results := readResultsFile(resultsPath)
+	if clearFilter {
+		results.Filter = review.Filter{}
+	}
+	if flags.Changed("sort") {
+		key, ok := review.ParseSortKey(sortBy)
+		if !ok {
+			log.Fatalf("Unknown sort order %q.", sortBy)
+		}
+		results.Filter.Sort = key
+	}
+	if flags.Changed("state") {
+		results.Filter.States = nil
+		for _, name := range filterStates {
+			state, ok := review.ParseState(name)
+			if !ok {
+				log.Fatalf("Unknown review state %q.", name)
+			}
+			results.Filter.States = append(results.Filter.States, state)
+		}
+	}
+	if flags.Changed("min-confidence") {
+		results.Filter.MinConfidence = filterMinConfidence
+	}
+	if flags.Changed("max-confidence") {
+		results.Filter.MaxConfidence = filterMaxConfidence
+	}
+	if flags.Changed("max-distance") {
+		results.Filter.MaxDistance = filterMaxDistance
+	}
+	if flags.Changed("path-prefix") {
+		results.Filter.PathPrefix = filterPathPrefix
+	}
+	WriteResultsFile(results, resultsPath)
 }
 
 // checkResults reads from the Results file and iterates over the Image Pairs,
-// asking the user to confirm if the image is a duplicate or not
-func checkResults(results_path string) {
+// asking the user to decide if the image is a duplicate or not. Answers are
+// read from in.
+func checkResults(resultsPath string, in io.Reader) {
 	var input string
-	results := readResultsFile(results_path)
+	answers := bufio.NewReader(in)
+	s, err := session.New(session.FileStore(resultsPath), nil, reviewer)
+	if err == session.ErrNoPairs {
+		fmt.Println("There are no image pairs to review.")
+		return
+	} else if err != nil {
+		log.Fatal("Error reading results file.", err)
+	}
 
-read_input:
-	for i := results.StartIdx; i < len(results.ImagePairs); i++ {
-		p := results.ImagePairs[i]
+	for {
+		p := s.Pair()
 		openDuplicates(p.RefImage, p.DupeImage)
 
-		results.StartIdx = i
-		WriteResultsFile(results, results_path)
-
-		fmt.Printf("%s and %s are duplicates? [y/N/stop] ", p.RefImage, p.DupeImage)
-		fmt.Scanln(&input)
+		if p.Review != review.Unreviewed {
+			fmt.Printf("%s (%s)\n", p.Decision(), p.Reviewer)
+		}
+		fmt.Println("Progress:", s.Progress())
+		fmt.Printf("%s and %s are duplicates? [y/N/right/both/skip/unmark/stop] ", p.RefImage, p.DupeImage)
+		input = ""
+		fmt.Fscanln(answers, &input)
 
-		switch input {
-		case "y", "Y":
-			results.ImagePairs[i].Confirmed = true
-			WriteResultsFile(results, results_path)
+		last := s.AtEnd()
+		switch strings.ToLower(input) {
+		case "y", "yes":
+			err = s.Decide(review.Duplicate)
+		case "r", "right":
+			fmt.Println("Keeping the duplicate image. Warning: the reference image", p.RefImage, "will be removed.")
+			err = s.Keep(review.KeepDuplicate)
+		case "b", "both":
+			err = s.Keep(review.KeepBoth)
+		case "s", "skip":
+			err = s.Decide(review.Skipped)
+		case "u", "unmark":
+			if err = s.Unmark(); err == nil {
+				_, err = s.Next()
+			}
 		case "stop":
-			break read_input
+			return
 		default:
-			continue
+			err = s.Decide(review.NotDuplicate)
+		}
+		if err != nil {
+			log.Fatal("Error writing results file.", err)
+		}
+		if last {
+			return
 		}
-		// if gui.ShowGui(p.RefImage, p.DupeImage) {
-		// 	fmt.Println("yes")
-		// 	results.ImagePairs[i].Confirmed = true
-		// 	writeResultsFile(results, results_path)
-		// }
 	}
 }
 
-func openDuplicates(refFile string, dupeFile string) {
+// openDuplicates opens both images of a pair in the system image viewer. It
+// is a variable so that tests can replace it.
+var openDuplicates = func(refFile string, dupeFile string) {
 	exec.Command("xdg-open", refFile).Start()
 	exec.Command("xdg-open", dupeFile).Run()
 }
 
+// newReviewSession starts a review of the results file which loads images for
+// display.
+func newReviewSession() (*session.Session, error) {
+	var err error
+	if thumbnails, err = thumbcache.Default(); err != nil {
+		log.Println("Thumbnails will not be cached.", err)
+	}
+	s, err := session.New(session.FileStore(resultsPath), loadDisplayImage, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	s.SetPrefetch(prefetchPairs, cacheSize<<20)
+	return s, nil
+}
+
 func checkResultsGui() {
 	showGui()
 }
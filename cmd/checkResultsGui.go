@@ -3,6 +3,7 @@ package cmd
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"image"
 	"io/ioutil"
 	"log"
@@ -11,8 +12,13 @@ import (
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/scan"
+	"github.com/mike-lloyd03/dedugo/session"
+	"github.com/mike-lloyd03/dedugo/thumbcache"
 )
 
 type ImageReader struct {
@@ -20,164 +26,455 @@ type ImageReader struct {
 	Reader image.Image
 }
 
-type Direction int
-
 const (
-	imgWidth            = 640
-	imgHeight           = 480
-	Previous  Direction = iota
-	Current
-	Next
+	imgWidth  = 640
+	imgHeight = 480
+
+	zoomStep = 1.25
+	minZoom  = 0.05
+	maxZoom  = 16
+
+	guiHelp = "←/→ move   Y duplicate   R keep right   B keep both   N not duplicate   S skip   X unmark   U undo   F fit   1 actual size   +/- zoom   V change view   C similar images   A apply to similar   Q quit"
 )
 
 var (
-	textCentered  = fyne.TextAlignCenter
-	monospaced    = fyne.TextStyle{Monospace: true}
-	bold          = fyne.TextStyle{Bold: true}
-	a             = app.New()
-	results       Results
+	textCentered = fyne.TextAlignCenter
+	monospaced   = fyne.TextStyle{Monospace: true}
+	bold         = fyne.TextStyle{Bold: true}
+)
+
+// reviewGui is the review window. All review logic lives in the session, the
+// window only shows its state and forwards user input to it.
+type reviewGui struct {
+	app     fyne.App
+	window  fyne.Window
+	session *session.Session
+	// images are the images of the current pair.
+	images session.Images
+
 	refImage      *canvas.Image
 	dupeImage     *canvas.Image
 	refImagePath  *widget.Label
 	dupeImagePath *widget.Label
+	refScroll     *container.Scroll
+	dupeScroll    *container.Scroll
+	statusLabel   *widget.Label
 	nextButton    *widget.Button
 	prevButton    *widget.Button
-	prevRefImage  image.Image
-	currRefImage  image.Image
-	nextRefImage  image.Image
-	prevDupeImage image.Image
-	currDupeImage image.Image
-	nextDupeImage image.Image
-)
+	reviewButtons map[review.State]*widget.Button
+	keepButtons   map[review.Keep]*widget.Button
+	refMetadata   *metadataPanel
+	dupeMetadata  *metadataPanel
+	compare       *compareView
+	filter        *filterBar
+	// captureTime finds pairs taken around the same time as a decided pair.
+	captureTime review.TimeFunc
 
-func showGui() {
-	results = readResultsFile(results_path)
-	p := results.ImagePairs[results.StartIdx]
+	syncingScroll bool
+	zoom          float32
+}
 
-	w := a.NewWindow("dedugo")
-	w.CenterOnScreen()
+func showGui() {
+	s, err := newReviewSession()
+	if err == session.ErrNoPairs {
+		fmt.Println("There are no image pairs to review.")
+		return
+	} else if err != nil {
+		log.Fatal("Error reading results file.", err)
+	}
+	newReviewGui(app.New(), s).window.ShowAndRun()
+}
 
-	initImages()
+// newReviewGui builds the review window for a session.
+func newReviewGui(a fyne.App, s *session.Session) *reviewGui {
+	g := &reviewGui{app: a, session: s, captureTime: captureTimes()}
+	// Zoomed images are decoded at full resolution, and those of the pairs
+	// around the current one prefetched within the display images' limits
+	s.SetFullLoader(scan.Open)
+	g.window = a.NewWindow("dedugo")
+	g.window.Resize(fyne.NewSize(2*imgWidth, imgHeight+200))
+	g.window.CenterOnScreen()
 
 	refLabel := widget.NewLabelWithStyle("Reference Image", textCentered, bold)
-	refImagePath = widget.NewLabelWithStyle(p.RefImage, textCentered, monospaced)
-	refImage = canvas.NewImageFromImage(currRefImage)
-	refImage.SetMinSize(fyne.NewSize(imgWidth, imgHeight))
-	refImage.FillMode = canvas.ImageFillContain
+	g.refImagePath = widget.NewLabelWithStyle("", textCentered, monospaced)
+	g.refImage = canvas.NewImageFromImage(nil)
+	g.refImage.FillMode = canvas.ImageFillContain
+	g.refScroll = container.NewScroll(newPanImage(g.refImage, g.panImages(&g.refScroll)))
 
 	dupeLabel := widget.NewLabelWithStyle("Duplicate Image", textCentered, bold)
-	dupeImagePath = widget.NewLabelWithStyle(p.DupeImage, textCentered, monospaced)
-	dupeImage = canvas.NewImageFromImage(currDupeImage)
-	dupeImage.SetMinSize(fyne.NewSize(imgWidth, imgHeight))
-	dupeImage.FillMode = canvas.ImageFillContain
+	g.dupeImagePath = widget.NewLabelWithStyle("", textCentered, monospaced)
+	g.dupeImage = canvas.NewImageFromImage(nil)
+	g.dupeImage.FillMode = canvas.ImageFillContain
+	g.dupeScroll = container.NewScroll(newPanImage(g.dupeImage, g.panImages(&g.dupeScroll)))
+
+	g.refScroll.OnScrolled = g.syncScroll(&g.refScroll, &g.dupeScroll)
+	g.dupeScroll.OnScrolled = g.syncScroll(&g.dupeScroll, &g.refScroll)
+
+	g.refMetadata = newMetadataPanel()
+	g.dupeMetadata = newMetadataPanel()
 
-	refImgCont := container.NewVBox(refLabel, refImagePath, refImage)
-	dupeImgCont := container.NewVBox(dupeLabel, dupeImagePath, dupeImage)
-	imgCont := container.NewHBox(refImgCont, dupeImgCont)
+	refImgCont := container.NewBorder(container.NewVBox(refLabel, g.refImagePath), g.refMetadata.content, nil, nil, g.refScroll)
+	dupeImgCont := container.NewBorder(container.NewVBox(dupeLabel, g.dupeImagePath), g.dupeMetadata.content, nil, nil, g.dupeScroll)
+	sideBySide := container.NewGridWithColumns(2, refImgCont, dupeImgCont)
+	g.compare = newCompareView(g, sideBySide)
+	imgCont := container.NewMax(sideBySide, g.compare.content)
 
-	confirmButton := widget.NewButton("Confirm Duplicate", confirmDuplicate())
-	nextButton = widget.NewButton("Next", nextPair())
-	prevButton = widget.NewButton("Previous", prevPair())
+	g.statusLabel = widget.NewLabelWithStyle("", textCentered, bold)
 
-	buttonCont := container.NewHBox(layout.NewSpacer(), prevButton, nextButton, confirmButton, layout.NewSpacer())
-	mainCont := container.NewVBox(imgCont, buttonCont)
+	g.nextButton = widget.NewButton("Next", g.nextPair)
+	g.prevButton = widget.NewButton("Previous", g.prevPair)
+	g.reviewButtons = map[review.State]*widget.Button{
+		review.Duplicate:    widget.NewButton("Duplicate", g.markPair(review.Duplicate)),
+		review.NotDuplicate: widget.NewButton("Not Duplicate", g.markPair(review.NotDuplicate)),
+		review.Skipped:      widget.NewButton("Skip", g.markPair(review.Skipped)),
+	}
+	g.keepButtons = map[review.Keep]*widget.Button{
+		review.KeepDuplicate: widget.NewButton("Keep Right", g.keepPair(review.KeepDuplicate)),
+		review.KeepBoth:      widget.NewButton("Keep Both", g.keepPair(review.KeepBoth)),
+	}
 
-	w.SetContent(mainCont)
+	buttonCont := container.NewHBox(
+		layout.NewSpacer(),
+		g.prevButton,
+		g.nextButton,
+		g.reviewButtons[review.Duplicate],
+		g.keepButtons[review.KeepDuplicate],
+		g.keepButtons[review.KeepBoth],
+		g.reviewButtons[review.NotDuplicate],
+		g.reviewButtons[review.Skipped],
+		widget.NewSeparator(),
+		widget.NewButton("Fit", func() { g.setZoom(0) }),
+		widget.NewButton("100%", func() { g.setZoom(1) }),
+		widget.NewButton("-", func() { g.zoomBy(1 / zoomStep) }),
+		widget.NewButton("+", func() { g.zoomBy(zoomStep) }),
+		widget.NewSeparator(),
+		g.compare.viewSelect,
+		widget.NewButton("Similar Images", g.showCluster),
+		widget.NewButton("Apply to Similar", g.confirmSimilar),
+		layout.NewSpacer(),
+	)
+	helpLabel := widget.NewLabelWithStyle(guiHelp, textCentered, fyne.TextStyle{Italic: true})
+	g.filter = newFilterBar(g)
+	mainCont := container.NewBorder(g.filter.content, container.NewVBox(g.statusLabel, buttonCont, g.newActionBar(), helpLabel), nil, nil, imgCont)
 
-	w.ShowAndRun()
+	g.window.Canvas().SetOnTypedKey(g.handleKey)
+	// Save a filter still waiting for the slider to stop moving
+	g.window.SetOnClosed(g.filter.flush)
+	g.window.SetContent(mainCont)
+	g.refresh()
+	return g
 }
 
-func confirmDuplicate() func() {
-	return func() {
-		results.ImagePairs[results.StartIdx].Confirmed = true
-		go WriteResultsFile(results, results_path)
-		nextPair()
+// handleKey runs the action bound to a key press.
+func (g *reviewGui) handleKey(e *fyne.KeyEvent) {
+	switch e.Name {
+	case fyne.KeyLeft:
+		g.prevPair()
+	case fyne.KeyRight:
+		g.nextPair()
+	case fyne.KeyY:
+		g.markPair(review.Duplicate)()
+	case fyne.KeyR:
+		g.keepPair(review.KeepDuplicate)()
+	case fyne.KeyB:
+		g.keepPair(review.KeepBoth)()
+	case fyne.KeyN:
+		g.markPair(review.NotDuplicate)()
+	case fyne.KeyS:
+		g.markPair(review.Skipped)()
+	case fyne.KeyX:
+		g.unmarkPair()
+	case fyne.KeyU:
+		g.undo()
+	case fyne.KeyF, fyne.Key0:
+		g.setZoom(0)
+	case fyne.Key1:
+		g.setZoom(1)
+	case fyne.KeyEqual:
+		g.zoomBy(zoomStep)
+	case fyne.KeyMinus:
+		g.zoomBy(1 / zoomStep)
+	case fyne.KeyV:
+		g.compare.cycle()
+	case fyne.KeyC:
+		g.showCluster()
+	case fyne.KeyA:
+		g.confirmSimilar()
+	case fyne.KeyQ, fyne.KeyEscape:
+		g.filter.flush()
+		g.app.Quit()
 	}
 }
 
-func nextPair() func() {
-	return func() {
-		if results.StartIdx <= len(results.ImagePairs) {
-			results.StartIdx++
-			go WriteResultsFile(results, results_path)
-			refreshImages(Next)
+// setZoom scales both images to z times their pixel size. A zoom of 0 fits
+// the images to the window. Zoomed images are shown at full resolution rather
+// than at the display size, so that 100% shows every pixel.
+func (g *reviewGui) setZoom(z float32) {
+	fit := g.zoom == 0
+	g.zoom = z
+	if fit != (z == 0) {
+		if z == 0 {
+			// Stop holding on to full resolution images while fit
+			g.session.DropFullImages()
+		}
+		g.showImages()
+	} else {
+		g.applyZoom()
+	}
+}
+
+// zoomBy multiplies the current zoom. When the images are fit to the window,
+// zooming starts from the scale they are currently shown at.
+func (g *reviewGui) zoomBy(factor float32) {
+	z := g.zoom
+	if z == 0 {
+		// The compare views show images the size of the reference image
+		full := g.session.FullImages()
+		if g.compare.mode == sideBySideView {
+			z = g.fitScale(full.Ref, g.refScroll)
 		} else {
-			nextButton.Disable()
+			z = g.fitScale(full.Ref, g.compare.scroll)
+		}
+	}
+	z *= factor
+	if z < minZoom {
+		z = minZoom
+	} else if z > maxZoom {
+		z = maxZoom
+	}
+	g.setZoom(z)
+}
+
+// applyZoom sets the minimum size of the displayed images so that their
+// scroll containers show them at the current zoom.
+func (g *reviewGui) applyZoom() {
+	for _, v := range []struct {
+		img    *canvas.Image
+		scroll *container.Scroll
+	}{{g.refImage, g.refScroll}, {g.dupeImage, g.dupeScroll}, {g.compare.base, g.compare.scroll}} {
+		if g.zoom == 0 || v.img.Image == nil {
+			v.img.SetMinSize(fyne.NewSize(1, 1))
+		} else {
+			b := v.img.Image.Bounds()
+			scale := g.window.Canvas().Scale()
+			v.img.SetMinSize(fyne.NewSize(float32(b.Dx())*g.zoom/scale, float32(b.Dy())*g.zoom/scale))
+		}
+		v.scroll.Refresh()
+	}
+}
+
+// fitScale returns the zoom at which an image fit to its scroll container is
+// currently shown.
+func (g *reviewGui) fitScale(img image.Image, scroll *container.Scroll) float32 {
+	if img == nil {
+		return 1
+	}
+	b := img.Bounds()
+	size := scroll.Size()
+	scale := g.window.Canvas().Scale()
+	z := size.Width * scale / float32(b.Dx())
+	if zh := size.Height * scale / float32(b.Dy()); zh < z {
+		z = zh
+	}
+	return z
+}
+
+// panImages returns a drag handler which pans the given scroll container. The
+// other image follows through syncScroll.
+func (g *reviewGui) panImages(scroll **container.Scroll) func(*fyne.DragEvent) {
+	return func(e *fyne.DragEvent) {
+		s := *scroll
+		s.Offset = fyne.NewPos(s.Offset.X-e.Dragged.DX, s.Offset.Y-e.Dragged.DY)
+		s.Refresh()
+	}
+}
+
+// syncScroll returns a scroll handler which moves the other scroll container
+// to the same relative position so that both images show the same area.
+func (g *reviewGui) syncScroll(from, to **container.Scroll) func(fyne.Position) {
+	return func(offset fyne.Position) {
+		if g.syncingScroll {
+			return
 		}
+		g.syncingScroll = true
+		defer func() { g.syncingScroll = false }()
+
+		f, t := *from, *to
+		t.Offset = fyne.NewPos(
+			scrollRatio(offset.X, f.Content.Size().Width, f.Size().Width)*(t.Content.Size().Width-t.Size().Width),
+			scrollRatio(offset.Y, f.Content.Size().Height, f.Size().Height)*(t.Content.Size().Height-t.Size().Height),
+		)
+		t.Refresh()
+	}
+}
+
+func scrollRatio(offset, content, view float32) float32 {
+	if content <= view {
+		return 0
 	}
+	return offset / (content - view)
 }
 
-func prevPair() func() {
+// markPair returns a callback which records state for the current pair and
+// moves on to the next one. If the pair already has that state, it is unmarked
+// instead. Duplicates keep the reference image.
+func (g *reviewGui) markPair(state review.State) func() {
 	return func() {
-		results.StartIdx--
-		go WriteResultsFile(results, results_path)
-
-		refreshImages(Previous)
-	}
-}
-
-func refreshImages(direction Direction) {
-	if direction == Next {
-		prevRefImage = currRefImage
-		prevDupeImage = currDupeImage
-		currRefImage = nextRefImage
-		currDupeImage = nextDupeImage
-		nextRefImage, nextDupeImage = loadImage(Next)
-	} else if direction == Previous {
-		nextRefImage = currRefImage
-		nextDupeImage = currDupeImage
-		currRefImage = prevRefImage
-		currDupeImage = prevDupeImage
-		prevRefImage, prevDupeImage = loadImage(Previous)
-	}
-	refImage.Image = currRefImage
-	refImagePath.Text = results.ImagePairs[results.StartIdx].RefImage
-	dupeImage.Image = currDupeImage
-	dupeImagePath.Text = results.ImagePairs[results.StartIdx].DupeImage
-	go refImage.Refresh()
-	go refImagePath.Refresh()
-	go dupeImage.Refresh()
-	go dupeImagePath.Refresh()
-}
-
-func initImages() {
-	i := results.StartIdx
-	if i == 0 {
-		prevRefImage, prevDupeImage = nil, nil
-		currRefImage, currDupeImage = loadImage(Current)
-		nextRefImage, nextDupeImage = loadImage(Next)
-	} else if i == len(results.ImagePairs) {
-		prevRefImage, prevDupeImage = loadImage(Previous)
-		currRefImage, currDupeImage = loadImage(Current)
-		nextRefImage, nextDupeImage = nil, nil
-	} else {
-		prevRefImage, prevDupeImage = loadImage(Previous)
-		currRefImage, currDupeImage = loadImage(Current)
-		nextRefImage, nextDupeImage = loadImage(Next)
+		if p := g.session.Pair(); p.Review == state && p.Keep == review.KeepReference {
+			g.unmarkPair()
+			return
+		}
+		g.check(g.session.Decide(state))
+		g.refresh()
 	}
 }
 
-func loadImage(direction Direction) (image.Image, image.Image) {
-	var i int
+// keepPair returns a callback which marks the current pair as a duplicate
+// which keeps the given image and moves on to the next one. If the pair
+// already keeps that image, it is unmarked instead.
+func (g *reviewGui) keepPair(keep review.Keep) func() {
+	return func() {
+		if p := g.session.Pair(); p.Review == review.Duplicate && p.Keep == keep {
+			g.unmarkPair()
+			return
+		}
+		g.check(g.session.Keep(keep))
+		g.refresh()
+	}
+}
+
+// unmarkPair clears the review decision for the current pair.
+func (g *reviewGui) unmarkPair() {
+	g.check(g.session.Unmark())
+	g.refresh()
+}
+
+// undo reverts the most recent decision.
+func (g *reviewGui) undo() {
+	_, err := g.session.Undo()
+	g.check(err)
+	g.refresh()
+}
+
+func (g *reviewGui) nextPair() {
+	moved, err := g.session.Next()
+	g.check(err)
+	if moved {
+		g.refresh()
+	}
+}
 
-	switch direction {
-	case Next:
-		i = results.StartIdx + 1
-	case Previous:
-		i = results.StartIdx - 1
-	default:
-		i = results.StartIdx
+func (g *reviewGui) prevPair() {
+	moved, err := g.session.Previous()
+	g.check(err)
+	if moved {
+		g.refresh()
 	}
+}
 
-	refImageImage, err := openAndDecodeImage(results.ImagePairs[i].RefImage)
+// check shows an error from saving the results.
+func (g *reviewGui) check(err error) {
 	if err != nil {
-		log.Fatal("Reference", err)
+		dialog.ShowError(fmt.Errorf("Error writing results file. %w", err), g.window)
 	}
-	dupeImageImage, err := openAndDecodeImage(results.ImagePairs[i].DupeImage)
+}
+
+// refresh shows the session's current pair.
+func (g *reviewGui) refresh() {
+	g.showImages()
+	g.refreshMetadata()
+	g.filter.refreshCount()
+	g.refreshStatus()
+}
+
+// showImages shows the current pair's images, scaled to the display size
+// when they are fit to the window and at full resolution when zoomed.
+func (g *reviewGui) showImages() {
+	p := g.session.Pair()
+	g.images = g.session.Images()
+	if g.zoom != 0 {
+		g.images = g.session.FullImages()
+	}
+	g.refImage.Image = g.images.Ref
+	g.dupeImage.Image = g.images.Dupe
+	g.refImagePath.SetText(imageLabel(p.RefImage, g.images.RefErr))
+	g.dupeImagePath.SetText(imageLabel(p.DupeImage, g.images.DupeErr))
+	g.refImage.Refresh()
+	g.dupeImage.Refresh()
+	g.compare.update()
+}
+
+
+// imageLabel returns the path of an image along with any error loading it.
+func imageLabel(path string, err error) string {
 	if err != nil {
-		log.Fatal("Duplicate", err)
+		return fmt.Sprintf("%s (%s)", path, err)
+	}
+	return path
+}
+
+// refreshStatus shows the review state of the current pair and highlights the
+// button for that state.
+func (g *reviewGui) refreshStatus() {
+	p := g.session.Pair()
+	status := p.Decision()
+	if p.Review != review.Unreviewed && p.Reviewer != "" {
+		status = fmt.Sprintf("%s, by %s on %s", status, p.Reviewer, p.ReviewedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if p.Review == review.Duplicate && p.Removed() == p.RefImage {
+		status += ". Warning: the reference image will be removed"
+	}
+	details := fmt.Sprintf("Confidence %d, distance %.0f", p.Confidence, p.Distance)
+	if p.Burst {
+		details += ", burst"
+	}
+	g.statusLabel.SetText(fmt.Sprintf("Pair %d of %d (%s). %s. Progress: %s", g.session.Position()+1, g.session.Len(), details, status, g.session.Progress()))
+	if g.session.AtStart() {
+		g.prevButton.Disable()
+	} else {
+		g.prevButton.Enable()
+	}
+	if g.session.AtEnd() {
+		g.nextButton.Disable()
+	} else {
+		g.nextButton.Enable()
+	}
+	for keep, b := range g.keepButtons {
+		if p.Review == review.Duplicate && keep == p.Keep {
+			b.Importance = widget.HighImportance
+		} else {
+			b.Importance = widget.MediumImportance
+		}
+		b.Refresh()
+	}
+	for state, b := range g.reviewButtons {
+		if state == p.Review && (state != review.Duplicate || p.Keep == review.KeepReference) {
+			b.Importance = widget.HighImportance
+		} else {
+			b.Importance = widget.MediumImportance
+		}
+		b.Refresh()
+	}
+}
+
+// thumbnails caches images decoded at display resolution. The zero cache only
+// scales images.
+var thumbnails thumbcache.Cache
+
+// loadDisplayImage decodes the image at path with its longest edge at most
+// displaySize pixels.
+func loadDisplayImage(path string) (image.Image, error) {
+	return loadThumbnail(path, displaySize)
+}
+
+// loadThumbnail decodes the image at path with its longest edge at most size
+// pixels, reusing a cached thumbnail when there is one. A size of 0 decodes
+// the image at full resolution.
+func loadThumbnail(path string, size int) (image.Image, error) {
+	if size <= 0 {
+		return openAndDecodeImage(path)
 	}
-	return refImageImage, dupeImageImage
+	return thumbnails.Load(path, size)
 }
 
 func openAndDecodeImage(path string) (image.Image, error) {
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/journal"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/session"
+	"github.com/mike-lloyd03/dedugo/thumbcache"
+)
+
+// writeTestResults writes a results file with n pairs of small images to a
+// temporary directory and returns its path.
+func writeTestResults(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	results := review.Results{RefDir: dir, EvalDir: dir}
+	for i := 0; i < n; i++ {
+		pair := review.Pair{Confidence: 5 - i%5, Review: review.Unreviewed}
+		for j, path := range []*string{&pair.RefImage, &pair.DupeImage} {
+			*path = filepath.Join(dir, string(rune('a'+i))+string(rune('0'+j))+".png")
+			img := image.NewRGBA(image.Rect(0, 0, 8+i, 6))
+			for k := range img.Pix {
+				img.Pix[k] = uint8(40 * (i + j))
+			}
+			img.Set(0, 0, color.White)
+			f, err := os.Create(*path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			png.Encode(f, img)
+			f.Close()
+		}
+		results.ImagePairs = append(results.ImagePairs, pair)
+	}
+	path := filepath.Join(dir, "results.yaml")
+	if err := review.Write(results, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestGui(t *testing.T, path string) *reviewGui {
+	t.Helper()
+	// Keep the trash and journal of actions out of the user's home
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s, err := session.New(session.FileStore(path), openAndDecodeImage, "obi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newReviewGui(test.NewApp(), s)
+}
+
+func typeKey(g *reviewGui, name fyne.KeyName) {
+	g.window.Canvas().OnTypedKey()(&fyne.KeyEvent{Name: name})
+}
+
+func TestGuiNavigation(t *testing.T) {
+	g := newTestGui(t, writeTestResults(t, 3))
+	if !g.prevButton.Disabled() || g.nextButton.Disabled() {
+		t.Error("only the next button should be enabled on the first pair")
+	}
+	if g.refImage.Image == nil || g.dupeImage.Image == nil {
+		t.Fatal("the first pair's images should be shown")
+	}
+
+	// Moving past the end used to panic while loading the next images
+	for i := 0; i < 5; i++ {
+		test.Tap(g.nextButton)
+		typeKey(g, fyne.KeyRight)
+	}
+	if g.session.Index() != 2 || !g.nextButton.Disabled() {
+		t.Error("expected to stop at the last pair. got", g.session.Index())
+	}
+	if g.refImage.Image.Bounds().Dx() != 10 {
+		t.Error("the last pair's images should be shown")
+	}
+
+	typeKey(g, fyne.KeyLeft)
+	if g.session.Index() != 1 {
+		t.Error("left should move to the previous pair. got", g.session.Index())
+	}
+}
+
+func TestGuiDecisions(t *testing.T) {
+	path := writeTestResults(t, 3)
+	g := newTestGui(t, path)
+
+	test.Tap(g.reviewButtons[review.Duplicate])
+	typeKey(g, fyne.KeyN)
+	if g.session.Index() != 2 {
+		t.Fatal("each decision should move to the next pair. got", g.session.Index())
+	}
+	typeKey(g, fyne.KeyS)
+	typeKey(g, fyne.KeyS)
+	if g.session.Pair().Review != review.Unreviewed {
+		t.Error("marking a pair with its current state should unmark it")
+	}
+	typeKey(g, fyne.KeyU)
+	if g.session.Pair().Review != review.Skipped {
+		t.Error("undo should restore the last decision")
+	}
+
+	results, err := review.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []review.State{review.Duplicate, review.NotDuplicate, review.Skipped}
+	for i, p := range results.ImagePairs {
+		if p.Review != want[i] || p.Reviewer != "obi" {
+			t.Errorf("pair %d: expected %s by obi. got %s by %s", i, want[i], p.Review, p.Reviewer)
+		}
+	}
+	if g.statusLabel.Text == "" || g.reviewButtons[review.Skipped].Importance == g.reviewButtons[review.Duplicate].Importance {
+		t.Error("the status and the active decision should be shown")
+	}
+}
+
+func TestGuiKeep(t *testing.T) {
+	g := newTestGui(t, writeTestResults(t, 3))
+	pairs := g.session.Results().ImagePairs
+
+	typeKey(g, fyne.KeyR)
+	typeKey(g, fyne.KeyLeft)
+	if p := g.session.Pair(); p.Review != review.Duplicate || p.Keep != review.KeepDuplicate {
+		t.Errorf("r should keep the duplicate image: %+v", p)
+	}
+	if !strings.Contains(g.statusLabel.Text, "reference image will be removed") {
+		t.Error("keeping the duplicate image should warn that the reference is removed. got", g.statusLabel.Text)
+	}
+	if g.keepButtons[review.KeepDuplicate].Importance != widget.HighImportance || g.reviewButtons[review.Duplicate].Importance == widget.HighImportance {
+		t.Error("only the keep right button should be highlighted")
+	}
+	typeKey(g, fyne.KeyY)
+	typeKey(g, fyne.KeyLeft)
+	if p := g.session.Pair(); p.Review != review.Duplicate || p.Keep != review.KeepReference {
+		t.Errorf("y should switch to keeping the reference image: %+v", p)
+	}
+
+	typeKey(g, fyne.KeyR)
+	typeKey(g, fyne.KeyLeft)
+	g.applyToCurrent(cleanup.NewPlan(g.session.Results(), []int{0}), deleteAction())
+	if _, err := os.Stat(pairs[0].RefImage); !os.IsNotExist(err) {
+		t.Error("the reference image should be deleted when the duplicate is kept")
+	}
+	if _, err := os.Stat(pairs[0].DupeImage); err != nil {
+		t.Error("the duplicate image should be kept.", err)
+	}
+	if p := g.session.Results().ImagePairs[0]; p.Keep != review.KeepDuplicate {
+		t.Errorf("deleting should not forget which image was kept: %+v", p)
+	}
+
+	typeKey(g, fyne.KeyB)
+	typeKey(g, fyne.KeyLeft)
+	typeKey(g, fyne.KeyB)
+	if p := g.session.Pair(); p.Review != review.Unreviewed {
+		t.Errorf("pressing b again should unmark the pair: %+v", p)
+	}
+}
+
+func TestGuiFilter(t *testing.T) {
+	g := newTestGui(t, writeTestResults(t, 4))
+	g.filter.sortSelect.SetSelected(sortName(review.SortConfidence))
+	g.filter.minConfSelect.SetSelected("4")
+	if g.session.Len() != 2 {
+		t.Error("expected 2 pairs with a confidence of at least 4. got", g.session.Len())
+	}
+	g.filter.clear()
+	if g.session.Len() != 4 || g.session.Filter().Sort != review.SortNone {
+		t.Error("clearing the filter should show every pair")
+	}
+}
+
+func TestGuiDistanceSlider(t *testing.T) {
+	path := writeTestResults(t, 4)
+	results, _ := review.Read(path)
+	for i := range results.ImagePairs {
+		results.ImagePairs[i].Distance = float32(1000*i + 450)
+	}
+	review.Write(results, path)
+	g := newTestGui(t, path)
+	if g.filter.distance.Max != 3500 || g.filter.distance.Value != 3500 {
+		t.Fatalf("the slider should start at the largest distance, rounded up. got %v of %v", g.filter.distance.Value, g.filter.distance.Max)
+	}
+
+	typeKey(g, fyne.KeyN)
+	g.filter.distance.SetValue(1500)
+	if g.session.Len() != 2 || g.session.Filter().MaxDistance != 1500 {
+		t.Errorf("expected 2 pairs within 1500. got %d", g.session.Len())
+	}
+	if want := "2 of 4 pairs shown up to distance 1500: 0 confirmed, 1 not duplicates"; g.filter.distanceLabel.Text != want {
+		t.Errorf("expected %q. got %q", want, g.filter.distanceLabel.Text)
+	}
+
+	if saved, _ := review.Read(path); saved.Filter.MaxDistance != 0 {
+		t.Error("the filter should not be saved while the slider is moving")
+	}
+	g.filter.flush()
+	if saved, _ := review.Read(path); saved.Filter.MaxDistance != 1500 {
+		t.Error("the filter should be saved once the slider stops. got", saved.Filter.MaxDistance)
+	}
+
+	// A distance of 0 would mean no limit
+	g.filter.distance.SetValue(0)
+	if g.session.Len() != 1 || g.session.Filter().MaxDistance != distanceStep {
+		t.Errorf("the slider should stop at one step. got %d pairs within %v", g.session.Len(), g.session.Filter().MaxDistance)
+	}
+
+	g.filter.distance.SetValue(g.filter.distance.Max)
+	if g.session.Len() != 4 || g.session.Filter().MaxDistance != 0 {
+		t.Error("the largest distance should not limit the queue")
+	}
+	g.filter.flush()
+}
+
+// TestGuiSliderSave makes decisions while the slider's filter is being saved.
+// Run it with -race.
+func TestGuiSliderSave(t *testing.T) {
+	path := writeTestResults(t, 4)
+	results, _ := review.Read(path)
+	for i := range results.ImagePairs {
+		results.ImagePairs[i].Distance = float32(1000*i + 450)
+	}
+	review.Write(results, path)
+	g := newTestGui(t, path)
+
+	defer func(delay time.Duration) { filterSaveDelay = delay }(filterSaveDelay)
+	filterSaveDelay = time.Microsecond
+	for i := 0; i < 20; i++ {
+		g.filter.distance.SetValue(float64(1000 + 100*(i%10)))
+		typeKey(g, fyne.KeyN)
+		typeKey(g, fyne.KeyU)
+	}
+	typeKey(g, fyne.KeyY)
+	time.Sleep(10 * time.Millisecond)
+	saved, _ := review.Read(path)
+	if saved.ImagePairs[0].Review != review.Duplicate || saved.Filter.MaxDistance != 1900 {
+		t.Errorf("the last decision and filter should be saved. got %s within %v", saved.ImagePairs[0].Review, saved.Filter.MaxDistance)
+	}
+
+	// Closing the window saves a filter before the slider has settled
+	filterSaveDelay = time.Hour
+	g.filter.distance.SetValue(2500)
+	g.window.Close()
+	if saved, _ := review.Read(path); saved.Filter.MaxDistance != 2500 {
+		t.Error("closing the window should save the filter. got", saved.Filter.MaxDistance)
+	}
+}
+
+func TestGuiViews(t *testing.T) {
+	g := newTestGui(t, writeTestResults(t, 2))
+	for mode := range viewNames {
+		g.compare.viewSelect.SetSelectedIndex(mode)
+		if (viewMode(mode) == sideBySideView) == g.compare.content.Visible() {
+			t.Errorf("%s: the compare view should only be shown outside side by side mode", viewNames[mode])
+		}
+	}
+	if g.compare.base.Image == nil || g.compare.base.Image.Bounds().Dx() != 8 {
+		t.Error("the difference view should show a heatmap the size of the reference image")
+	}
+	typeKey(g, fyne.KeyV)
+	if g.compare.mode != sideBySideView {
+		t.Error("v should cycle back to the side by side view")
+	}
+
+	typeKey(g, fyne.Key1)
+	if g.refImage.MinSize().Width == 1 {
+		t.Error("actual size should size the image to its pixels")
+	}
+	typeKey(g, fyne.KeyF)
+	if g.zoom != 0 {
+		t.Error("f should fit the images to the window")
+	}
+}
+
+func TestGuiZoomFullResolution(t *testing.T) {
+	path := writeTestResults(t, 2)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	// Other tests may have set up the shared cache, which only stores
+	// standard sizes
+	defer func(c thumbcache.Cache) { thumbnails = c }(thumbnails)
+	thumbnails = thumbcache.Cache{}
+	thumbnail := func(path string) (image.Image, error) { return loadThumbnail(path, 4) }
+	s, err := session.New(session.FileStore(path), thumbnail, "obi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newReviewGui(test.NewApp(), s)
+	if g.refImage.Image.Bounds().Dx() != 4 {
+		t.Fatal("fit to window should show the display image")
+	}
+
+	typeKey(g, fyne.Key1)
+	if g.refImage.Image.Bounds().Dx() != 8 || g.dupeImage.Image.Bounds().Dx() != 8 {
+		t.Error("actual size should show the images at full resolution")
+	}
+	typeKey(g, fyne.KeyRight)
+	if g.refImage.Image.Bounds().Dx() != 9 {
+		t.Error("the next pair should be shown at full resolution while zoomed")
+	}
+	typeKey(g, fyne.KeyF)
+	if g.refImage.Image.Bounds().Dx() != 4 {
+		t.Error("fit to window should go back to the display image")
+	}
+	typeKey(g, fyne.KeyEqual)
+	if g.refImage.Image.Bounds().Dx() != 9 {
+		t.Error("zooming in from fit should show the images at full resolution")
+	}
+}
+
+func TestGuiMissingImage(t *testing.T) {
+	path := writeTestResults(t, 2)
+	results, _ := review.Read(path)
+	os.Remove(results.ImagePairs[1].DupeImage)
+
+	g := newTestGui(t, path)
+	typeKey(g, fyne.KeyRight)
+	if g.dupeImage.Image != nil || g.dupeImagePath.Text == results.ImagePairs[1].DupeImage {
+		t.Error("a missing image should be reported instead of shown")
+	}
+}
+
+func TestGuiActions(t *testing.T) {
+	path := writeTestResults(t, 3)
+	g := newTestGui(t, path)
+	pairs := g.session.Results().ImagePairs
+
+	g.applyToCurrent(cleanup.NewPlan(g.session.Results(), []int{0}), deleteAction())
+	if _, err := os.Stat(pairs[0].DupeImage); !os.IsNotExist(err) {
+		t.Error("delete now should delete the duplicate image")
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("XDG_DATA_HOME"), "Trash", "files", filepath.Base(pairs[0].DupeImage))); err != nil {
+		t.Error("delete now should move the duplicate image to the trash.", err)
+	}
+	if _, err := os.Stat(pairs[0].RefImage); err != nil {
+		t.Error("delete now should keep the reference image.", err)
+	}
+	if g.session.Results().ImagePairs[0].Review != review.Duplicate || g.session.Index() != 1 {
+		t.Error("a deleted pair should be marked as a duplicate and the next pair shown")
+	}
+
+	typeKey(g, fyne.KeyY)
+	quarantineDir = filepath.Join(t.TempDir(), "quarantine")
+	defer func() { quarantineDir = "" }()
+	results := g.session.Results()
+	plan := cleanup.NewPlan(results, cleanup.Confirmed(results, false))
+	if len(plan.Files) != 1 || len(plan.Skipped) != 1 {
+		t.Fatalf("expected one pair to apply and the deleted one skipped. got %+v", plan)
+	}
+	g.applyAll(plan, quarantineAction())
+	if _, err := os.Stat(filepath.Join(quarantineDir, filepath.Base(pairs[1].DupeImage))); err != nil {
+		t.Error("apply all should move confirmed duplicates to the quarantine directory.", err)
+	}
+
+	j, _ := journal.Default()
+	runs, err := j.List()
+	if err != nil || len(runs) != 2 || runs[0].Operation != journal.Trash || runs[1].Operation != journal.Move {
+		t.Fatalf("expected both actions to be recorded in the journal. got %+v %v", runs, err)
+	}
+	if e := runs[1].Entries[0]; e.Source != pairs[1].DupeImage || e.Hash == "" {
+		t.Errorf("unexpected journal entry %+v", e)
+	}
+	if errs := runs[1].Undo(); errs[0] != nil {
+		t.Error("the quarantined image should be restored.", errs[0])
+	}
+	if _, err := os.Stat(pairs[1].DupeImage); err != nil {
+		t.Error("the quarantined image should be back in place.", err)
+	}
+}
+
+func TestGuiApplySimilar(t *testing.T) {
+	path := writeTestResults(t, 3)
+	results, _ := review.Read(path)
+	// The last pair shares its reference image with the first
+	results.ImagePairs[2].RefImage = results.ImagePairs[0].RefImage
+	review.Write(results, path)
+	g := newTestGui(t, path)
+
+	if _, _, ok := similarToLast(g.session, g.captureTime); ok {
+		t.Error("nothing should be similar before a decision is made")
+	}
+	typeKey(g, fyne.KeyN)
+	index, similar, ok := similarToLast(g.session, g.captureTime)
+	if !ok || index != 0 || len(similar) != 1 || similar[0] != 2 {
+		t.Fatalf("expected the last pair to be similar to the first. got %d %v", index, similar)
+	}
+	typeKey(g, fyne.KeyA)
+	g.applySimilar(index, similar)
+	if p := g.session.Results().ImagePairs[2]; p.Review != review.NotDuplicate || p.Reviewer != "obi" {
+		t.Errorf("the similar pair should be given the same decision: %+v", p)
+	}
+	if p := g.session.Results().ImagePairs[1]; p.Review != review.Unreviewed {
+		t.Errorf("other pairs should be left alone: %+v", p)
+	}
+
+	typeKey(g, fyne.KeyU)
+	if p := g.session.Results().ImagePairs[2]; p.Review != review.Unreviewed {
+		t.Errorf("undo should restore the similar pair: %+v", p)
+	}
+}
@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/session"
+	"github.com/mike-lloyd03/dedugo/termimg"
+	"golang.org/x/term"
+)
+
+const tuiHelp = "y duplicate  r keep right  b keep both  n not duplicate  s skip  x unmark  u undo  a apply to similar  ←/→ move  g jump  q quit"
+
+type tuiSession struct {
+	session  *session.Session
+	protocol termimg.Protocol
+	fd       int
+	in       *bufio.Reader
+	message  string
+	// captureTime finds pairs taken around the same time as a decided pair.
+	captureTime review.TimeFunc
+}
+
+// checkResultsTui reviews the results inside the terminal, drawing both images
+// inline and reading single key decisions.
+func checkResultsTui() {
+	protocol, err := termimg.ParseProtocol(graphics)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		log.Fatal("The terminal review mode must be run in a terminal.")
+	}
+
+	sess, err := newReviewSession()
+	if err == session.ErrNoPairs {
+		fmt.Println("There are no image pairs to review.")
+		return
+	} else if err != nil {
+		log.Fatal("Error reading results file.", err)
+	}
+	s := tuiSession{
+		session:     sess,
+		protocol:    protocol,
+		fd:          fd,
+		in:          bufio.NewReader(os.Stdin),
+		captureTime: captureTimes(),
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer term.Restore(fd, oldState)
+	defer fmt.Print("\x1b[?25h\x1b[2J\x1b[H")
+	fmt.Print("\x1b[?25l")
+
+	for {
+		s.draw()
+		var err error
+		switch readKey(s.in) {
+		case "y":
+			err = s.decide(review.Duplicate)
+		case "r":
+			err = s.keep(review.KeepDuplicate)
+		case "b":
+			err = s.keep(review.KeepBoth)
+		case "n":
+			err = s.decide(review.NotDuplicate)
+		case "s":
+			err = s.decide(review.Skipped)
+		case "x":
+			err = s.decide(review.Unreviewed)
+		case "u":
+			err = s.undo()
+		case "a":
+			err = s.applySimilar()
+		case "right", "l", " ":
+			_, err = s.session.Next()
+			s.message = ""
+		case "left", "h":
+			_, err = s.session.Previous()
+			s.message = ""
+		case "g":
+			err = s.jump()
+		case "q", "ctrl-c":
+			return
+		}
+		if err != nil {
+			s.message = "Error writing results file. " + err.Error()
+		}
+	}
+}
+
+// decide records state for the current pair and moves on to the next one.
+func (s *tuiSession) decide(state review.State) error {
+	pos := s.session.Position()
+	s.message = fmt.Sprintf("Pair %d: %s", pos+1, state.Description())
+	return s.session.Decide(state)
+}
+
+// keep marks the current pair as a duplicate which keeps the given image and
+// moves on to the next one.
+func (s *tuiSession) keep(keep review.Keep) error {
+	pos := s.session.Position()
+	p := s.session.Pair()
+	p.SetKeep(keep, "")
+	s.message = fmt.Sprintf("Pair %d: %s", pos+1, p.Decision())
+	if p.Removed() == p.RefImage {
+		s.message += ". Warning: the reference image will be removed"
+	}
+	return s.session.Keep(keep)
+}
+
+// undo restores the pair changed by the most recent decision and returns to
+// it.
+func (s *tuiSession) undo() error {
+	undone, err := s.session.Undo()
+	if !undone {
+		s.message = "Nothing to undo"
+		return err
+	}
+	s.message = fmt.Sprintf("Undid decision for pair %d", s.session.Position()+1)
+	return err
+}
+
+// applySimilar lists the pending pairs similar to the pair decided most
+// recently and asks whether to give them the same decision.
+func (s *tuiSession) applySimilar() error {
+	index, similar, ok := similarToLast(s.session, s.captureTime)
+	if !ok {
+		s.message = "Decide a pair first"
+		return nil
+	}
+	pairs := s.session.Results().ImagePairs
+	decided := pairs[index]
+	if len(similar) == 0 {
+		s.message = "No pending pairs are similar to " + pairName(decided)
+		return nil
+	}
+
+	var sb strings.Builder
+	if s.protocol == termimg.Kitty {
+		sb.WriteString(termimg.ClearKitty())
+	}
+	sb.WriteString("\x1b[2J\x1b[H")
+	fmt.Fprintf(&sb, "%s was marked \"%s\". These %d pending pairs share images with it, or were taken within %s of it in the same folders:\n\n", pairName(decided), decided.Decision(), len(similar), similarWindow)
+	for _, i := range similar {
+		p := pairs[i]
+		fmt.Fprintf(&sb, "Distance %.0f\n  %s\n  %s\n", p.Distance, p.RefImage, p.DupeImage)
+	}
+	fmt.Fprintf(&sb, "\nApply \"%s\" to all %d pairs? [y/N] ", decided.Decision(), len(similar))
+	fmt.Print(strings.ReplaceAll(sb.String(), "\n", "\r\n"))
+	if readKey(s.in) != "y" {
+		s.message = ""
+		return nil
+	}
+	s.message = fmt.Sprintf("Applied \"%s\" to %d similar pairs", decided.Decision(), len(similar))
+	return s.session.ApplyDecision(index, similar)
+}
+
+// jump prompts for a pair number and moves to it.
+func (s *tuiSession) jump() error {
+	digits := ""
+	for {
+		fmt.Printf("\r\x1b[KJump to pair (1-%d): %s", s.session.Len(), digits)
+		switch key := readKey(s.in); key {
+		case "enter":
+			n, err := strconv.Atoi(digits)
+			if err != nil || n < 1 || n > s.session.Len() {
+				s.message = "No pair " + digits
+				return nil
+			}
+			s.message = ""
+			_, err = s.session.Seek(n - 1)
+			return err
+		case "esc", "ctrl-c":
+			return nil
+		case "backspace":
+			if len(digits) > 0 {
+				digits = digits[:len(digits)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
+				digits += key
+			}
+		}
+	}
+}
+
+func (s *tuiSession) draw() {
+	cols, rows, err := term.GetSize(s.fd)
+	if err != nil || cols <= 0 || rows <= 0 {
+		cols, rows = 80, 24
+	}
+	imgCols := (cols - 2) / 2
+	if imgCols < 1 {
+		imgCols = 1
+	}
+	imgRows := rows - 8
+	if imgRows < 1 {
+		imgRows = 1
+	}
+
+	p := s.session.Pair()
+	images := s.session.Images()
+	refImg, refErr := images.Ref, images.RefErr
+	dupeImg, dupeErr := images.Dupe, images.DupeErr
+
+	var sb strings.Builder
+	if s.protocol == termimg.Kitty {
+		sb.WriteString(termimg.ClearKitty())
+	}
+	sb.WriteString("\x1b[2J\x1b[H")
+	status := p.Decision()
+	if p.Review != review.Unreviewed && p.Reviewer != "" {
+		status += " by " + p.Reviewer
+	}
+	fmt.Fprintf(&sb, "Pair %d of %d  Confidence %d  Distance %.0f  %s\n", s.session.Position()+1, s.session.Len(), p.Confidence, p.Distance, status)
+	fmt.Fprintf(&sb, "%s  (%s)\n", s.session.Progress(), s.session.Filter())
+
+	if s.protocol == termimg.Blocks {
+		left := tuiImageLines(refImg, refErr, imgCols, imgRows)
+		right := tuiImageLines(dupeImg, dupeErr, imgCols, imgRows)
+		for row := 0; row < imgRows; row++ {
+			sb.WriteString(left[row] + "  " + right[row] + "\n")
+		}
+	} else {
+		cellWidth, cellHeight := termimg.CellSize(s.fd)
+		sb.WriteString(strings.Repeat("\n", imgRows))
+		fmt.Fprintf(&sb, "\x1b[%dA\x1b7", imgRows)
+		sb.WriteString(tuiImageSequence(refImg, refErr, s.protocol, imgCols, imgRows, cellWidth, cellHeight))
+		fmt.Fprintf(&sb, "\x1b8\x1b[%dC", imgCols+2)
+		sb.WriteString(tuiImageSequence(dupeImg, dupeErr, s.protocol, imgCols, imgRows, cellWidth, cellHeight))
+		fmt.Fprintf(&sb, "\x1b8\x1b[%dB", imgRows)
+	}
+
+	sb.WriteString("\n")
+	writeColumns(&sb, imgCols, "Reference: "+p.RefImage, "Duplicate: "+p.DupeImage)
+	writeColumns(&sb, imgCols, describeImage(p.RefImage, refImg), describeImage(p.DupeImage, dupeImg))
+	sb.WriteString("\n" + tuiHelp + "\n" + s.message)
+
+	fmt.Print(strings.ReplaceAll(sb.String(), "\n", "\r\n"))
+}
+
+func tuiImageLines(img image.Image, err error, cols, rows int) []string {
+	if err != nil {
+		lines := make([]string, rows)
+		for i := range lines {
+			lines[i] = strings.Repeat(" ", cols)
+		}
+		lines[0] = fitColumn(err.Error(), cols)
+		return lines
+	}
+	return termimg.BlockLines(img, cols, rows)
+}
+
+func tuiImageSequence(img image.Image, err error, p termimg.Protocol, cols, rows, cellWidth, cellHeight int) string {
+	if err != nil {
+		return err.Error()
+	}
+	seq, err := termimg.Encode(img, p, cols, rows, cellWidth, cellHeight)
+	if err != nil {
+		return err.Error()
+	}
+	return seq
+}
+
+// describeImage returns the dimensions and file size of an image.
+func describeImage(path string, img image.Image) string {
+	desc := make([]string, 0, 2)
+	if img != nil {
+		desc = append(desc, fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()))
+	}
+	if info, err := os.Stat(path); err == nil {
+		desc = append(desc, formatBytes(info.Size()))
+	}
+	return strings.Join(desc, "  ")
+}
+
+func writeColumns(sb *strings.Builder, width int, left, right string) {
+	sb.WriteString(fitColumn(left, width) + "  " + fitColumn(right, width) + "\n")
+}
+
+// fitColumn pads or truncates s to exactly width characters, keeping the end
+// of the string since that is the most useful part of a path.
+func fitColumn(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return "…" + string(r[len(r)-width+1:])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// readKey reads a single key press from a terminal in raw mode.
+func readKey(in *bufio.Reader) string {
+	b, err := in.ReadByte()
+	if err != nil {
+		return "q"
+	}
+	switch b {
+	case 3:
+		return "ctrl-c"
+	case '\r', '\n':
+		return "enter"
+	case 127, 8:
+		return "backspace"
+	case 0x1b:
+		if in.Buffered() == 0 {
+			return "esc"
+		}
+		seq := make([]byte, 0, 2)
+		for in.Buffered() > 0 && len(seq) < 2 {
+			c, _ := in.ReadByte()
+			seq = append(seq, c)
+		}
+		switch string(seq) {
+		case "[C":
+			return "right"
+		case "[D":
+			return "left"
+		}
+		return "esc"
+	}
+	return strings.ToLower(string(b))
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+func TestCheckResultsPrompt(t *testing.T) {
+	path := writeTestResults(t, 4)
+	var opened []string
+	defer func(open func(string, string)) { openDuplicates = open }(openDuplicates)
+	openDuplicates = func(ref, dupe string) { opened = append(opened, ref) }
+	defer func(name string) { reviewer = name }(reviewer)
+	reviewer = "obi"
+
+	checkResults(path, strings.NewReader("y\nright\n\nstop\n"))
+	results, err := review.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opened) != 4 || opened[1] != results.ImagePairs[1].RefImage {
+		t.Error("each pair should be opened in the image viewer. got", opened)
+	}
+	for i, want := range []review.Pair{
+		{Review: review.Duplicate, Reviewer: "obi"},
+		{Review: review.Duplicate, Keep: review.KeepDuplicate, Reviewer: "obi"},
+		{Review: review.NotDuplicate, Reviewer: "obi"},
+		{Review: review.Unreviewed},
+	} {
+		p := results.ImagePairs[i]
+		if p.Review != want.Review || p.Keep != want.Keep || p.Reviewer != want.Reviewer {
+			t.Errorf("pair %d: expected %s by %q. got %s by %q", i, want.Decision(), want.Reviewer, p.Decision(), p.Reviewer)
+		}
+	}
+	if results.StartIdx != 3 {
+		t.Error("the pair stopped at should be saved as the position. got", results.StartIdx)
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/thumbcache"
+	"golang.org/x/image/draw"
+)
+
+const (
+	thumbWidth  = 240
+	thumbHeight = 180
+)
+
+// clusterCard is one image in the cluster grid.
+type clusterCard struct {
+	path       string
+	keepButton *widget.Button
+	dupeCheck  *widget.Check
+}
+
+// showCluster opens a window with every image connected to the current pair
+// in a grid. The user picks the image to keep and marks any of the others as
+// duplicates, and the decisions are written to every pair in the cluster.
+func (g *reviewGui) showCluster() {
+	cluster := g.session.Cluster()
+	w := g.app.NewWindow(fmt.Sprintf("dedugo - %d similar images", len(cluster.Images)))
+
+	keeper := cluster.Images[0]
+	cards := make([]*clusterCard, len(cluster.Images))
+	selectKeeper := func(path string) {
+		keeper = path
+		for _, c := range cards {
+			if c.path == keeper {
+				c.keepButton.Importance = widget.HighImportance
+				c.dupeCheck.SetChecked(false)
+				c.dupeCheck.Disable()
+			} else {
+				c.keepButton.Importance = widget.MediumImportance
+				c.dupeCheck.Enable()
+			}
+			c.keepButton.Refresh()
+		}
+	}
+
+	grid := container.NewGridWrap(fyne.NewSize(thumbWidth, thumbHeight+110))
+	for i, path := range cluster.Images {
+		path := path
+		thumb := canvas.NewImageFromImage(nil)
+		thumb.FillMode = canvas.ImageFillContain
+		thumb.SetMinSize(fyne.NewSize(thumbWidth, thumbHeight))
+		go func() {
+			img, err := loadThumbnail(path, int(thumbcache.Large))
+			if err != nil {
+				return
+			}
+			thumb.Image = thumbnail(img, thumbWidth, thumbHeight)
+			thumb.Refresh()
+		}()
+
+		c := &clusterCard{
+			path:       path,
+			keepButton: widget.NewButton("Keep", func() { selectKeeper(path) }),
+			dupeCheck:  widget.NewCheck("Duplicate", nil),
+		}
+		cards[i] = c
+		name := widget.NewLabelWithStyle(filepath.Base(path), textCentered, monospaced)
+		name.Wrapping = fyne.TextTruncate
+		grid.Add(container.NewVBox(thumb, name, container.NewHBox(layout.NewSpacer(), c.keepButton, c.dupeCheck, layout.NewSpacer())))
+	}
+	selectKeeper(keeper)
+
+	selectAll := widget.NewButton("Mark all others as duplicates", func() {
+		for _, c := range cards {
+			if c.path != keeper {
+				c.dupeCheck.SetChecked(true)
+			}
+		}
+	})
+	apply := widget.NewButton("Apply", func() {
+		duplicates := make([]string, 0)
+		for _, c := range cards {
+			if c.dupeCheck.Checked {
+				duplicates = append(duplicates, c.path)
+			}
+		}
+		g.check(g.session.ResolveCluster(keeper, duplicates))
+		w.Close()
+		g.refresh()
+	})
+	apply.Importance = widget.HighImportance
+	cancel := widget.NewButton("Cancel", w.Close)
+
+	info := widget.NewLabel(fmt.Sprintf("%d images connected by %d pairs. Pick the image to keep and mark the copies to remove.", len(cluster.Images), len(cluster.Pairs)))
+	buttons := container.NewHBox(layout.NewSpacer(), selectAll, cancel, apply)
+	w.SetContent(container.NewBorder(info, buttons, nil, nil, container.NewVScroll(grid)))
+	w.Resize(fyne.NewSize(4*thumbWidth+60, 2*(thumbHeight+110)+100))
+	w.Show()
+}
+
+// thumbnail scales img to fit within width by height.
+func thumbnail(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	scale := float64(width) / float64(b.Dx())
+	if s := float64(height) / float64(b.Dy()); s < scale {
+		scale = s
+	}
+	if scale >= 1 {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
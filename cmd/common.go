@@ -1,31 +1,36 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
+	"os/user"
 
-	"gopkg.in/yaml.v2"
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/review"
 )
 
-func readResultsFile(path string) Results {
-	results := Results{}
-	file, err := ioutil.ReadFile(path)
+func readResultsFile(path string) review.Results {
+	results, err := review.Read(path)
 	if err != nil {
 		log.Fatal("Error reading results file.", err)
 	}
-	yaml.Unmarshal(file, &results)
 	return results
 }
 
-func WriteResultsFile(results Results, path string) {
-	data, err := yaml.Marshal(results)
+// currentUser returns the name of the user running dedugo for recording who
+// reviewed a pair.
+func currentUser() string {
+	u, err := user.Current()
 	if err != nil {
-		log.Fatal(err)
+		return ""
 	}
+	return u.Username
+}
 
-	err = ioutil.WriteFile(path, data, 0644)
+func WriteResultsFile(results review.Results, path string) {
+	err := review.Write(results, path)
 	if err != nil {
 		log.Fatal("Error writing results file.", err)
 	}
@@ -43,3 +48,47 @@ func setupLogging(logToFile bool) {
 		log.SetOutput(io.Discard)
 	}
 }
+
+// formatBytes returns a byte count in human readable units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// planSummary describes the files a cleanup plan removes, such as
+// "3 duplicate images (12.4 MiB)".
+func planSummary(plan cleanup.Plan) string {
+	noun := "duplicate images"
+	if len(plan.Files) == 1 {
+		noun = "duplicate image"
+	}
+	return fmt.Sprintf("%d %s (%s)", len(plan.Files), noun, formatBytes(plan.Bytes))
+}
+
+// printSkipped lists the duplicates a cleanup plan leaves in place.
+func printSkipped(plan cleanup.Plan) {
+	for _, s := range plan.Skipped {
+		fmt.Printf("Skipping %s because %s.\n", s.Path, s.Reason)
+	}
+}
+
+// printReferenceWarning lists the reference images a cleanup plan removes
+// because the reviewer chose to keep the duplicate instead.
+func printReferenceWarning(plan cleanup.Plan) {
+	refs := plan.FromReference()
+	if len(refs) == 0 {
+		return
+	}
+	fmt.Printf("Warning: %d reference images will be removed because their duplicates were chosen to be kept:\n", len(refs))
+	for _, f := range refs {
+		fmt.Printf("  %s (keeping %s)\n", f.Path, f.Keep)
+	}
+}
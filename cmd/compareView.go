@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/verify"
+)
+
+type viewMode int
+
+const (
+	sideBySideView viewMode = iota
+	flickerView
+	overlayView
+	diffView
+)
+
+const flickerInterval = 500 * time.Millisecond
+
+var viewNames = []string{"Side by side", "Flicker", "Overlay", "Difference"}
+
+// compareView shows the current pair as a single image in the flicker,
+// overlay and difference modes, and swaps with the side by side view when
+// the mode changes.
+type compareView struct {
+	gui        *reviewGui
+	mode       viewMode
+	sideBySide fyne.CanvasObject
+	content    fyne.CanvasObject
+	viewSelect *widget.Select
+	label      *widget.Label
+	base       *canvas.Image
+	top        *canvas.Image
+	scroll     *container.Scroll
+	opacity    *widget.Slider
+	stop       chan struct{}
+
+	// ref and dupe are the images the aligned duplicate and heatmap were
+	// computed from, so they are only recomputed when the pair changes.
+	ref     image.Image
+	dupe    image.Image
+	aligned image.Image
+	heatmap image.Image
+}
+
+func newCompareView(g *reviewGui, sideBySide fyne.CanvasObject) *compareView {
+	c := &compareView{gui: g, sideBySide: sideBySide}
+	c.label = widget.NewLabelWithStyle("", textCentered, bold)
+	c.base = canvas.NewImageFromImage(nil)
+	c.base.FillMode = canvas.ImageFillContain
+	c.top = canvas.NewImageFromImage(nil)
+	c.top.FillMode = canvas.ImageFillContain
+	c.scroll = container.NewScroll(newPanImage(container.NewMax(c.base, c.top), g.panImages(&c.scroll)))
+
+	c.opacity = widget.NewSlider(0, 100)
+	c.opacity.Value = 50
+	c.opacity.OnChanged = func(float64) {
+		if c.mode == overlayView {
+			c.update()
+		}
+	}
+
+	c.viewSelect = widget.NewSelect(viewNames, nil)
+	c.viewSelect.SetSelectedIndex(int(c.mode))
+	c.viewSelect.OnChanged = func(name string) {
+		for i, n := range viewNames {
+			if n == name {
+				c.mode = viewMode(i)
+			}
+		}
+		c.update()
+	}
+
+	c.content = container.NewBorder(container.NewVBox(c.label, c.opacity), nil, nil, nil, c.scroll)
+	c.content.Hide()
+	return c
+}
+
+// cycle switches to the next view mode.
+func (c *compareView) cycle() {
+	c.viewSelect.SetSelectedIndex((int(c.mode) + 1) % len(viewNames))
+}
+
+// update shows the current pair in the selected view mode. It is called
+// whenever the mode or the pair changes.
+func (c *compareView) update() {
+	if c.stop != nil {
+		// Wait for the flicker to stop so that it can't overwrite the image
+		c.stop <- struct{}{}
+		c.stop = nil
+	}
+	images := c.gui.images
+	if c.mode == sideBySideView || images.Ref == nil || images.Dupe == nil {
+		c.content.Hide()
+		c.sideBySide.Show()
+		c.gui.applyZoom()
+		return
+	}
+	c.sideBySide.Hide()
+	c.content.Show()
+
+	if c.ref != images.Ref || c.dupe != images.Dupe {
+		c.ref, c.dupe = images.Ref, images.Dupe
+		c.aligned, c.heatmap = nil, nil
+	}
+
+	c.opacity.Hide()
+	c.top.Hide()
+	switch c.mode {
+	case flickerView:
+		c.stop = make(chan struct{})
+		go c.flicker(c.ref, c.alignedDupe(), c.stop)
+	case overlayView:
+		c.opacity.Show()
+		c.base.Image = c.ref
+		c.top.Image = c.alignedDupe()
+		c.top.Translucency = 1 - c.opacity.Value/100
+		c.top.Show()
+		c.label.SetText(fmt.Sprintf("Duplicate over reference at %.0f%% opacity", c.opacity.Value))
+	case diffView:
+		if c.heatmap == nil {
+			c.heatmap = verify.Heatmap(c.ref, c.dupe, 0)
+		}
+		c.base.Image = c.heatmap
+		c.label.SetText("Difference heatmap. Brighter areas differ more")
+	}
+	c.base.Refresh()
+	c.top.Refresh()
+	c.gui.applyZoom()
+}
+
+// alignedDupe returns the duplicate image scaled to the size of the reference
+// image so that the two line up when overlaid.
+func (c *compareView) alignedDupe() image.Image {
+	if c.aligned == nil {
+		if c.dupe.Bounds().Size() == c.ref.Bounds().Size() {
+			c.aligned = c.dupe
+		} else {
+			c.aligned = verify.Align(c.dupe, c.ref.Bounds())
+		}
+	}
+	return c.aligned
+}
+
+// flicker alternates between the reference and duplicate images until it
+// receives on stop.
+func (c *compareView) flicker(ref, dupe image.Image, stop chan struct{}) {
+	ticker := time.NewTicker(flickerInterval)
+	defer ticker.Stop()
+
+	showDupe := false
+	for {
+		if showDupe {
+			c.base.Image = dupe
+			c.label.SetText("Flicker: duplicate image")
+		} else {
+			c.base.Image = ref
+			c.label.SetText("Flicker: reference image")
+		}
+		c.base.Refresh()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			showDupe = !showDupe
+		}
+	}
+}
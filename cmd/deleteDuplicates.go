@@ -18,15 +18,17 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/trash"
 	"github.com/spf13/cobra"
 )
 
 var (
 	deleteAll bool
 	dryRun    bool
+	permanent bool
 )
 
 // deleteDuplicatesCmd represents the deleteDuplicates command
@@ -34,7 +36,7 @@ var deleteDuplicatesCmd = &cobra.Command{
 	Aliases: []string{"delete", "d"},
 	Use:     "delete-duplicates",
 	Short:   "Delete all confirmed duplicate files",
-	Long:    `After running "dedugo find-duplicates" and "dedugo check-results", this command will go through the file system and delete all confirmed duplicate images.`,
+	Long:    `After running "dedugo find-duplicates" and "dedugo check-results", this command will go through the file system and move all confirmed duplicate images to the trash, from where they can be restored. With --permanent they are deleted instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		deleteDuplicates()
 	},
@@ -44,34 +46,100 @@ func init() {
 	rootCmd.AddCommand(deleteDuplicatesCmd)
 
 	deleteDuplicatesCmd.Flags().StringVarP(&resultsPath, "input-file", "i", "dedugo_results.yaml", "input file to read results from")
-	deleteDuplicatesCmd.Flags().BoolVar(&deleteAll, "all", false, "delete all duplicate images whether they are confirmed or not")
+	deleteDuplicatesCmd.Flags().BoolVar(&deleteAll, "all", false, "delete all duplicate images which have not been marked as not duplicates")
 	deleteDuplicatesCmd.Flags().BoolVar(&logToFile, "log", false, "log events to file")
 	deleteDuplicatesCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show only what would be deleted without actually doing it")
+	deleteDuplicatesCmd.Flags().BoolVar(&permanent, "permanent", false, "delete files permanently instead of moving them to the trash")
 }
 
 func deleteDuplicates() {
 	setupLogging(logToFile)
 
+	results := readResultsFile(resultsPath)
+	plan := cleanup.NewPlan(results, cleanup.Confirmed(results, deleteAll))
+	printSkipped(plan)
+	printReferenceWarning(plan)
+	if len(plan.Files) == 0 {
+		fmt.Println("There are no duplicate images to delete.")
+		return
+	}
+
+	verb := "Moving to the trash"
+	if permanent {
+		verb = "Permanently deleting"
+	}
+
 	var input string
-	fmt.Printf("Are you sure you want to delete all duplicate images found in %s? [y/N]: ", resultsPath)
+	fmt.Printf("Are you sure you want to %s %s found in %s? [y/N]: ", deleteVerb(), planSummary(plan), resultsPath)
 	fmt.Scan(&input)
 	if strings.ToLower(input) != "yes" && strings.ToLower(input) != "y" {
 		fmt.Println("Aborting")
 		return
 	}
 
-	results := readResultsFile(resultsPath)
-	log.Printf("Deleting duplicate images.")
-	for _, p := range results.ImagePairs {
-		if p.Confirmed || deleteAll {
-			fmt.Println("Deleting", p.DupeImage)
-			if !dryRun {
-				err := os.Remove(p.DupeImage)
-				if err != nil {
-					log.Printf("Failed to delete %s. %s\n", p.DupeImage, err)
-				}
-			}
+	log.Printf("%s duplicate images.", verb)
+	if dryRun {
+		for _, f := range plan.Files {
+			fmt.Println(verb, f.Path)
+		}
+		fmt.Println("Done.")
+		return
+	}
+	outcomes, note, err := journaled(deleteOperation(), func(record cleanup.Recorder) ([]cleanup.Outcome, error) {
+		return removeFiles(plan, record)
+	})
+	if err != nil {
+		log.Fatal("Nothing was deleted. ", err)
+	}
+	done := "Moved to the trash"
+	if permanent {
+		done = "Deleted"
+	}
+	failed := 0
+	for _, o := range outcomes {
+		if o.Err != nil {
+			failed++
+			continue
 		}
+		fmt.Println(done, o.File.Path)
+	}
+	for _, o := range outcomes {
+		if o.Err != nil {
+			log.Printf("Failed to %s %s. %s\n", deleteVerb(), o.File.Path, o.Err)
+			fmt.Printf("Failed to %s %s. %s\n", deleteVerb(), o.File.Path, o.Err)
+		}
+	}
+	files, bytes := cleanup.Reclaimed(outcomes)
+	if permanent {
+		fmt.Printf("Done. Deleted %d files and reclaimed %s.\n", files, formatBytes(bytes))
+	} else {
+		fmt.Printf("Done. Moved %d files (%s) to the trash. Empty the trash to reclaim the space.\n", files, formatBytes(bytes))
+	}
+	if failed > 0 {
+		fmt.Printf("%d files could not be deleted.\n", failed)
+	}
+	if note != "" {
+		fmt.Println(note)
+	}
+}
+
+// deleteVerb describes how duplicates are deleted.
+func deleteVerb() string {
+	if permanent {
+		return "permanently delete"
+	}
+	return "move to the trash"
+}
+
+// removeFiles deletes the files in plan, moving them to the current user's
+// trash unless permanent is set.
+func removeFiles(plan cleanup.Plan, record cleanup.Recorder) ([]cleanup.Outcome, error) {
+	if permanent {
+		return cleanup.Delete(plan, record), nil
+	}
+	t, err := trash.Default()
+	if err != nil {
+		return nil, fmt.Errorf("could not find the trash, use --permanent to delete files instead: %w", err)
 	}
-	fmt.Println("Done.")
+	return cleanup.Trash(plan, t, record), nil
 }
@@ -0,0 +1,80 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/mike-lloyd03/dedugo/scan"
+	"github.com/mike-lloyd03/dedugo/verify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffOutput string
+	diffSize   int
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(2),
+	Use:   "diff image_a image_b",
+	Short: "Write a heatmap of the differences between two images",
+	Long: `Scales the second image to the dimensions of the first and writes a PNG heatmap of their per-pixel differences. Unchanged areas are shown as a dimmed grayscale copy of the first image and differences are drawn from red through yellow to white as they grow. This is the same heatmap shown by the difference view in "dedugo check-results".
+
+The similarity scores used by "find-duplicates --verify" are printed as well.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		diffImages(args[0], args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "diff.png", "path to write the heatmap to")
+	diffCmd.Flags().IntVar(&diffSize, "size", 0, "longest edge of the heatmap in pixels (default the size of the first image)")
+}
+
+func diffImages(pathA, pathB string) {
+	a, err := scan.Open(pathA)
+	if err != nil {
+		log.Fatalf("Error opening %s: %s", pathA, err)
+	}
+	b, err := scan.Open(pathB)
+	if err != nil {
+		log.Fatalf("Error opening %s: %s", pathB, err)
+	}
+
+	heatmap := verify.Heatmap(a, b, diffSize)
+	file, err := os.Create(diffOutput)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(file, heatmap); err != nil {
+		file.Close()
+		log.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	scores := verify.Compare(a, b, verifySize)
+	fmt.Printf("SSIM: %.4f  Mean difference: %.2f  Changed pixels: %.1f%%\n", scores.SSIM, scores.MeanDifference, scores.ChangedPixels*100)
+	fmt.Println("Heatmap written to", diffOutput)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/mike-lloyd03/dedugo/evaluate"
+	"github.com/mike-lloyd03/dedugo/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	truthPath   string
+	evalVerbose bool
+)
+
+// evalCmd represents the eval command
+var evalCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(1),
+	Use:   "eval dataset_directory",
+	Short: "Measure matching accuracy against a labeled dataset",
+	Long:  `Finds duplicates among all images in the dataset directory and compares them with a ground truth file listing the groups of images which are true duplicates. Precision, recall and F1 are reported along with the number of pairs found and missed for each transform listed in the ground truth file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		evalDataset(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+
+	evalCmd.Flags().StringVarP(&truthPath, "truth", "t", "", "ground truth file (default is ground_truth.yaml in the dataset directory)")
+	evalCmd.Flags().IntVarP(&minConfidence, "min-confidence", "m", 1, "set the minimum confidence score (1-5) required to consider images similar")
+	evalCmd.Flags().BoolVar(&logToFile, "log", false, "log events to file")
+	evalCmd.Flags().BoolVarP(&evalVerbose, "verbose", "v", false, "list every missed and false match")
+}
+
+func evalDataset(dir string) {
+	setupLogging(logToFile)
+
+	if truthPath == "" {
+		truthPath = filepath.Join(dir, "ground_truth.yaml")
+	}
+	manifest, err := evaluate.ReadManifest(truthPath)
+	if err != nil {
+		log.Fatal("Error reading ground truth file.", err)
+	}
+
+	fmt.Println("Comparing images in", dir)
+	opts := scan.Options{
+		MinConfidence: minConfidence,
+		Bands:         viper.GetIntSlice("confidence-bands"),
+		Progress:      printProgress(),
+	}
+	result, err := scan.Within(context.Background(), dir, opts)
+	if err != nil {
+		log.Fatal("Error:", err)
+	}
+	pairMap := result.Pairs
+
+	predicted := make([]evaluate.Pair, 0, len(pairMap))
+	for _, p := range pairMap {
+		predicted = append(predicted, evaluate.NewPair(relPath(dir, p.RefImage), relPath(dir, p.DupeImage)))
+	}
+	report := evaluate.Score(manifest, predicted)
+
+	fmt.Printf("\nImages: %d  Predicted pairs: %d  True pairs: %d\n", result.RefImages, report.TP+report.FP, report.TP+report.FN)
+	fmt.Printf("Precision: %.3f  Recall: %.3f  F1: %.3f\n\n", report.Precision, report.Recall, report.F1)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Transform\tFound\tMissed\tRecall")
+	for _, b := range report.Transforms {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.3f\n", b.Transform, b.Found, b.Missed, float64(b.Found)/float64(b.Found+b.Missed))
+	}
+	tw.Flush()
+
+	if evalVerbose {
+		fmt.Println("\nMissed duplicates:")
+		for _, p := range report.Missed {
+			fmt.Println(" ", p)
+		}
+		fmt.Println("\nFalse matches:")
+		for _, p := range report.FalseMatches {
+			fmt.Println(" ", p)
+		}
+	}
+}
+
+// relPath returns path relative to dir, or path unchanged if it is not inside
+// dir.
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -16,26 +16,32 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"image"
-	"io/fs"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
 	"strings"
-	"sync"
 	"time"
 
-	_ "github.com/adrium/goheif"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/scan"
 	"github.com/spf13/cobra"
-	images "github.com/vitali-fedulov/images3"
+	"github.com/spf13/viper"
 )
 
 var (
-	resultsPath   string
-	logToFile     bool
-	minConfidence int
+	resultsPath     string
+	logToFile       bool
+	minConfidence   int
+	verifyPairs     bool
+	verifyThreshold float64
+	verifyAction    string
+	verifySize      int
+	burstWindow     time.Duration
+	burstMode       string
+	profileName     string
 )
 
 // findDuplicatesCmd represents the findDuplicates command
@@ -44,8 +50,11 @@ var findDuplicatesCmd = &cobra.Command{
 	Args:    cobra.MinimumNArgs(2),
 	Use:     "find-duplicates ref_directory eval_directory",
 	Short:   "Finds duplicate images between two directories.",
-	Long:    `Recursively searches through both input directories for images and compares if the "evaulation directory" contains any duplicates of images found in the "reference directory".`,
+	Long: `Recursively searches through both input directories for images and compares if the "evaulation directory" contains any duplicates of images found in the "reference directory".
+
+A matching profile can be chosen with --profile instead of tuning the matching flags. Flags given alongside a profile override it.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		applyProfile(cmd)
 		findDuplicates(args[0], args[1])
 	},
 }
@@ -56,231 +65,163 @@ func init() {
 	findDuplicatesCmd.Flags().StringVarP(&resultsPath, "output-file", "o", "dedugo_results.yaml", "output file for results")
 	findDuplicatesCmd.Flags().BoolVar(&logToFile, "log", false, "log events to file")
 	findDuplicatesCmd.Flags().IntVarP(&minConfidence, "min-confidence", "m", 1, "set the minimum confidence score (1-5) required to consider images similar")
+	findDuplicatesCmd.Flags().BoolVar(&verifyPairs, "verify", false, "re-compare each potential duplicate pixel by pixel and record the scores")
+	findDuplicatesCmd.Flags().Float64Var(&verifyThreshold, "verify-threshold", 0.6, "minimum structural similarity (0-1) for a pair to pass verification")
+	findDuplicatesCmd.Flags().StringVar(&verifyAction, "verify-action", "demote", `what to do with pairs which fail verification: "demote" lowers their confidence by one, "discard" removes them, "keep" only records the scores`)
+	findDuplicatesCmd.Flags().IntVar(&verifySize, "verify-size", scan.DefaultVerifySize, "longest edge in pixels images are scaled to for verification")
+	findDuplicatesCmd.Flags().DurationVar(&burstWindow, "burst-window", 2*time.Second, "maximum gap between EXIF capture times for a pair to be tagged as a burst (0 disables burst detection)")
+	findDuplicatesCmd.Flags().StringVar(&burstMode, "bursts", "tag", `what to do with burst pairs: "tag" marks them in the results, "exclude" removes them, "separate" writes them to their own results file`)
+	findDuplicatesCmd.Flags().StringVar(&profileName, "profile", "", "matching profile which sets --min-confidence, --verify and --verify-action: "+profileNames())
 
 	if minConfidence < 1 || minConfidence > 5 {
 		log.Fatal("Minimum confidence must be in the range of 1-5")
 	}
 }
 
-var (
-	imgFormats = map[string]struct{}{
-		".jpg":  {},
-		".jpeg": {},
-		".heic": {},
-		".png":  {},
+// profileNames lists the names of the matching profiles.
+func profileNames() string {
+	names := make([]string, len(scan.Profiles))
+	for i, p := range scan.Profiles {
+		names[i] = p.Name
 	}
-	wg         sync.WaitGroup
-	m          sync.Mutex
-	maxWorkers int
-)
-
-type Image struct {
-	Path string
-	Icon images.IconT
+	return strings.Join(names, ", ")
 }
 
-type Pair struct {
-	RefImage   string `yaml:"ReferenceImage"`
-	DupeImage  string `yaml:"DuplicateImage"`
-	Confirmed  bool   `yaml:"Confirmed?"`
-	Confidence int    `yaml:"Confidence"`
-}
-
-type Results struct {
-	RefDir     string `yaml:"ReferenceDirectory"`
-	EvalDir    string `yaml:"EvaluationDirectory"`
-	StartIdx   int    `yaml:"StartIndex"`
-	ImagePairs []Pair `yaml:"ImagePairs"`
+// applyProfile sets the matching flags from the chosen profile, leaving any
+// flags given on the command line alone.
+func applyProfile(cmd *cobra.Command) {
+	if profileName == "" {
+		return
+	}
+	profile, ok := scan.FindProfile(profileName)
+	if !ok {
+		log.Fatalf("Unknown profile %q. Must be one of %s.", profileName, profileNames())
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("min-confidence") {
+		minConfidence = profile.MinConfidence
+	}
+	if !flags.Changed("verify") {
+		verifyPairs = profile.Verify
+	}
+	if !flags.Changed("verify-action") && profile.Verify {
+		verifyAction = string(profile.VerifyAction)
+	}
 }
 
 func findDuplicates(refDir, evalDir string) {
 	startTime := time.Now()
 
+	switch verifyAction {
+	case "demote", "discard", "keep":
+	default:
+		log.Fatalf("Unknown verify action %q. Must be demote, discard or keep.", verifyAction)
+	}
+	switch burstMode {
+	case "tag", "exclude", "separate":
+	default:
+		log.Fatalf("Unknown bursts option %q. Must be tag, exclude or separate.", burstMode)
+	}
+
 	setupLogging(logToFile)
 
 	log.Printf("Finding duplicates for %s and %s. Minimum confidence score = %d.\n", refDir, evalDir, minConfidence)
 
-	maxWorkers = runtime.NumCPU()
-
-	pairMap := make(map[string]Pair)
-
-	fmt.Println("Walking reference directory", refDir)
-	refImages, err := getImagesFromDir(refDir)
-	if err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := scanDirs(ctx, refDir, evalDir, scanOptions(printProgress()))
+	if err == context.Canceled {
+		log.Fatal("Scan cancelled.")
+	} else if err != nil {
 		log.Fatal("Error:", err)
 	}
-	fmt.Printf("Images found in reference directory: %d images.\n", len(refImages))
 
-	fmt.Println("Walking evaluation directory", evalDir)
-	evalImages, err := getImagesFromDir(evalDir)
-	if err != nil {
-		log.Fatal("Error:", err)
+	fmt.Printf("Images found: %d in the reference directory and %d in the evaluation directory.\n", result.RefImages, result.EvalImages)
+	if len(result.Unreadable) > 0 {
+		fmt.Printf("Skipped %d images which could not be read.\n", len(result.Unreadable))
 	}
-	fmt.Printf("Images found in evaluation directory: %d images.\n", len(evalImages))
-
-	fmt.Println("Comparing images...")
-	for _, refImg := range refImages {
-		wg.Add(1)
-		go CompareImages(refImg, evalImages, pairMap)
+	if burstWindow > 0 {
+		fmt.Printf("%d pairs look like burst or bracketed shots.\n", len(result.Bursts))
 	}
-	wg.Wait()
-	fmt.Printf("Done. %d potential duplicate images found.\n", len(pairMap))
-	// checkDuplicates(pairMap)
-	GenerateResults(refDir, evalDir, pairMap)
-	log.Printf("Done. Found %d potential duplicates. Total elapsed time: %s", len(pairMap), time.Now().Sub(startTime).Round(10*time.Millisecond))
-}
-
-func getImagePaths(dir string) []string {
-	paths := make([]string, 0)
-	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
-		if !entry.IsDir() {
-			if isImage(path) {
-				paths = append(paths, path)
-			}
-		}
-		if err != nil {
-			log.Fatal(path, err)
-		}
-		return nil
-	},
-	)
-	return paths
-}
-
-func isImage(path string) bool {
-	_, found := imgFormats[strings.ToLower(filepath.Ext(path))]
-	return found
-}
-
-func getImagesFromDir(dir string) ([]Image, error) {
-	imgs := getImagePaths(dir)
-	imageList := make([]Image, 0)
-	pathChan := make(chan string, len(imgs))
-	imageChan := make(chan Image, len(imgs))
-	countChan := make(chan bool, len(imgs))
-
-	var numWorkers int
-	if len(imgs) < maxWorkers {
-		numWorkers = len(imgs)
-	} else {
-		numWorkers = maxWorkers
+	if burstMode == "separate" && len(result.Bursts) > 0 {
+		fmt.Println("Burst pairs written to", burstsPath(resultsPath))
 	}
-	startTime := time.Now()
-	log.Printf("Beginning scan of %s with %d workers.\n", dir, numWorkers)
-
-	go monitorProgress(countChan, len(imgs))
+	fmt.Printf("Done. %d potential duplicate images found.\n", len(result.Pairs))
+	log.Printf("Done. Found %d potential duplicates. Total elapsed time: %s", len(result.Pairs), time.Now().Sub(startTime).Round(10*time.Millisecond))
+}
 
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go openAndHashWorker(pathChan, imageChan, countChan)
+// scanOptions returns the scan options set by the find-duplicates flags and
+// the confidence bands in the config file.
+func scanOptions(progress func(scan.Progress)) scan.Options {
+	return scan.Options{
+		MinConfidence:   minConfidence,
+		Bands:           viper.GetIntSlice("confidence-bands"),
+		Verify:          verifyPairs,
+		VerifyThreshold: verifyThreshold,
+		VerifyAction:    scan.VerifyAction(verifyAction),
+		VerifySize:      verifySize,
+		BurstWindow:     burstWindow,
+		Progress:        progress,
 	}
+}
 
-	for _, path := range imgs {
-		pathChan <- path
+// scanDirs finds duplicates between refDir and evalDir and writes them to the
+// results file, handling burst pairs as the --bursts flag asks. The pairs
+// written are returned. It is used by find-duplicates and the scan screen of
+// the GUI.
+func scanDirs(ctx context.Context, refDir, evalDir string, opts scan.Options) (scan.Result, error) {
+	result, err := scan.Find(ctx, refDir, evalDir, opts)
+	if err != nil {
+		return result, err
 	}
-
-	close(pathChan)
-	wg.Wait()
-	close(imageChan)
-	close(countChan)
-	for img := range imageChan {
-		imageList = append(imageList, img)
+	if burstMode == "exclude" || burstMode == "separate" {
+		for key := range result.Bursts {
+			delete(result.Pairs, key)
+		}
 	}
-	log.Printf("Finished scan. Found %d images. Elapsed time: %s\n", len(imageList), time.Now().Sub(startTime).Round(10*time.Millisecond))
-	return imageList, nil
-}
-
-func openAndHashWorker(pathChan <-chan string, imageChan chan<- Image, countChan chan<- bool) {
-	defer wg.Done()
-	for path := range pathChan {
-		img, err := OpenImage(path)
-		if err != nil {
-			log.Fatalf("Error opening %s: %s", path, err)
+	if burstMode == "separate" && len(result.Bursts) > 0 {
+		if err := GenerateResults(refDir, evalDir, result.Bursts, burstsPath(resultsPath)); err != nil {
+			return result, err
 		}
-		icon := images.Icon(img, path)
-		imageChan <- Image{path, icon}
-		countChan <- true
 	}
+	return result, GenerateResults(refDir, evalDir, result.Pairs, resultsPath)
 }
 
-// OpenImage opens and decodes an image file for a given path.
-func OpenImage(path string) (img image.Image, err error) {
-	file, err := os.Open(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-	img, _, err = image.Decode(file)
-	file.Close()
-	if err != nil {
-		return nil, err
-	}
-	return img, nil
+// burstsPath returns the path burst pairs are written to when they are
+// separated from the results file at path.
+func burstsPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_bursts" + filepath.Ext(path)
 }
 
-func CompareImages(refImg Image, evalImages []Image, pairMap map[string]Pair) {
-	defer wg.Done()
-	for _, evalImg := range evalImages {
-		// m1, m2, m3 := images.EucMetric(refImg.Icon, evalImg.Icon)
-		// if (m1+m2+m3)/3 < 2000 {
-		// 	m.Lock()
-		// 	pairMap[refImg.Path+","+evalImg.Path] = Pair{RefImage: refImg.Path, DupeImage: evalImg.Path}
-		// 	m.Unlock()
-		// }
-		confidence := calcConfidence(images.EucMetric(refImg.Icon, evalImg.Icon))
-		if confidence >= minConfidence {
-			m.Lock()
-			pairMap[refImg.Path+","+evalImg.Path] = Pair{RefImage: refImg.Path, DupeImage: evalImg.Path, Confidence: confidence}
-			m.Unlock()
+// printProgress returns a progress callback which prints each stage of a
+// scan on its own line, updating the line as the stage progresses.
+func printProgress() func(scan.Progress) {
+	var stage scan.Stage
+	var dir string
+	return func(p scan.Progress) {
+		if p.Stage != stage || p.Dir != dir {
+			stage, dir = p.Stage, p.Dir
+			fmt.Println(p)
+			return
 		}
+		fmt.Print("\033[1A\033[K")
+		fmt.Println(p)
 	}
 }
 
-func GenerateResults(refDir, evalDir string, pairMap map[string]Pair) {
-	pairArray := make([]Pair, len(pairMap))
+func GenerateResults(refDir, evalDir string, pairMap map[string]review.Pair, path string) error {
+	pairArray := make([]review.Pair, len(pairMap))
 	i := 0
 	for _, p := range pairMap {
+		p.Review = review.Unreviewed
 		pairArray[i] = p
 		i++
 	}
-	results := Results{
+	results := review.Results{
 		RefDir:     refDir,
 		EvalDir:    evalDir,
 		StartIdx:   0,
 		ImagePairs: pairArray,
 	}
-	WriteResultsFile(results, resultsPath)
-}
-
-// calcConfidence returns the confidence that two images are similar on a scale of 0-5
-// with 5 being the highest confidence
-func calcConfidence(m1, m2, m3 float32) int {
-	avg := (m1 + m2 + m3) / 3
-	if avg < 2000 {
-		return 5
-	}
-	if avg < 5000 {
-		return 4
-	}
-	if avg < 8000 {
-		return 3
-	}
-	if avg < 11000 {
-		return 2
-	}
-	if avg < 14000 {
-		return 1
-	}
-	return 0
-}
-
-func monitorProgress(countChan chan bool, total int) {
-	count := 1
-	fmt.Println()
-	for done := range countChan {
-		if done {
-			fmt.Print("\033[1A\033[K")
-			fmt.Printf("Scanning in progress: %d / %d\n", count, total)
-			count += 1
-		}
-	}
+	return review.Write(results, path)
 }
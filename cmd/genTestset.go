@@ -0,0 +1,114 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mike-lloyd03/dedugo/evaluate"
+	"github.com/mike-lloyd03/dedugo/scan"
+	"github.com/mike-lloyd03/dedugo/testset"
+	"github.com/spf13/cobra"
+)
+
+var transformNames []string
+
+// genTestsetCmd represents the genTestset command
+var genTestsetCmd = &cobra.Command{
+	Args:  cobra.MinimumNArgs(2),
+	Use:   "gen-testset output_directory source [source...]",
+	Short: "Generate a labeled dataset of duplicate images",
+	Long: `Writes a copy of each source image to the output directory along with variants produced by resizing, recompressing, cropping, rotating, flipping, color shifting, watermarking and converting to PNG. Sources may be image files or directories which are searched recursively.
+
+A ground_truth.yaml file listing each source image and its variants is written to the output directory so that the dataset can be used with "dedugo eval". Available transforms are:
+  ` + strings.Join(transformList(), "\n  "),
+	Run: func(cmd *cobra.Command, args []string) {
+		genTestset(args[0], args[1:])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(genTestsetCmd)
+
+	genTestsetCmd.Flags().StringSliceVarP(&transformNames, "transforms", "t", nil, "comma separated list of transforms to apply (default all)")
+}
+
+func genTestset(outDir string, sources []string) {
+	transforms, err := testset.Lookup(transformNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	paths := make([]string, 0)
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if info.IsDir() {
+			dirPaths, err := scan.ImagePaths(src)
+			if err != nil {
+				log.Fatal(err)
+			}
+			paths = append(paths, dirPaths...)
+		} else {
+			paths = append(paths, src)
+		}
+	}
+
+	manifest := evaluate.Manifest{}
+	names := make(map[string]int)
+	for _, path := range paths {
+		img, err := scan.Open(path)
+		if err != nil {
+			log.Fatalf("Error opening %s: %s", path, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		names[name]++
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, names[name])
+		}
+
+		fmt.Printf("Generating %d variants of %s\n", len(transforms), path)
+		group, err := testset.Generate(img, path, outDir, name, transforms)
+		if err != nil {
+			log.Fatal(err)
+		}
+		manifest.Groups = append(manifest.Groups, group)
+	}
+
+	truthFile := filepath.Join(outDir, "ground_truth.yaml")
+	if err := evaluate.WriteManifest(manifest, truthFile); err != nil {
+		log.Fatal("Error writing ground truth file.", err)
+	}
+	fmt.Printf("Done. Wrote %d groups to %s.\n", len(manifest.Groups), truthFile)
+}
+
+func transformList() []string {
+	names := make([]string, len(testset.Transforms))
+	for i, t := range testset.Transforms {
+		names[i] = t.Name
+	}
+	return names
+}
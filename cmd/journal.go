@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/journal"
+)
+
+// journaled runs remove, recording each file in the journal just before it is
+// removed so that the run can be undone even if it is interrupted. It returns
+// the outcomes of remove and a note on how to undo the run, or why it can't
+// be. Nothing is removed if the journal can't be written.
+func journaled(op journal.Operation, remove func(cleanup.Recorder) ([]cleanup.Outcome, error)) ([]cleanup.Outcome, string, error) {
+	j, err := journal.Default()
+	if err != nil {
+		return nil, "", err
+	}
+	run := journal.Run{Operation: op, Time: time.Now()}
+	if abs, err := filepath.Abs(resultsPath); err == nil {
+		run.Results = abs
+	}
+	w, err := j.Begin(run)
+	if err != nil {
+		return nil, "", fmt.Errorf("the journal could not be written: %w", err)
+	}
+
+	entries := make(map[string]journal.Entry)
+	outcomes, err := remove(func(o cleanup.Outcome) error {
+		e, err := journalEntry(o)
+		if err != nil {
+			return err
+		}
+		entries[o.File.Path] = e
+		return w.Record(e)
+	})
+	var removed []journal.Entry
+	for _, o := range outcomes {
+		if o.Err == nil {
+			removed = append(removed, entries[o.File.Path])
+		}
+	}
+	if ferr := w.Finish(removed); ferr != nil {
+		return outcomes, fmt.Sprintf("The journal of this run could not be saved. %s", ferr), err
+	}
+	if len(removed) == 0 || op == journal.Delete {
+		return outcomes, "", err
+	}
+	return outcomes, fmt.Sprintf("Run \"dedugo undo %s\" to undo this.", w.ID()), err
+}
+
+// journalEntry returns the journal entry of a file being removed. Paths are
+// absolute since runs may be undone from any directory.
+func journalEntry(o cleanup.Outcome) (journal.Entry, error) {
+	source, err := filepath.Abs(o.File.Path)
+	if err != nil {
+		return journal.Entry{}, err
+	}
+	dest := o.Dest
+	if dest != "" {
+		if dest, err = filepath.Abs(dest); err != nil {
+			return journal.Entry{}, err
+		}
+	}
+	return journal.Entry{Source: source, Dest: dest, Info: o.Info, Size: o.File.Size, Hash: o.Hash}, nil
+}
+
+// deleteOperation is how duplicates are deleted.
+func deleteOperation() journal.Operation {
+	if permanent {
+		return journal.Delete
+	}
+	return journal.Trash
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/metadata"
+)
+
+var metadataRows = []string{"Dimensions", "File size", "Format", "Modified", "Captured", "Camera", "GPS"}
+
+// metadataPanel lists the attributes of one image of a pair. Values which are
+// better than the other image's are highlighted.
+type metadataPanel struct {
+	values  []*canvas.Text
+	content *fyne.Container
+}
+
+func newMetadataPanel() *metadataPanel {
+	m := &metadataPanel{content: container.NewGridWithColumns(2)}
+	for _, row := range metadataRows {
+		value := canvas.NewText("", theme.ForegroundColor())
+		m.values = append(m.values, value)
+		m.content.Add(widget.NewLabel(row))
+		m.content.Add(container.NewCenter(value))
+	}
+	return m
+}
+
+// set shows the attributes of info. better lists the rows, in the order of
+// metadataRows, on which this image is preferable to the other one.
+func (m *metadataPanel) set(info metadata.Info, better []bool) {
+	for i, v := range infoValues(info) {
+		m.values[i].Text = v
+		if better[i] {
+			m.values[i].Color = theme.PrimaryColor()
+			m.values[i].TextStyle = bold
+		} else {
+			m.values[i].Color = theme.ForegroundColor()
+			m.values[i].TextStyle = fyne.TextStyle{}
+		}
+		m.values[i].Refresh()
+	}
+}
+
+// refreshMetadata reads the attributes of the current pair and shows them in
+// the metadata panels.
+func (g *reviewGui) refreshMetadata() {
+	p := g.session.Pair()
+	// Unreadable attributes are shown as empty so errors are ignored here
+	refInfo, _ := metadata.ReadInfo(p.RefImage)
+	dupeInfo, _ := metadata.ReadInfo(p.DupeImage)
+
+	c := metadata.Compare(refInfo, dupeInfo)
+	sides := []metadata.Side{c.Resolution, c.FileSize, metadata.Neither, c.Modified, c.Captured, c.Camera, c.GPS}
+	refBetter := make([]bool, len(sides))
+	dupeBetter := make([]bool, len(sides))
+	for i, s := range sides {
+		refBetter[i] = s == metadata.Left
+		dupeBetter[i] = s == metadata.Right
+	}
+	g.refMetadata.set(refInfo, refBetter)
+	g.dupeMetadata.set(dupeInfo, dupeBetter)
+}
+
+// infoValues formats the attributes of info in the order of metadataRows.
+func infoValues(info metadata.Info) []string {
+	values := make([]string, len(metadataRows))
+	if info.Width > 0 {
+		values[0] = fmt.Sprintf("%dx%d", info.Width, info.Height)
+	}
+	if info.Size > 0 {
+		values[1] = formatBytes(info.Size)
+	}
+	values[2] = strings.ToUpper(info.Format)
+	if !info.ModTime.IsZero() {
+		values[3] = info.ModTime.Local().Format("2006-01-02 15:04:05")
+	}
+	values[4] = "none"
+	if !info.Exif.CaptureTime.IsZero() {
+		values[4] = info.Exif.CaptureTime.Format("2006-01-02 15:04:05")
+	}
+	values[5] = "unknown"
+	if camera := info.Exif.Camera(); camera != "" {
+		values[5] = camera
+	}
+	values[6] = "no"
+	if info.Exif.HasGPS {
+		values[6] = "yes"
+	}
+	return values
+}
@@ -18,14 +18,18 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/journal"
 	"github.com/spf13/cobra"
 )
 
-var moveAll bool
+var (
+	moveAll    bool
+	moveMirror bool
+	moveRename bool
+)
 
 // moveDuplicatesCmd represents the moveDuplicates command
 var moveDuplicatesCmd = &cobra.Command{
@@ -33,7 +37,9 @@ var moveDuplicatesCmd = &cobra.Command{
 	Args:    cobra.MinimumNArgs(1),
 	Use:     "move-duplicates destination_directory",
 	Short:   "Move all confirmed duplicates to a designated directory",
-	Long:    `After running "dedugo find-duplicates" and "dedugo check-results", this command will move all confirmed duplicate images to the designated directory.`,
+	Long: `After running "dedugo find-duplicates" and "dedugo check-results", this command will move all confirmed duplicate images to the designated directory.
+
+With --mirror each image keeps its path relative to the directory it was scanned from, so images with the same name in different folders don't collide. An image whose destination already exists is given a numbered name such as "IMG_0001.2.jpg", or left in place with --rename=false. Images are copied and checked before the original is removed when the destination is on another drive.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		moveDuplicates(args[0])
 	},
@@ -43,35 +49,65 @@ func init() {
 	rootCmd.AddCommand(moveDuplicatesCmd)
 
 	moveDuplicatesCmd.Flags().StringVarP(&resultsPath, "input-file", "i", "dedugo_results.yaml", "input file to read results from")
-	moveDuplicatesCmd.Flags().BoolVar(&moveAll, "all", false, "move all duplicate images whether they are confirmed or not")
+	moveDuplicatesCmd.Flags().BoolVar(&moveAll, "all", false, "move all duplicate images which have not been marked as not duplicates")
 	moveDuplicatesCmd.Flags().BoolVar(&logToFile, "log", false, "log events to file")
 	moveDuplicatesCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show only what would be deleted without actually doing it")
+	moveDuplicatesCmd.Flags().BoolVar(&moveMirror, "mirror", false, "recreate each image's folders under the destination directory")
+	moveDuplicatesCmd.Flags().BoolVar(&moveRename, "rename", true, "give an image a numbered name if its destination already exists instead of skipping it")
 }
 
 func moveDuplicates(destDir string) {
 	setupLogging(logToFile)
 
+	results := readResultsFile(resultsPath)
+	plan := cleanup.NewPlan(results, cleanup.Confirmed(results, moveAll))
+	printSkipped(plan)
+	printReferenceWarning(plan)
+	if len(plan.Files) == 0 {
+		fmt.Println("There are no duplicate images to move.")
+		return
+	}
+
 	var input string
-	fmt.Printf("Are you sure you want to move all duplicate images found in %s? [y/N]: ", resultsPath)
+	fmt.Printf("Are you sure you want to move %s found in %s? [y/N]: ", planSummary(plan), resultsPath)
 	fmt.Scan(&input)
 	if strings.ToLower(input) != "yes" && strings.ToLower(input) != "y" {
 		fmt.Println("Aborting")
 		return
 	}
 
-	results := readResultsFile(resultsPath)
 	log.Printf("Moving duplicate images to %s.", destDir)
-	for _, p := range results.ImagePairs {
-		if p.Confirmed || moveAll {
-			newPath := filepath.Join(destDir, filepath.Base(p.DupeImage))
-			log.Printf("Moving %s to %s\n", p.DupeImage, newPath)
-			if !dryRun {
-				err := os.Rename(p.DupeImage, newPath)
-				if err != nil {
-					log.Printf("%s", err)
-				}
-			}
+	opts := cleanup.MoveOptions{Mirror: moveMirror, Rename: moveRename}
+	if dryRun {
+		for i, dest := range cleanup.Destinations(plan, destDir, opts) {
+			log.Printf("Moving %s to %s\n", plan.Files[i].Path, dest)
+			fmt.Printf("Moving %s to %s\n", plan.Files[i].Path, dest)
 		}
+		fmt.Println("Done.")
+		return
+	}
+	outcomes, note, err := journaled(journal.Move, func(record cleanup.Recorder) ([]cleanup.Outcome, error) {
+		return cleanup.Move(plan, destDir, opts, record), nil
+	})
+	if err != nil {
+		log.Fatal("Nothing was moved. ", err)
+	}
+	failed := 0
+	for _, o := range outcomes {
+		if o.Err != nil {
+			failed++
+			log.Printf("Failed to move %s. %s\n", o.File.Path, o.Err)
+			fmt.Printf("Failed to move %s. %s\n", o.File.Path, o.Err)
+			continue
+		}
+		log.Printf("Moved %s to %s\n", o.File.Path, o.Dest)
+	}
+	files, bytes := cleanup.Reclaimed(outcomes)
+	fmt.Printf("Done. Moved %d files (%s).\n", files, formatBytes(bytes))
+	if failed > 0 {
+		fmt.Printf("%d files could not be moved.\n", failed)
+	}
+	if note != "" {
+		fmt.Println(note)
 	}
-	fmt.Println("Done.")
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// panImage is an image, or a stack of images, which reports mouse drags so
+// that the scroll container holding it can be panned.
+type panImage struct {
+	widget.BaseWidget
+	content fyne.CanvasObject
+	onDrag  func(*fyne.DragEvent)
+}
+
+func newPanImage(content fyne.CanvasObject, onDrag func(*fyne.DragEvent)) *panImage {
+	p := &panImage{content: content, onDrag: onDrag}
+	p.ExtendBaseWidget(p)
+	return p
+}
+
+func (p *panImage) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(p.content)
+}
+
+// Dragged is called when the user drags the image.
+func (p *panImage) Dragged(e *fyne.DragEvent) {
+	if p.onDrag != nil {
+		p.onDrag(e)
+	}
+}
+
+// DragEnd is called when the user stops dragging the image.
+func (p *panImage) DragEnd() {
+}
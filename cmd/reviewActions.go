@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/journal"
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+// maxListedFiles is the number of skipped or failed files listed in a dialog.
+const maxListedFiles = 5
+
+// cleanupAction is a way of removing duplicates from the review GUI.
+type cleanupAction struct {
+	// name is used for the action's button and dialog title.
+	name string
+	// verb and done describe the action in the confirmation and the result.
+	verb string
+	done string
+	// op is recorded in the journal with the files the action removes.
+	op  journal.Operation
+	run func(cleanup.Plan, cleanup.Recorder) ([]cleanup.Outcome, error)
+}
+
+// apply runs the action on plan, recording it in the journal, and returns a
+// note on how to undo it.
+func (a cleanupAction) apply(plan cleanup.Plan) ([]cleanup.Outcome, string, error) {
+	return journaled(a.op, func(record cleanup.Recorder) ([]cleanup.Outcome, error) {
+		return a.run(plan, record)
+	})
+}
+
+func deleteAction() cleanupAction {
+	if permanent {
+		return cleanupAction{name: "Delete", verb: deleteVerb(), done: "Deleted", op: journal.Delete, run: removeFiles}
+	}
+	return cleanupAction{name: "Move to Trash", verb: deleteVerb(), done: "Trashed", op: journal.Trash, run: removeFiles}
+}
+
+func quarantineAction() cleanupAction {
+	dir := quarantinePath()
+	return cleanupAction{
+		name: "Move to Quarantine",
+		verb: "move to " + dir,
+		done: "Moved",
+		op:   journal.Move,
+		run: func(plan cleanup.Plan, record cleanup.Recorder) ([]cleanup.Outcome, error) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+			return cleanup.Move(plan, dir, cleanup.MoveOptions{Rename: true}, record), nil
+		},
+	}
+}
+
+// quarantinePath returns the directory the review GUI moves duplicates to.
+func quarantinePath() string {
+	if quarantineDir != "" {
+		return quarantineDir
+	}
+	return filepath.Join(filepath.Dir(resultsPath), "dedugo_quarantine")
+}
+
+// newActionBar builds the buttons which remove duplicates without leaving the
+// review.
+func (g *reviewGui) newActionBar() *fyne.Container {
+	return container.NewHBox(
+		layout.NewSpacer(),
+		widget.NewButton("Delete Now", func() { g.confirmCurrent(deleteAction()) }),
+		widget.NewButton("Quarantine Now", func() { g.confirmCurrent(quarantineAction()) }),
+		widget.NewButton("Apply All Confirmed", g.confirmAll),
+		layout.NewSpacer(),
+	)
+}
+
+// confirmCurrent asks for confirmation before removing the duplicate image of
+// the current pair.
+func (g *reviewGui) confirmCurrent(action cleanupAction) {
+	plan := cleanup.NewPlan(g.session.Results(), []int{g.session.Index()})
+	if len(plan.Files) == 0 {
+		dialog.ShowInformation(action.name, "Nothing to "+action.verb+".\n"+skippedText(plan), g.window)
+		return
+	}
+	message := fmt.Sprintf("Are you sure you want to %s %s?\n%s\nThe pair will be marked as a duplicate.", action.verb, planSummary(plan), plan.Files[0].Path)
+	if plan.Files[0].Reference {
+		message += "\nWarning: this is the reference image. The duplicate image is kept instead."
+	}
+	dialog.ShowConfirm(action.name, message, func(ok bool) {
+		if ok {
+			g.applyToCurrent(plan, action)
+		}
+	}, g.window)
+}
+
+// applyToCurrent removes the duplicate image of the current pair, marks the
+// pair as a duplicate and moves on to the next one.
+func (g *reviewGui) applyToCurrent(plan cleanup.Plan, action cleanupAction) {
+	outcomes, note, err := action.apply(plan)
+	if err != nil {
+		g.check(err)
+		return
+	}
+	if files, _ := cleanup.Reclaimed(outcomes); files > 0 {
+		if g.session.Pair().Review == review.Duplicate {
+			// Marking the pair again would forget which image was kept
+			_, err = g.session.Next()
+		} else {
+			err = g.session.Decide(review.Duplicate)
+		}
+		g.check(err)
+	}
+	g.refresh()
+	g.showOutcomes(action, outcomes, note)
+}
+
+// confirmAll asks whether to delete or quarantine the duplicate images of
+// every confirmed pair.
+func (g *reviewGui) confirmAll() {
+	results := g.session.Results()
+	plan := cleanup.NewPlan(results, cleanup.Confirmed(results, false))
+	title := "Apply All Confirmed"
+	if len(plan.Files) == 0 {
+		dialog.ShowInformation(title, "There are no confirmed duplicates to remove.\n"+skippedText(plan), g.window)
+		return
+	}
+
+	message := fmt.Sprintf("The confirmed pairs have %s which can be removed.", planSummary(plan))
+	if refs := plan.FromReference(); len(refs) > 0 {
+		message += fmt.Sprintf("\nWarning: %d of them are reference images, where the duplicate image was chosen to be kept instead.", len(refs))
+	}
+	if len(plan.Skipped) > 0 {
+		message += "\n" + skippedText(plan)
+	}
+	var d dialog.Dialog
+	buttons := container.NewHBox(layout.NewSpacer())
+	for _, action := range []cleanupAction{deleteAction(), quarantineAction()} {
+		action := action
+		buttons.Add(widget.NewButton(action.name, func() {
+			d.Hide()
+			g.applyAll(plan, action)
+		}))
+	}
+	buttons.Add(layout.NewSpacer())
+	d = dialog.NewCustom(title, "Cancel", container.NewVBox(widget.NewLabel(message), buttons), g.window)
+	d.Show()
+}
+
+// applyAll removes the duplicate images in plan.
+func (g *reviewGui) applyAll(plan cleanup.Plan, action cleanupAction) {
+	outcomes, note, err := action.apply(plan)
+	if err != nil {
+		g.check(err)
+		return
+	}
+	g.refresh()
+	g.showOutcomes(action, outcomes, note)
+}
+
+// showOutcomes reports how many files an action removed and any which failed,
+// followed by note.
+func (g *reviewGui) showOutcomes(action cleanupAction, outcomes []cleanup.Outcome, note string) {
+	files, bytes := cleanup.Reclaimed(outcomes)
+	message := fmt.Sprintf("%s %d of %d files, reclaiming %s.", action.done, files, len(outcomes), formatBytes(bytes))
+	listed := 0
+	for _, o := range outcomes {
+		if o.Err == nil {
+			continue
+		}
+		if listed == maxListedFiles {
+			message += "\n..."
+			break
+		}
+		message += fmt.Sprintf("\nFailed to %s %s: %s", action.verb, o.File.Path, o.Err)
+		listed++
+	}
+	if note != "" {
+		message += "\n" + note
+	}
+	dialog.ShowInformation(action.name, message, g.window)
+}
+
+// skippedText lists the duplicates a plan leaves in place and why.
+func skippedText(plan cleanup.Plan) string {
+	var lines []string
+	for i, s := range plan.Skipped {
+		if i == maxListedFiles {
+			lines = append(lines, fmt.Sprintf("and %d more", len(plan.Skipped)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("Skipping %s because %s.", s.Path, s.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
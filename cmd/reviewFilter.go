@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+// filterSaveDelay is how long the threshold slider has to stay still before
+// its filter is saved. It is a variable so that tests can shorten it.
+var filterSaveDelay = 500 * time.Millisecond
+
+const (
+	allStates     = "All states"
+	anyConfidence = "Any"
+	// highestConfidence is the highest confidence find-duplicates assigns.
+	highestConfidence = 5
+	// distanceStep is the distance the threshold slider moves in at a time.
+	distanceStep = 100
+)
+
+// filterBar holds the controls used to sort and filter the review queue.
+type filterBar struct {
+	gui           *reviewGui
+	content       fyne.CanvasObject
+	sortSelect    *widget.Select
+	stateSelect   *widget.Select
+	minConfSelect *widget.Select
+	maxConfSelect *widget.Select
+	pathPrefix    *widget.Entry
+	// distance is the threshold slider. Its maximum means no limit. It is
+	// hidden if the results have no distances.
+	distance      *widget.Slider
+	distanceLabel *widget.Label
+	// saveTimer saves the filter once the slider stops moving, since Fyne
+	// has no event for the end of a drag. It runs on its own goroutine, which
+	// is safe because Session.Save may be called from any goroutine.
+	saveTimer     *time.Timer
+	saveTimerLock sync.Mutex
+	stateNames    map[string]review.State
+	// resetting is set while the clear button resets the controls so that
+	// the queue is only rebuilt once.
+	resetting bool
+}
+
+// newFilterBar builds the filter controls. They start out showing the filter
+// saved in the results file.
+func newFilterBar(g *reviewGui) *filterBar {
+	f := &filterBar{gui: g}
+	saved := g.session.Filter()
+
+	sortNames := make([]string, len(review.SortKeys))
+	for i, k := range review.SortKeys {
+		sortNames[i] = sortName(k)
+	}
+	f.sortSelect = widget.NewSelect(sortNames, nil)
+	f.sortSelect.SetSelected(sortName(saved.Sort))
+
+	stateNames := []string{allStates}
+	f.stateNames = make(map[string]review.State)
+	for _, s := range review.States {
+		stateNames = append(stateNames, s.Description())
+		f.stateNames[s.Description()] = s
+	}
+	f.stateSelect = widget.NewSelect(stateNames, nil)
+	switch len(saved.States) {
+	case 0:
+		f.stateSelect.SetSelected(allStates)
+	case 1:
+		f.stateSelect.SetSelected(saved.States[0].Description())
+	default:
+		f.stateSelect.PlaceHolder = "Several states"
+	}
+
+	confidences := make([]string, highestConfidence+1)
+	for i := range confidences {
+		confidences[i] = strconv.Itoa(i)
+	}
+	f.minConfSelect = widget.NewSelect(confidences, nil)
+	f.minConfSelect.SetSelected(strconv.Itoa(saved.MinConfidence))
+	f.maxConfSelect = widget.NewSelect(append([]string{anyConfidence}, confidences[1:]...), nil)
+	if saved.MaxConfidence > 0 {
+		f.maxConfSelect.SetSelected(strconv.Itoa(saved.MaxConfidence))
+	} else {
+		f.maxConfSelect.SetSelected(anyConfidence)
+	}
+
+	f.pathPrefix = widget.NewEntry()
+	f.pathPrefix.SetPlaceHolder("Path prefix")
+	f.pathPrefix.SetText(saved.PathPrefix)
+	f.pathPrefix.OnSubmitted = func(string) { f.apply() }
+
+	// The slider snaps to whole steps, so its maximum is rounded up past the
+	// largest distance. It starts at one step since a maximum distance of 0
+	// means no limit.
+	maxDistance := math.Ceil(float64(g.session.Results().MaxDistance())/distanceStep) * distanceStep
+	f.distance = widget.NewSlider(distanceStep, maxDistance)
+	f.distance.Step = distanceStep
+	f.distance.Value = maxDistance
+	if saved.MaxDistance > 0 && float64(saved.MaxDistance) < maxDistance {
+		f.distance.Value = float64(saved.MaxDistance)
+	}
+	f.distanceLabel = widget.NewLabel("")
+
+	for _, s := range []*widget.Select{f.sortSelect, f.stateSelect, f.minConfSelect, f.maxConfSelect} {
+		s.OnChanged = func(string) { f.apply() }
+	}
+	f.distance.OnChanged = func(float64) { f.drag() }
+
+	clearButton := widget.NewButton("Clear", f.clear)
+
+	distanceRow := container.NewBorder(nil, nil, widget.NewLabel("Max distance"), f.distanceLabel, f.distance)
+	if maxDistance == 0 {
+		// Results written before distances were recorded
+		distanceRow.Hide()
+	}
+	f.content = container.NewVBox(
+		container.NewBorder(nil, nil,
+			container.NewHBox(
+				widget.NewLabel("Sort"), f.sortSelect,
+				widget.NewLabel("Show"), f.stateSelect,
+				widget.NewLabel("Confidence"), f.minConfSelect, widget.NewLabel("to"), f.maxConfSelect,
+			),
+			clearButton,
+			f.pathPrefix,
+		),
+		distanceRow,
+	)
+	return f
+}
+
+// clear resets every control and shows all pairs.
+func (f *filterBar) clear() {
+	f.resetting = true
+	f.sortSelect.SetSelected(sortName(review.SortNone))
+	f.stateSelect.SetSelected(allStates)
+	f.minConfSelect.SetSelected("0")
+	f.maxConfSelect.SetSelected(anyConfidence)
+	f.pathPrefix.SetText("")
+	f.distance.SetValue(f.distance.Max)
+	f.resetting = false
+	f.apply()
+}
+
+// apply rebuilds the review queue with the filter shown in the controls and
+// saves it. The current pair stays selected.
+func (f *filterBar) apply() {
+	if f.resetting {
+		return
+	}
+	f.stopSave()
+	f.gui.check(f.gui.session.SetFilter(f.filter()))
+	f.gui.refresh()
+}
+
+// drag rebuilds the review queue as the threshold slider moves. The filter is
+// only saved once the slider has been still for filterSaveDelay, rather than
+// writing the results file at every step.
+func (f *filterBar) drag() {
+	if f.resetting {
+		return
+	}
+	f.gui.session.PreviewFilter(f.filter())
+	f.gui.refresh()
+	f.saveTimerLock.Lock()
+	defer f.saveTimerLock.Unlock()
+	if f.saveTimer != nil {
+		f.saveTimer.Stop()
+	}
+	f.saveTimer = time.AfterFunc(filterSaveDelay, func() {
+		f.gui.check(f.gui.session.Save())
+	})
+}
+
+// stopSave cancels saving the filter after the slider moved. It reports
+// whether a save was still waiting.
+func (f *filterBar) stopSave() bool {
+	f.saveTimerLock.Lock()
+	defer f.saveTimerLock.Unlock()
+	return f.saveTimer != nil && f.saveTimer.Stop()
+}
+
+// flush saves a filter still waiting for the slider to stop moving.
+func (f *filterBar) flush() {
+	if f.stopSave() {
+		f.gui.check(f.gui.session.Save())
+	}
+}
+
+// filter returns the filter shown in the controls.
+func (f *filterBar) filter() review.Filter {
+	filter := review.Filter{PathPrefix: f.pathPrefix.Text}
+	for _, k := range review.SortKeys {
+		if sortName(k) == f.sortSelect.Selected {
+			filter.Sort = k
+		}
+	}
+	if state, ok := f.stateNames[f.stateSelect.Selected]; ok {
+		filter.States = []review.State{state}
+	} else if f.stateSelect.Selected == "" {
+		// Keep a multiple state filter given on the command line
+		filter.States = f.gui.session.Filter().States
+	}
+	filter.MinConfidence, _ = strconv.Atoi(f.minConfSelect.Selected)
+	filter.MaxConfidence, _ = strconv.Atoi(f.maxConfSelect.Selected)
+	if f.distance.Value < f.distance.Max {
+		filter.MaxDistance = float32(f.distance.Value)
+	}
+	return filter
+}
+
+// refreshCount shows how many pairs the filter includes and the decisions
+// made on them, so that the distance where false positives start can be
+// found by moving the slider.
+func (f *filterBar) refreshCount() {
+	results := f.gui.session.Results()
+	filter := results.Filter
+	p := results.Progress(results.Matching(filter))
+	text := fmt.Sprintf("%d of %d pairs shown", p.Total, len(results.ImagePairs))
+	if filter.MaxDistance > 0 {
+		text += fmt.Sprintf(" up to distance %.0f", filter.MaxDistance)
+	}
+	f.distanceLabel.SetText(fmt.Sprintf("%s: %d confirmed, %d not duplicates", text, p.Duplicates, p.NotDuplicates))
+}
+
+// sortName returns the name of a sort order for display.
+func sortName(k review.SortKey) string {
+	if k == review.SortNone {
+		return "File order"
+	}
+	return strings.Title(string(k))
+}
@@ -16,12 +16,16 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"log"
+	"os"
+
 	"github.com/spf13/cobra"
 
 	"github.com/spf13/viper"
 )
 
-// var cfgFile string
+var cfgFile string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -40,9 +44,30 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.CompletionOptions.HiddenDefaultCmd = true
 
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.dedugo.yaml)")
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+	} else {
+		home, err := os.UserHomeDir()
+		cobra.CheckErr(err)
+
+		viper.AddConfigPath(home)
+		viper.SetConfigType("yaml")
+		viper.SetConfigName(".dedugo")
+	}
+
 	viper.AutomaticEnv() // read in environment variables that match
+
+	// Only a missing default config file is fine. Ignoring a broken one would
+	// let calibrate --write-config overwrite it.
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if cfgFile != "" || !errors.As(err, &notFound) {
+			log.Fatal("Error reading config file. ", err)
+		}
+	}
 }
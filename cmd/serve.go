@@ -0,0 +1,61 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/mike-lloyd03/dedugo/server"
+	"github.com/mike-lloyd03/dedugo/session"
+	"github.com/spf13/cobra"
+)
+
+var listenAddr string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Review results in a web browser",
+	Long:  `Serves a web page for reviewing the image pairs found by "dedugo find-duplicates". Pairs can be filtered by confidence and review state, and every decision is saved to the results file as soon as it is made. Use --addr to make the page available to other machines.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if reviewer == "" {
+			reviewer = currentUser()
+		}
+		serveResults()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVarP(&resultsPath, "input", "i", "dedugo_results.yaml", "input file to read results from")
+	serveCmd.Flags().StringVar(&reviewer, "reviewer", "", "name recorded with decisions when the browser doesn't provide one (default is the current user)")
+	serveCmd.Flags().StringVarP(&listenAddr, "addr", "a", "localhost:8080", "address to listen on")
+}
+
+func serveResults() {
+	s, err := server.New(resultsPath, reviewer)
+	if err == session.ErrNoPairs {
+		fmt.Println("There are no image pairs to review.")
+		return
+	} else if err != nil {
+		log.Fatal("Error reading results file.", err)
+	}
+	fmt.Printf("Serving %s at http://%s\n", resultsPath, listenAddr)
+	log.Fatal(http.ListenAndServe(listenAddr, s))
+}
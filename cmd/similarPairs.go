@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/metadata"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/session"
+)
+
+// captureTimes returns a review.TimeFunc which reads the EXIF capture time of
+// each image once.
+func captureTimes() review.TimeFunc {
+	var m sync.Mutex
+	times := make(map[string]time.Time)
+	return func(path string) (time.Time, bool) {
+		m.Lock()
+		defer m.Unlock()
+		t, found := times[path]
+		if !found {
+			info, _ := metadata.ReadExif(path)
+			t = info.CaptureTime
+			times[path] = t
+		}
+		return t, !t.IsZero()
+	}
+}
+
+// similarToLast returns the pair decided most recently in the session and the
+// pending pairs similar to it. It returns false if no pair has been decided.
+func similarToLast(s *session.Session, taken review.TimeFunc) (int, []int, bool) {
+	index, ok := s.LastDecided()
+	if !ok || s.Results().ImagePairs[index].Review == review.Unreviewed {
+		return 0, nil, false
+	}
+	return index, s.Similar(index, similarWindow, taken), true
+}
+
+// pairName describes a pair by its file names.
+func pairName(p review.Pair) string {
+	return fmt.Sprintf("%s ↔ %s", filepath.Base(p.RefImage), filepath.Base(p.DupeImage))
+}
+
+// confirmSimilar lists the pending pairs similar to the pair decided most
+// recently and asks before giving them the same decision.
+func (g *reviewGui) confirmSimilar() {
+	title := "Apply to Similar"
+	index, similar, ok := similarToLast(g.session, g.captureTime)
+	if !ok {
+		dialog.ShowInformation(title, "Decide a pair first, then its decision can be applied to similar pairs.", g.window)
+		return
+	}
+	pairs := g.session.Results().ImagePairs
+	decided := pairs[index]
+	if len(similar) == 0 {
+		dialog.ShowInformation(title, fmt.Sprintf("No pending pairs are similar to %s.", pairName(decided)), g.window)
+		return
+	}
+
+	checks := make([]*widget.Check, len(similar))
+	list := container.NewVBox()
+	for i, j := range similar {
+		checks[i] = widget.NewCheck(pairName(pairs[j]), nil)
+		checks[i].SetChecked(true)
+		list.Add(checks[i])
+	}
+	message := fmt.Sprintf("%s was marked \"%s\".\nApply the same decision to these %d pending pairs? They share images with it, or were taken within %s of it in the same folders.", pairName(decided), decided.Decision(), len(similar), similarWindow)
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(0, 200))
+	content := container.NewBorder(widget.NewLabel(message), nil, nil, nil, scroll)
+
+	d := dialog.NewCustomConfirm(title, "Apply", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		chosen := make([]int, 0, len(similar))
+		for i, c := range checks {
+			if c.Checked {
+				chosen = append(chosen, similar[i])
+			}
+		}
+		g.applySimilar(index, chosen)
+	}, g.window)
+	d.Resize(fyne.NewSize(imgWidth, imgHeight))
+	d.Show()
+}
+
+// applySimilar gives the pairs in similar the decision made on the pair at
+// index.
+func (g *reviewGui) applySimilar(index int, similar []int) {
+	g.check(g.session.ApplyDecision(index, similar))
+	g.refresh()
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var startResultsPath string
+
+// startCmd represents the start command
+var startCmd = &cobra.Command{
+	Aliases: []string{"gui"},
+	Use:     "start",
+	Short:   "Pick folders to scan and review the duplicates in a window",
+	Long: `Opens a window for choosing a reference folder, an evaluation folder and a matching profile. The folders are scanned the same way "dedugo find-duplicates" scans them, with the progress shown in the window, and the duplicates found are opened for review as soon as the scan finishes.
+
+Results are written to dedugo_results.yaml in the evaluation folder unless --output-file is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if reviewer == "" {
+			reviewer = currentUser()
+		}
+		setupLogging(logToFile)
+		showStartGui()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
+
+	startCmd.Flags().StringVarP(&startResultsPath, "output-file", "o", "", "output file for results (default is dedugo_results.yaml in the evaluation folder)")
+	startCmd.Flags().StringVar(&reviewer, "reviewer", "", "name recorded with each decision (default is the current user)")
+	startCmd.Flags().BoolVar(&logToFile, "log", false, "log events to file")
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/mike-lloyd03/dedugo/scan"
+	"github.com/mike-lloyd03/dedugo/session"
+)
+
+// startGui is the start screen, where the folders to scan are chosen. Once a
+// scan finishes the results are opened in the review window.
+type startGui struct {
+	app    fyne.App
+	window fyne.Window
+
+	refDir       *widget.Entry
+	evalDir      *widget.Entry
+	profile      *widget.Select
+	profileInfo  *widget.Label
+	resultsLabel *widget.Label
+	status       *widget.Label
+	progress     *widget.ProgressBar
+	scanButton   *widget.Button
+	cancelButton *widget.Button
+
+	// cancel stops the running scan.
+	cancel context.CancelFunc
+	// scanning is done once the running scan has finished.
+	scanning sync.WaitGroup
+	// review is the review window opened after a scan.
+	review *reviewGui
+}
+
+func showStartGui() {
+	newStartGui(app.New()).window.ShowAndRun()
+}
+
+// newStartGui builds the start screen.
+func newStartGui(a fyne.App) *startGui {
+	g := &startGui{app: a}
+	g.window = a.NewWindow("dedugo")
+	g.window.Resize(fyne.NewSize(imgWidth, 0))
+	g.window.CenterOnScreen()
+
+	g.refDir = widget.NewEntry()
+	g.refDir.SetPlaceHolder("Folder with the images to keep")
+	g.evalDir = widget.NewEntry()
+	g.evalDir.SetPlaceHolder("Folder to look for duplicates in")
+	g.resultsLabel = widget.NewLabel("")
+	g.evalDir.OnChanged = func(string) { g.refreshResultsLabel() }
+
+	names := make([]string, len(scan.Profiles))
+	for i, p := range scan.Profiles {
+		names[i] = p.Name
+	}
+	g.profileInfo = widget.NewLabel("")
+	g.profileInfo.Wrapping = fyne.TextWrapWord
+	g.profile = widget.NewSelect(names, func(name string) {
+		p, _ := scan.FindProfile(name)
+		g.profileInfo.SetText(p.Description)
+	})
+	g.profile.SetSelected(scan.DefaultProfile)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Reference folder", g.folderPicker(g.refDir)),
+		widget.NewFormItem("Evaluation folder", g.folderPicker(g.evalDir)),
+		widget.NewFormItem("Matching", container.NewVBox(g.profile, g.profileInfo)),
+	)
+
+	g.status = widget.NewLabelWithStyle("", textCentered, fyne.TextStyle{})
+	g.progress = widget.NewProgressBar()
+	g.progress.Hide()
+	g.scanButton = widget.NewButton("Find Duplicates", g.confirmScan)
+	g.scanButton.Importance = widget.HighImportance
+	g.cancelButton = widget.NewButton("Cancel", g.cancelScan)
+	g.cancelButton.Disable()
+
+	g.window.SetContent(container.NewVBox(
+		widget.NewLabelWithStyle("Find duplicate images", textCentered, bold),
+		form,
+		g.resultsLabel,
+		g.progress,
+		g.status,
+		container.NewHBox(layout.NewSpacer(), g.scanButton, g.cancelButton, layout.NewSpacer()),
+	))
+	g.window.SetOnClosed(g.cancelScan)
+	g.refreshResultsLabel()
+	return g
+}
+
+// folderPicker returns entry with a button for choosing its folder.
+func (g *startGui) folderPicker(entry *widget.Entry) fyne.CanvasObject {
+	choose := widget.NewButton("Choose...", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			if dir != nil {
+				entry.SetText(dir.Path())
+			}
+		}, g.window)
+	})
+	return container.NewBorder(nil, nil, nil, choose, entry)
+}
+
+// resultsFile returns where the results of scanning the chosen folders are
+// written.
+func (g *startGui) resultsFile() string {
+	if startResultsPath != "" || g.evalDir.Text == "" {
+		return startResultsPath
+	}
+	return filepath.Join(g.evalDir.Text, "dedugo_results.yaml")
+}
+
+func (g *startGui) refreshResultsLabel() {
+	if path := g.resultsFile(); path != "" {
+		g.resultsLabel.SetText("Results will be saved to " + path)
+	} else {
+		g.resultsLabel.SetText("")
+	}
+}
+
+// confirmScan checks the chosen folders and asks before replacing an existing
+// results file.
+func (g *startGui) confirmScan() {
+	for _, dir := range []struct{ name, path string }{{"reference", g.refDir.Text}, {"evaluation", g.evalDir.Text}} {
+		if info, err := os.Stat(dir.path); err != nil || !info.IsDir() {
+			dialog.ShowInformation("Find Duplicates", fmt.Sprintf("Choose the %s folder to scan.", dir.name), g.window)
+			return
+		}
+	}
+	path := g.resultsFile()
+	if _, err := os.Stat(path); err == nil {
+		message := fmt.Sprintf("%s already exists.\nScanning again will replace it and any review decisions saved in it.", path)
+		dialog.ShowConfirm("Find Duplicates", message, func(ok bool) {
+			if ok {
+				g.startScan()
+			}
+		}, g.window)
+		return
+	}
+	g.startScan()
+}
+
+// startScan scans the chosen folders in the background.
+func (g *startGui) startScan() {
+	profile, _ := scan.FindProfile(g.profile.Selected)
+	opts := scanOptions(g.showProgress)
+	profile.Apply(&opts)
+	refDir, evalDir := g.refDir.Text, g.evalDir.Text
+	resultsPath = g.resultsFile()
+
+	var ctx context.Context
+	ctx, g.cancel = context.WithCancel(context.Background())
+	g.setScanning(true)
+	g.scanning.Add(1)
+	go func() {
+		defer g.scanning.Done()
+		result, err := scanDirs(ctx, refDir, evalDir, opts)
+		g.cancel()
+		g.setScanning(false)
+		g.finishScan(result, err)
+	}()
+}
+
+// cancelScan stops the running scan, if there is one.
+func (g *startGui) cancelScan() {
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+// setScanning disables the form while a scan is running.
+func (g *startGui) setScanning(scanning bool) {
+	for _, w := range []fyne.Disableable{g.refDir, g.evalDir, g.profile, g.scanButton} {
+		if scanning {
+			w.Disable()
+		} else {
+			w.Enable()
+		}
+	}
+	if scanning {
+		g.cancelButton.Enable()
+		g.progress.SetValue(0)
+		g.progress.Show()
+	} else {
+		g.cancelButton.Disable()
+		g.progress.Hide()
+	}
+}
+
+func (g *startGui) showProgress(p scan.Progress) {
+	g.status.SetText(p.String())
+	if p.Total > 0 {
+		g.progress.SetValue(float64(p.Done) / float64(p.Total))
+	} else {
+		g.progress.SetValue(0)
+	}
+}
+
+// finishScan opens the results of a scan for review.
+func (g *startGui) finishScan(result scan.Result, err error) {
+	if err == context.Canceled {
+		g.status.SetText("Scan cancelled.")
+		return
+	} else if err != nil {
+		g.status.SetText("Scan failed.")
+		dialog.ShowError(err, g.window)
+		return
+	}
+
+	message := fmt.Sprintf("Found %d potential duplicates among %d reference and %d evaluation images.", len(result.Pairs), result.RefImages, result.EvalImages)
+	if len(result.Unreadable) > 0 {
+		message += fmt.Sprintf(" %d images could not be read.", len(result.Unreadable))
+	}
+	g.status.SetText(message)
+
+	s, err := newReviewSession()
+	if err == session.ErrNoPairs {
+		dialog.ShowInformation("Find Duplicates", "No duplicates were found.", g.window)
+		return
+	} else if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.review = newReviewGui(g.app, s)
+	g.review.window.Show()
+	g.window.Close()
+}
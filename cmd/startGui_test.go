@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+// writeGradient writes a gradient image to path which is large enough to be
+// matched by a scan.
+func writeGradient(t *testing.T, path string) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 5), 100, 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	png.Encode(f, img)
+}
+
+func TestStartGuiScan(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	refDir, dir := t.TempDir(), t.TempDir()
+	writeGradient(t, filepath.Join(refDir, "a.png"))
+	writeGradient(t, filepath.Join(dir, "a copy.png"))
+	g := newStartGui(test.NewApp())
+
+	test.Tap(g.scanButton)
+	if g.cancel != nil {
+		t.Fatal("a scan should not start before the folders are chosen")
+	}
+
+	g.refDir.SetText(refDir)
+	g.evalDir.SetText(dir)
+	if want := filepath.Join(dir, "dedugo_results.yaml"); !strings.Contains(g.resultsLabel.Text, want) {
+		t.Errorf("expected the results to be saved in the evaluation folder. got %q", g.resultsLabel.Text)
+	}
+	g.profile.SetSelected("thorough")
+	test.Tap(g.scanButton)
+	g.scanning.Wait()
+
+	if g.review == nil {
+		t.Fatal("the results should be opened for review once the scan finishes. status:", g.status.Text)
+	}
+	if g.scanButton.Disabled() || !g.cancelButton.Disabled() {
+		t.Error("only the scan button should be enabled after the scan")
+	}
+	results, err := review.Read(filepath.Join(dir, "dedugo_results.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.ImagePairs) != 1 || results.RefDir != refDir {
+		t.Errorf("expected a and its copy to be paired. got %+v", results)
+	}
+	if p := g.review.session.Pair(); p.DupeImage != filepath.Join(dir, "a copy.png") {
+		t.Error("the review should show the pair found. got", p)
+	}
+}
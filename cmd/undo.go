@@ -0,0 +1,151 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mike-lloyd03/dedugo/journal"
+	"github.com/spf13/cobra"
+)
+
+var listRuns bool
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [run]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Restore the files removed by a delete or move",
+	Long: `Every run of "dedugo delete-duplicates", "dedugo move-duplicates" and the review window's delete and quarantine actions is recorded in a journal. This command moves the files removed by the most recent run which can be undone, or by the run given, back to where they were. Runs which deleted files permanently can't be undone.
+
+A file is not restored if it was deleted permanently, if it has since been removed from the trash or destination directory or changed there, or if another file has been created in its place. Use --list to see the recorded runs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if listRuns {
+			printRuns()
+			return
+		}
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		undo(id)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Flags().BoolVar(&listRuns, "list", false, "list the recorded runs instead of undoing one")
+	undoCmd.Flags().BoolVar(&logToFile, "log", false, "log events to file")
+}
+
+// runSummary describes a run, such as "moved 3 files (12.4 MiB) to the trash".
+func runSummary(run journal.Run) string {
+	action := map[journal.Operation]string{
+		journal.Delete: "permanently deleted %d files (%s)",
+		journal.Trash:  "moved %d files (%s) to the trash",
+		journal.Move:   "moved %d files (%s)",
+	}[run.Operation]
+	if action == "" {
+		action = string(run.Operation) + " %d files (%s)"
+	}
+	return fmt.Sprintf(action, len(run.Entries), formatBytes(run.Bytes()))
+}
+
+func printRuns() {
+	j, err := journal.Default()
+	if err != nil {
+		log.Fatal(err)
+	}
+	runs, err := j.List()
+	if err != nil {
+		log.Fatal("Error reading the journal.", err)
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs have been recorded.")
+		return
+	}
+	for _, run := range runs {
+		line := fmt.Sprintf("%s  %s  %s", run.ID, run.Time.Format("2006-01-02 15:04:05"), runSummary(run))
+		if run.Results != "" {
+			line += " from " + run.Results
+		}
+		if !run.Undone.IsZero() {
+			line += " (undone)"
+		} else if n := run.Restored(); n > 0 {
+			line += fmt.Sprintf(" (%d restored)", n)
+		}
+		fmt.Println(line)
+	}
+}
+
+func undo(id string) {
+	setupLogging(logToFile)
+
+	j, err := journal.Default()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var run journal.Run
+	if id == "" {
+		run, err = j.Latest()
+	} else {
+		run, err = j.Get(id)
+	}
+	if err == journal.ErrNoRuns {
+		fmt.Println("There are no runs to undo.")
+		return
+	} else if err != nil {
+		log.Fatal("Error reading the journal. ", err)
+	}
+	if run.Operation == journal.Delete {
+		fmt.Printf("Run %s %s, so they can't be restored.\n", run.ID, runSummary(run))
+		return
+	}
+	if !run.Undone.IsZero() {
+		fmt.Printf("Run %s has already been undone.\n", run.ID)
+		return
+	}
+
+	var input string
+	fmt.Printf("Run %s %s on %s. Are you sure you want to restore them? [y/N]: ", run.ID, runSummary(run), run.Time.Format("2006-01-02 15:04:05"))
+	fmt.Scan(&input)
+	if strings.ToLower(input) != "yes" && strings.ToLower(input) != "y" {
+		fmt.Println("Aborting")
+		return
+	}
+
+	log.Printf("Undoing run %s.", run.ID)
+	errs := run.Undo()
+	restored := 0
+	for i, e := range run.Entries {
+		if errs[i] != nil {
+			log.Printf("Could not restore %s. %s\n", e.Source, errs[i])
+			fmt.Printf("Could not restore %s because %s.\n", e.Source, errs[i])
+			continue
+		}
+		restored++
+	}
+	if err := j.Save(run); err != nil {
+		log.Fatal("Error writing the journal. ", err)
+	}
+	fmt.Printf("Done. Restored %d of %d files.\n", restored, len(run.Entries))
+	if restored < len(run.Entries) {
+		fmt.Printf("Run \"dedugo undo %s\" to try the others again.\n", run.ID)
+	}
+}
@@ -0,0 +1,188 @@
+package evaluate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Original is the transform name reported for pairs where neither image was
+// derived from the other.
+const Original = "original"
+
+// Manifest lists the groups of images in a dataset which are true duplicates of
+// each other.
+type Manifest struct {
+	Groups []Group `yaml:"Groups"`
+}
+
+// Group is a set of images which are all duplicates of each other.
+type Group struct {
+	Name   string    `yaml:"Name"`
+	Images []Variant `yaml:"Images"`
+}
+
+// Variant is a single image in a group. Path is relative to the dataset
+// directory and Transform describes how the image was derived from the
+// group's source image. It is empty for the source image itself.
+type Variant struct {
+	Path      string `yaml:"Path"`
+	Transform string `yaml:"Transform,omitempty"`
+}
+
+// Pair is an unordered pair of image paths relative to the dataset directory.
+type Pair [2]string
+
+// NewPair returns a Pair with its paths in a consistent order.
+func NewPair(a, b string) Pair {
+	a, b = filepath.ToSlash(filepath.Clean(a)), filepath.ToSlash(filepath.Clean(b))
+	if b < a {
+		a, b = b, a
+	}
+	return Pair{a, b}
+}
+
+// Breakdown holds the number of true duplicate pairs of a given transform
+// which were found and missed.
+type Breakdown struct {
+	Transform string
+	Found     int
+	Missed    int
+}
+
+// Report summarizes how well a set of predicted pairs matches the manifest.
+type Report struct {
+	TP, FP, FN   int
+	Precision    float64
+	Recall       float64
+	F1           float64
+	Transforms   []Breakdown
+	Missed       []Pair
+	FalseMatches []Pair
+}
+
+// ReadManifest reads a ground truth manifest from a YAML file.
+func ReadManifest(path string) (Manifest, error) {
+	manifest := Manifest{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return manifest, err
+	}
+	err = yaml.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// WriteManifest writes a ground truth manifest to a YAML file.
+func WriteManifest(manifest Manifest, path string) error {
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// TruePairs returns every pair of images which share a group, mapped to the
+// transform label for that pair.
+func (m Manifest) TruePairs() map[Pair]string {
+	pairs := make(map[Pair]string)
+	for _, g := range m.Groups {
+		for i := 0; i < len(g.Images); i++ {
+			for j := i + 1; j < len(g.Images); j++ {
+				a, b := g.Images[i], g.Images[j]
+				pairs[NewPair(a.Path, b.Path)] = transformLabel(a.Transform, b.Transform)
+			}
+		}
+	}
+	return pairs
+}
+
+// Score compares the predicted pairs against the manifest.
+func Score(m Manifest, predicted []Pair) Report {
+	truth := m.TruePairs()
+	found := make(map[Pair]bool)
+	byTransform := make(map[string]*Breakdown)
+	r := Report{}
+
+	for _, p := range predicted {
+		p = NewPair(p[0], p[1])
+		if found[p] {
+			continue
+		}
+		found[p] = true
+		if _, ok := truth[p]; ok {
+			r.TP++
+		} else {
+			r.FP++
+			r.FalseMatches = append(r.FalseMatches, p)
+		}
+	}
+
+	for p, t := range truth {
+		b, ok := byTransform[t]
+		if !ok {
+			b = &Breakdown{Transform: t}
+			byTransform[t] = b
+		}
+		if found[p] {
+			b.Found++
+		} else {
+			b.Missed++
+			r.FN++
+			r.Missed = append(r.Missed, p)
+		}
+	}
+
+	for _, b := range byTransform {
+		r.Transforms = append(r.Transforms, *b)
+	}
+	sort.Slice(r.Transforms, func(i, j int) bool { return r.Transforms[i].Transform < r.Transforms[j].Transform })
+	sortPairs(r.Missed)
+	sortPairs(r.FalseMatches)
+
+	r.Precision = ratio(r.TP, r.TP+r.FP)
+	r.Recall = ratio(r.TP, r.TP+r.FN)
+	if r.Precision+r.Recall > 0 {
+		r.F1 = 2 * r.Precision * r.Recall / (r.Precision + r.Recall)
+	}
+	return r
+}
+
+// String returns the pair formatted for display.
+func (p Pair) String() string {
+	return fmt.Sprintf("%s <-> %s", p[0], p[1])
+}
+
+// transformLabel names the pair after the transforms applied to either image.
+func transformLabel(a, b string) string {
+	switch {
+	case a == "" && b == "":
+		return Original
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	}
+	labels := []string{a, b}
+	sort.Strings(labels)
+	return strings.Join(labels, "+")
+}
+
+func sortPairs(pairs []Pair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
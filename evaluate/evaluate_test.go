@@ -0,0 +1,105 @@
+package evaluate
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var manifest = Manifest{
+	Groups: []Group{
+		{
+			Name: "obi",
+			Images: []Variant{
+				{Path: "Obi1.jpg"},
+				{Path: "Obi2.jpg", Transform: "resize"},
+				{Path: "Obi3.jpg", Transform: "crop"},
+			},
+		},
+		{
+			Name: "jango",
+			Images: []Variant{
+				{Path: "Jango3.jpg"},
+				{Path: "Jango4.jpg"},
+			},
+		},
+	},
+}
+
+func TestTruePairs(t *testing.T) {
+	pairs := manifest.TruePairs()
+	if len(pairs) != 4 {
+		t.Fatal("expected 4 true pairs. got", len(pairs))
+	}
+	expect := map[Pair]string{
+		NewPair("Obi1.jpg", "Obi2.jpg"):     "resize",
+		NewPair("Obi1.jpg", "Obi3.jpg"):     "crop",
+		NewPair("Obi2.jpg", "Obi3.jpg"):     "crop+resize",
+		NewPair("Jango3.jpg", "Jango4.jpg"): Original,
+	}
+	for p, transform := range expect {
+		if pairs[p] != transform {
+			t.Errorf("pair %s should have transform %q. got %q", p, transform, pairs[p])
+		}
+	}
+}
+
+func TestNewPair(t *testing.T) {
+	if NewPair("b.jpg", "./a.jpg") != NewPair("a.jpg", "b.jpg") {
+		t.Error("pairs should be unordered and use clean paths")
+	}
+}
+
+func TestScore(t *testing.T) {
+	predicted := []Pair{
+		{"Obi2.jpg", "Obi1.jpg"},
+		{"Obi1.jpg", "Obi2.jpg"}, // Duplicate predictions are only counted once
+		{"Jango3.jpg", "Jango4.jpg"},
+		{"Obi1.jpg", "Jango3.jpg"},
+	}
+	r := Score(manifest, predicted)
+	if r.TP != 2 || r.FP != 1 || r.FN != 2 {
+		t.Fatalf("wrong counts: TP=%d FP=%d FN=%d", r.TP, r.FP, r.FN)
+	}
+	if r.Precision != 2.0/3.0 || r.Recall != 0.5 {
+		t.Errorf("wrong precision or recall: %f %f", r.Precision, r.Recall)
+	}
+	if r.F1 < 0.571 || r.F1 > 0.572 {
+		t.Error("F1 should be 4/7. is", r.F1)
+	}
+
+	if len(r.Transforms) != 4 {
+		t.Fatal("expected a breakdown for 4 transforms. got", len(r.Transforms))
+	}
+	for _, b := range r.Transforms {
+		switch b.Transform {
+		case "resize", Original:
+			if b.Found != 1 || b.Missed != 0 {
+				t.Errorf("%s should have 1 found and 0 missed: %+v", b.Transform, b)
+			}
+		case "crop", "crop+resize":
+			if b.Found != 0 || b.Missed != 1 {
+				t.Errorf("%s should have 0 found and 1 missed: %+v", b.Transform, b)
+			}
+		}
+	}
+	if len(r.FalseMatches) != 1 || r.FalseMatches[0] != NewPair("Jango3.jpg", "Obi1.jpg") {
+		t.Error("wrong false matches", r.FalseMatches)
+	}
+}
+
+func TestReadWriteManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ground_truth.yaml")
+	if err := WriteManifest(manifest, path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Groups) != 2 || got.Groups[0].Images[1].Transform != "resize" {
+		t.Error("manifest was not read back correctly", got)
+	}
+	if _, err := ReadManifest("notAfile.yaml"); err == nil {
+		t.Error("reading a non-existant manifest should return an error")
+	}
+}
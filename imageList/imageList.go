@@ -3,92 +3,337 @@ package imagelist
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"image"
 	"io/ioutil"
-	"math"
+	"sort"
 	"sync"
 )
 
-var (
-	m sync.Mutex
-	w sync.WaitGroup
-)
+// DefaultWindow is the number of images either side of the current one which
+// are loaded in the background when Options doesn't say otherwise.
+const DefaultWindow = 1
+
+// ErrOutOfRange is returned when an index outside of the list is requested.
+var ErrOutOfRange = errors.New("image list index out of range")
 
+// Options configure how an ImageList prefetches images.
+type Options struct {
+	// Behind and Ahead are the number of images before and after the current
+	// one which are loaded in the background. Negative values disable
+	// prefetching in that direction and zero values use DefaultWindow.
+	Behind int
+	Ahead  int
+	// MaxBytes limits the memory used by decoded images. Prefetching stops
+	// once the limit is reached and the images furthest from the current one
+	// are dropped first. The current image is always kept. Zero means no
+	// limit.
+	MaxBytes int64
+	// Load decodes the image at path. The default reads and decodes the file.
+	Load func(path string) (image.Image, error)
+}
+
+// ImageList loads the images in a list of paths in the background so that the
+// images around the current one are ready before they are needed. It is safe
+// for concurrent use.
 type ImageList struct {
-	index      int
-	paths      []string
-	imageCache []image.Image
+	mu    sync.Mutex
+	opts  Options
+	paths []string
+	index int
+	slots map[string]*slot
+}
+
+// slot holds an image which is loading or has been loaded. image, err and
+// size must only be read once done is closed.
+type slot struct {
+	done  chan struct{}
+	image image.Image
+	err   error
+	size  int64
 }
 
-// New creates a new ImageList type and initializes the image cache.
-func New(paths []string) (ImageList, error) {
-	if len(paths) < 3 {
-		return ImageList{}, errors.New("paths argument must contain at least 2 items")
+func (s *slot) loaded() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
 	}
+}
 
-	imgCache := make([]image.Image, 3)
-	for i := 0; i < 3; i++ {
-		img, err := loadImage(paths[i])
-		if err != nil {
-			return ImageList{}, errors.New(fmt.Sprint("could not load image", paths[i]))
-		}
-		imgCache[i] = img
+// New creates an ImageList positioned at the first path and starts loading the
+// images around it. Images which can't be loaded don't stop the list from
+// being created; their errors are returned when they are requested.
+func New(paths []string, opts Options) (*ImageList, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("paths argument must contain at least 1 item")
+	}
+	if opts.Behind == 0 {
+		opts.Behind = DefaultWindow
+	}
+	if opts.Ahead == 0 {
+		opts.Ahead = DefaultWindow
 	}
+	if opts.Behind < 0 {
+		opts.Behind = 0
+	}
+	if opts.Ahead < 0 {
+		opts.Ahead = 0
+	}
+	if opts.Load == nil {
+		opts.Load = loadImage
+	}
+
+	il := &ImageList{
+		opts:  opts,
+		paths: append([]string(nil), paths...),
+		slots: make(map[string]*slot),
+	}
+	il.mu.Lock()
+	il.prefetch()
+	il.mu.Unlock()
+	return il, nil
+}
+
+// Len returns the number of paths in the list.
+func (il *ImageList) Len() int {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	return len(il.paths)
+}
+
+// Index returns the index of the current image.
+func (il *ImageList) Index() int {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	return il.index
+}
 
-	return ImageList{
-		index:      0,
-		paths:      paths,
-		imageCache: imgCache,
-	}, nil
+// Current returns the current image and its path, waiting for it to load if
+// necessary.
+func (il *ImageList) Current() (image.Image, string, error) {
+	il.mu.Lock()
+	i := il.index
+	il.mu.Unlock()
+	return il.Get(i)
 }
 
-// Next returns the next image in the cache and it's path.
+// Next moves to the next image and returns it and its path. The last image is
+// returned again once the end of the list is reached.
 func (il *ImageList) Next() (image.Image, string, error) {
-	// TODO: If list is less than three images
-	w.Wait()
-	nextPath := il.paths[il.index]
-	if il.index < 2 {
-		nextImage := il.imageCache[il.index]
-		il.index++
-		return nextImage, nextPath, nil
-	} else if 2 <= il.index && il.index < len(il.paths)-1 {
-		nextImage := il.imageCache[2]
+	il.mu.Lock()
+	if il.index < len(il.paths)-1 {
 		il.index++
-		w.Add(1)
-		go il.appendImage()
-		return nextImage, nextPath, nil
-	} else if il.index == len(il.paths)-1 {
-		nextImage := il.imageCache[2]
-		return nextImage, nextPath, nil
+		il.prefetch()
 	}
-	return nil, "", errors.New(fmt.Sprint("image list index out of range: ", il.index))
+	i := il.index
+	il.mu.Unlock()
+	return il.Get(i)
 }
 
-// Previous returns the previous image in the cache and it's path.
+// Previous moves to the previous image and returns it and its path. The first
+// image is returned again once the start of the list is reached.
 func (il *ImageList) Previous() (image.Image, string, error) {
-	// TODO: If list is less than three images
-	prevPath := il.paths[int(math.Max(0, float64(il.index-1)))]
-	w.Wait()
-	if il.index == len(il.paths)-1 {
-		prevImage := il.imageCache[1]
-		il.index--
-		return prevImage, prevPath, nil
-	} else if 1 < il.index && il.index < len(il.paths)-1 {
-		prevImage := il.imageCache[0]
+	il.mu.Lock()
+	if il.index > 0 {
 		il.index--
-		w.Add(1)
-		go il.prependImage()
-		return prevImage, prevPath, nil
-	} else if il.index == 1 {
-		prevImage := il.imageCache[0]
-		il.index--
-		return prevImage, prevPath, nil
-	} else if il.index == 0 {
-		prevImage := il.imageCache[0]
-		return prevImage, prevPath, nil
+		il.prefetch()
+	}
+	i := il.index
+	il.mu.Unlock()
+	return il.Get(i)
+}
+
+// Seek moves to the image at index i and starts loading the images around it.
+// It doesn't wait for any of them to load.
+func (il *ImageList) Seek(i int) error {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	if i < 0 || i >= len(il.paths) {
+		return ErrOutOfRange
 	}
-	return nil, "", errors.New(fmt.Sprint("image list index out of range: ", il.index))
+	il.index = i
+	il.prefetch()
+	return nil
+}
+
+// Get returns the image at index i and its path without moving, waiting for
+// it to load if necessary. The error is the one returned when loading that
+// image.
+func (il *ImageList) Get(i int) (image.Image, string, error) {
+	il.mu.Lock()
+	if i < 0 || i >= len(il.paths) {
+		il.mu.Unlock()
+		return nil, "", ErrOutOfRange
+	}
+	path := il.paths[i]
+	s := il.fetch(path)
+	il.mu.Unlock()
+
+	<-s.done
+	return s.image, path, s.err
+}
+
+// SetPaths replaces the paths in the list and moves to index i. Images which
+// are already loaded are kept if their paths are still near the current one.
+func (il *ImageList) SetPaths(paths []string, i int) error {
+	if i < 0 || i >= len(paths) {
+		return ErrOutOfRange
+	}
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	il.paths = append([]string(nil), paths...)
+	il.index = i
+	il.prefetch()
+	return nil
+}
+
+// CachedBytes returns the memory used by the images which have been loaded.
+func (il *ImageList) CachedBytes() int64 {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	return il.cachedBytes()
+}
+
+// window returns the distance from the current image of every path within the
+// prefetch window.
+func (il *ImageList) window() map[string]int {
+	distances := make(map[string]int)
+	for i := il.index - il.opts.Behind; i <= il.index+il.opts.Ahead; i++ {
+		if i < 0 || i >= len(il.paths) {
+			continue
+		}
+		d := i - il.index
+		if d < 0 {
+			d = -d
+		}
+		if old, ok := distances[il.paths[i]]; !ok || d < old {
+			distances[il.paths[i]] = d
+		}
+	}
+	return distances
+}
+
+// prefetch drops the images outside the window and starts loading the ones
+// inside it, nearest first, until the memory limit is reached. il.mu must be
+// held.
+func (il *ImageList) prefetch() {
+	distances := il.window()
+	for path := range il.slots {
+		if _, ok := distances[path]; !ok {
+			delete(il.slots, path)
+		}
+	}
+	il.trim(distances)
+
+	paths := make([]string, 0, len(distances))
+	for path := range distances {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return distances[paths[i]] < distances[paths[j]] })
+	for _, path := range paths {
+		if distances[path] > 0 && il.opts.MaxBytes > 0 && il.cachedBytes() >= il.opts.MaxBytes {
+			break
+		}
+		il.fetch(path)
+	}
+}
+
+// fetch returns the slot for path, starting to load it if it isn't cached.
+// il.mu must be held.
+func (il *ImageList) fetch(path string) *slot {
+	if s, ok := il.slots[path]; ok {
+		return s
+	}
+	s := &slot{done: make(chan struct{})}
+	il.slots[path] = s
+	go func() {
+		s.image, s.err = il.opts.Load(path)
+		s.size = imageBytes(s.image)
+
+		// The slot is marked loaded while holding the lock so the cache is
+		// never seen over the limit
+		il.mu.Lock()
+		defer il.mu.Unlock()
+		close(s.done)
+		if il.slots[path] == s {
+			il.trim(il.window())
+		}
+	}()
+	return s
+}
+
+// trim drops the loaded images furthest from the current one until the cache
+// fits in the memory limit. il.mu must be held.
+func (il *ImageList) trim(distances map[string]int) {
+	if il.opts.MaxBytes <= 0 {
+		return
+	}
+	total := il.cachedBytes()
+	if total <= il.opts.MaxBytes {
+		return
+	}
+	// Images requested with Get from outside the window are dropped first
+	far := len(il.paths)
+	distance := func(path string) int {
+		if d, ok := distances[path]; ok {
+			return d
+		}
+		return far
+	}
+	paths := make([]string, 0, len(il.slots))
+	for path, s := range il.slots {
+		if s.loaded() && distance(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return distance(paths[i]) > distance(paths[j]) })
+	for _, path := range paths {
+		if total <= il.opts.MaxBytes {
+			break
+		}
+		total -= il.slots[path].size
+		delete(il.slots, path)
+	}
+}
+
+// cachedBytes adds up the size of the loaded images. il.mu must be held.
+func (il *ImageList) cachedBytes() int64 {
+	var total int64
+	for _, s := range il.slots {
+		if s.loaded() {
+			total += s.size
+		}
+	}
+	return total
+}
+
+// imageBytes estimates the memory used by a decoded image.
+func imageBytes(img image.Image) int64 {
+	switch img := img.(type) {
+	case nil:
+		return 0
+	case *image.RGBA:
+		return int64(len(img.Pix))
+	case *image.NRGBA:
+		return int64(len(img.Pix))
+	case *image.RGBA64:
+		return int64(len(img.Pix))
+	case *image.NRGBA64:
+		return int64(len(img.Pix))
+	case *image.Gray:
+		return int64(len(img.Pix))
+	case *image.Gray16:
+		return int64(len(img.Pix))
+	case *image.Paletted:
+		return int64(len(img.Pix))
+	case *image.YCbCr:
+		return int64(len(img.Y) + len(img.Cb) + len(img.Cr))
+	case *image.CMYK:
+		return int64(len(img.Pix))
+	}
+	size := img.Bounds().Size()
+	return int64(size.X) * int64(size.Y) * 4
 }
 
 func loadImage(path string) (image.Image, error) {
@@ -103,19 +348,3 @@ func loadImage(path string) (image.Image, error) {
 	}
 	return img, nil
 }
-
-func (il *ImageList) appendImage() {
-	defer w.Done()
-	aImg, _ := loadImage(il.paths[il.index])
-	m.Lock()
-	il.imageCache = append(il.imageCache[1:], aImg)
-	m.Unlock()
-}
-
-func (il *ImageList) prependImage() {
-	defer w.Done()
-	pImg, _ := loadImage(il.paths[il.index-1])
-	m.Lock()
-	il.imageCache = append([]image.Image{pImg}, il.imageCache[:2]...)
-	m.Unlock()
-}
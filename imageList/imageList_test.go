@@ -5,281 +5,260 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
-	"os"
+	"sync"
 	"testing"
 )
 
-func TestNewImageList(t *testing.T) {
-	paths := []string{
-		"./test_images/Obi1.jpg",
-		"./test_images/Obi2.jpg",
-		"./test_images/Jango3.jpg",
-	}
+var testPaths = []string{
+	"./test_images/Obi1.jpg",
+	"./test_images/Obi2.jpg",
+	"./test_images/Jango3.jpg",
+	"./test_images/Jango4.jpg",
+	"./test_images/Kylo5.jpg",
+}
 
-	imgList := make([]image.Image, 3)
-	for i, path := range paths {
-		imgFile, err := os.Open(path)
-		if err != nil {
-			t.Errorf("failed to open %s %s", path, err)
-		}
-		img, _, err := image.Decode(imgFile)
-		if err != nil {
-			t.Errorf("failed to decode %s %s", path, err)
-		}
-		imgFile.Close()
-		imgList[i] = img
-	}
+// countingLoader loads images from disk and counts how many times each path
+// was loaded.
+type countingLoader struct {
+	m     sync.Mutex
+	loads map[string]int
+}
 
-	expectIL := ImageList{
-		index:      0,
-		paths:      paths,
-		imageCache: imgList,
-	}
+func (c *countingLoader) load(path string) (image.Image, error) {
+	c.m.Lock()
+	if c.loads == nil {
+		c.loads = make(map[string]int)
+	}
+	c.loads[path]++
+	c.m.Unlock()
+	return loadImage(path)
+}
+
+func (c *countingLoader) count(path string) int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.loads[path]
+}
 
-	gotIL, err := New(paths)
+func TestNewImageList(t *testing.T) {
+	il, err := New(testPaths[:3], Options{})
 	if err != nil {
-		t.Error("could not create new imageList type")
+		t.Fatal("could not create new imageList type.", err)
 	}
-
-	// Compare index
-	if gotIL.index != 0 {
-		t.Error("index field was not instantiated correctly")
+	if il.Index() != 0 || il.Len() != 3 {
+		t.Error("list should start at the first of 3 images. got", il.Index(), il.Len())
 	}
-
-	// Compare paths
-	for i, p := range gotIL.paths {
-		if p != expectIL.paths[i] {
-			t.Error("path field was not instantiated correctly")
-		}
+	img, path, err := il.Current()
+	if err != nil || path != testPaths[0] || !compareImages(img, mustLoad(t, testPaths[0])) {
+		t.Error("current image should be the first image.", path, err)
 	}
 
-	// Compare cached images
-	for i, img := range gotIL.imageCache {
-		if !compareImages(img, gotIL.imageCache[i]) {
-			t.Error("image cache was not instantiated correctly")
+	// Lists shorter than the prefetch window work
+	for n := 1; n < 3; n++ {
+		il, err := New(testPaths[:n], Options{})
+		if err != nil {
+			t.Errorf("creating an ImageList with %d items failed. %s", n, err)
+			continue
+		}
+		il.Next()
+		il.Next()
+		if _, path, err := il.Current(); err != nil || path != testPaths[n-1] {
+			t.Errorf("list of %d items should stop at its last image. got %s %s", n, path, err)
 		}
 	}
 
-	// Load image list with less than 3 items
-	_, err = New(paths[:2])
-	if err == nil {
-		t.Error("creating an ImageList with less than 3 items should return an error")
-	}
-	// Load image list with un-loadable image
-	badPaths := append(paths[:2], "notANimagePath.jpg")
-	_, err = New(badPaths)
-	if err == nil {
-		t.Error("creating an ImageList with unopenable images should return an error")
+	if _, err := New(nil, Options{}); err == nil {
+		t.Error("creating an empty ImageList should return an error")
 	}
 }
 
 func TestNextandPrevious(t *testing.T) {
-	// Setup
-	paths := []string{
-		"./test_images/Obi1.jpg",
-		"./test_images/Obi2.jpg",
-		"./test_images/Jango3.jpg",
-		"./test_images/Jango4.jpg",
-		"./test_images/Kylo5.jpg",
-	}
-	expectImages := make([]image.Image, len(paths))
-	for i, p := range paths {
-		img, _ := loadImage(p)
-		expectImages[i] = img
-	}
-
-	// ----------
-	// Test Next
-	// ----------
-	il, _ := New(paths)
-	nextImage, nextPath, err := il.Next()
-	if err != nil {
-		t.Errorf("could not get next (first) image. %s", err)
-	}
-	if nextPath != paths[0] {
-		t.Error("got wrong next (first) image path")
-	}
-	if !compareImages(nextImage, expectImages[0]) {
-		t.Error("got wrong next (first) image")
+	il, _ := New(testPaths, Options{})
+	expectImages := make([]image.Image, len(testPaths))
+	for i, p := range testPaths {
+		expectImages[i] = mustLoad(t, p)
 	}
 
-	// Index should be 1
-	if il.index != 1 {
-		t.Error("index was not iterated. index =", il.index)
+	for i := 1; i < len(testPaths)+2; i++ {
+		want := i
+		if want >= len(testPaths) {
+			// Getting the next image beyond the end returns the last image
+			want = len(testPaths) - 1
+		}
+		img, path, err := il.Next()
+		if err != nil {
+			t.Errorf("could not get next image %d. %s", i, err)
+		}
+		if path != testPaths[want] || !compareImages(img, expectImages[want]) {
+			t.Errorf("got wrong next image %d. got %s", i, path)
+		}
+		if il.Index() != want {
+			t.Errorf("index should be %d. got %d", want, il.Index())
+		}
 	}
 
-	// Image cache should contain the first three images in the list
-	if len(il.imageCache) != 3 {
-		t.Error("image cache size should be 3. is ", len(il.imageCache))
-	}
-	for i, img := range il.imageCache {
-		if !compareImages(img, expectImages[i]) {
-			t.Error("image cache is not correct at index", i)
+	for i := len(testPaths) - 2; i > -3; i-- {
+		want := i
+		if want < 0 {
+			// Getting the previous image before the start returns the first image
+			want = 0
+		}
+		img, path, err := il.Previous()
+		if err != nil {
+			t.Errorf("could not get previous image %d. %s", i, err)
+		}
+		if path != testPaths[want] || !compareImages(img, expectImages[want]) {
+			t.Errorf("got wrong previous image %d. got %s", i, path)
+		}
+		if il.Index() != want {
+			t.Errorf("index should be %d. got %d", want, il.Index())
 		}
 	}
-	// Get second image
-	nextImage, nextPath, err = il.Next()
-	if err != nil {
-		t.Errorf("could not get next (second) image. %s", err)
-	}
-	if nextPath != paths[1] {
-		t.Error("got wrong next (second) image path")
-	}
-	if !compareImages(nextImage, expectImages[1]) {
-		t.Error("got wrong next (second) image")
-	}
+}
 
-	// Get third image
-	nextImage, nextPath, err = il.Next()
-	if err != nil {
-		t.Errorf("could not get next (third) image. %s", err)
+func TestSeek(t *testing.T) {
+	il, _ := New(testPaths, Options{})
+	if err := il.Seek(3); err != nil {
+		t.Fatal("could not seek to image 3.", err)
 	}
-	if nextPath != paths[2] {
-		t.Error("got wrong next (third) image path")
+	img, path, err := il.Current()
+	if err != nil || path != testPaths[3] || !compareImages(img, mustLoad(t, testPaths[3])) {
+		t.Error("current image should be image 3 after seeking.", path, err)
 	}
-	if !compareImages(nextImage, expectImages[2]) {
-		t.Error("got wrong next (third) image")
+	if _, path, _ := il.Get(0); path != testPaths[0] || il.Index() != 3 {
+		t.Error("get should return any image without moving.", path, il.Index())
 	}
 
-	// First image in the image cache should be the second image in the list
-	if !compareImages(il.imageCache[0], expectImages[1]) {
-		t.Error("first image in image cache should be second image in path list")
+	for _, i := range []int{-1, len(testPaths)} {
+		if err := il.Seek(i); err != ErrOutOfRange {
+			t.Errorf("seeking to %d should return ErrOutOfRange. got %v", i, err)
+		}
+		if _, _, err := il.Get(i); err != ErrOutOfRange {
+			t.Errorf("getting %d should return ErrOutOfRange. got %v", i, err)
+		}
 	}
-
-	// Last image in the image cache should be the fourth image in the list
-	if !compareImages(il.imageCache[len(il.imageCache)-1], expectImages[3]) {
-		t.Error("last image in the image cache should be fourth image in the path list")
+	if il.Index() != 3 {
+		t.Error("a failed seek should not move. index =", il.Index())
 	}
+}
 
-	// Get fourth image
-	nextImage, nextPath, err = il.Next()
-	if err != nil {
-		t.Errorf("could not get next (fourth) image. %s", err)
+func TestPrefetchWindow(t *testing.T) {
+	loader := &countingLoader{}
+	il, _ := New(testPaths, Options{Behind: 1, Ahead: 2, Load: loader.load})
+	il.Seek(2)
+	for i := range testPaths {
+		il.Get(i)
 	}
-	if nextPath != paths[3] {
-		t.Error("got wrong next (fourth) image path")
+
+	// Every image in the window was loaded once in the background and the
+	// images outside it were loaded when requested. Image 0 was loaded again
+	// after leaving the window.
+	for i, path := range testPaths {
+		want := 1
+		if i == 0 {
+			want = 2
+		}
+		if n := loader.count(path); n != want {
+			t.Errorf("image %d should have been loaded %d times. loaded %d times", i, want, n)
+		}
 	}
-	if !compareImages(nextImage, expectImages[3]) {
-		t.Error("got wrong next (fourth) image")
+	il.Seek(3)
+	for i := 1; i < len(testPaths); i++ {
+		il.Get(i)
 	}
-	// First image in image cache should be second to last image in list
-	if !compareImages(il.imageCache[0], expectImages[2]) {
-		t.Error("first image in image cache should be second to last image in path list")
+	if n := loader.count(testPaths[1]); n != 2 {
+		t.Error("image 1 should have been dropped after leaving the window. loaded", n)
 	}
-	// Last image in image cache should be last image in list
-	if !compareImages(il.imageCache[2], expectImages[4]) {
-		t.Error("last image in image cache should be last image in path list")
+	if n := loader.count(testPaths[4]); n != 1 {
+		t.Error("image 4 should have stayed cached inside the window. loaded", n)
 	}
+}
 
-	// Get fifth image
-	nextImage, nextPath, err = il.Next()
+func TestLoadErrors(t *testing.T) {
+	paths := []string{testPaths[0], "./test_images/notAnImage.jpg", "notAfile.jpg", testPaths[1]}
+	il, err := New(paths, Options{})
 	if err != nil {
-		t.Errorf("could not get next (fifth) image. %s", err)
-	}
-	if nextPath != paths[4] {
-		t.Error("got wrong next (fifth) image path")
+		t.Fatal("an image which can't be loaded should not stop the list being created.", err)
 	}
-	if !compareImages(nextImage, expectImages[4]) {
-		t.Error("got wrong next (fifth) image")
+	for i, path := range paths {
+		img, _, err := il.Get(i)
+		if bad := i == 1 || i == 2; bad != (err != nil) || bad != (img == nil) {
+			t.Errorf("%s: expected an error only for images which can't be loaded. got %v", path, err)
+		}
 	}
+}
 
-	// Getting next image beyond length of list should return the last image
-	nextImage, nextPath, err = il.Next()
-	if err != nil {
-		t.Errorf("could not get next (last) image after index is max. %s", err)
-	}
-	if nextPath != paths[4] {
-		t.Error("got wrong next (fifth) image path after index is max")
-	}
-	if !compareImages(nextImage, expectImages[4]) {
-		t.Error("got wrong next (last) image after index is max")
-	}
-	// Index should not be greater than len(paths) - 1
-	if il.index != len(il.paths)-1 {
-		t.Error("index should be len(paths) - 1")
-	}
+func TestMemoryLimit(t *testing.T) {
+	loader := &countingLoader{}
+	load := func(path string) (image.Image, error) {
+		loader.load(path)
+		return image.NewRGBA(image.Rect(0, 0, 100, 100)), nil
+	}
+	one := int64(100 * 100 * 4)
+	il, _ := New(testPaths, Options{Behind: 2, Ahead: 2, MaxBytes: 2 * one, Load: load})
+	il.Seek(2)
+	il.Current()
+	il.Get(3)
 
-	// ----------
-	// Test Previous
-	// ----------
-	// Get fourth image
-	prevImage, prevPath, err := il.Previous()
-	if err != nil {
-		t.Errorf("could not get previous (fourth) image. %s", err)
-	}
-	if prevPath != paths[3] {
-		t.Error("got wrong next (fourth) image path")
+	if cached := il.CachedBytes(); cached > 2*one {
+		t.Errorf("cache should stay within the limit of %d bytes. got %d", 2*one, cached)
 	}
-	if !compareImages(prevImage, expectImages[3]) {
-		t.Error("got wrong previous (fourth) image")
+	// The current image is kept and doesn't need loading again
+	if _, _, err := il.Current(); err != nil || loader.count(testPaths[2]) != 1 {
+		t.Error("the current image should stay cached.", err)
 	}
 
-	// First image in image cache should be second to last image in list
-	if !compareImages(il.imageCache[0], expectImages[2]) {
-		t.Error("first image in image cache should be second to last image in path list")
+	tiny, _ := New(testPaths, Options{MaxBytes: 1, Load: load})
+	if img, _, err := tiny.Current(); err != nil || img == nil {
+		t.Error("the current image should be returned even when it exceeds the limit.", err)
 	}
-	// Last image in image cache should be last image in list
-	if !compareImages(il.imageCache[2], expectImages[4]) {
-		t.Error("last image in image cache should be last image in path list")
+	if cached := tiny.CachedBytes(); cached != one {
+		t.Error("only the current image should be cached when it exceeds the limit. got", cached)
 	}
+}
 
-	// Get third image
-	prevImage, prevPath, err = il.Previous()
-	if err != nil {
-		t.Errorf("could not get previous (third) image. %s", err)
-	}
-	if prevPath != paths[2] {
-		t.Error("got wrong next (third) image path")
-	}
-	if !compareImages(prevImage, expectImages[2]) {
-		t.Error("got wrong previous (third) image")
-	}
-	// Get second image
-	prevImage, prevPath, err = il.Previous()
-	if err != nil {
-		t.Errorf("could not get previous (second) image. %s", err)
-	}
-	if prevPath != paths[1] {
-		t.Error("got wrong next (second) image path")
-	}
-	if !compareImages(prevImage, expectImages[1]) {
-		t.Error("got wrong previous (second) image")
-	}
-	// Get first image
-	prevImage, prevPath, err = il.Previous()
-	if err != nil {
-		t.Errorf("could not get previous (first) image. %s", err)
-	}
-	if prevPath != paths[0] {
-		t.Error("got wrong next (first) image path")
+func TestSetPaths(t *testing.T) {
+	loader := &countingLoader{}
+	il, _ := New(testPaths[:3], Options{Load: loader.load})
+	il.Get(1)
+
+	reordered := []string{testPaths[4], testPaths[1], testPaths[0]}
+	if err := il.SetPaths(reordered, 1); err != nil {
+		t.Fatal("could not set paths.", err)
 	}
-	if !compareImages(prevImage, expectImages[0]) {
-		t.Error("got wrong previous (first) image")
+	for i := range reordered {
+		il.Get(i)
 	}
-	// Getting previous image before zero should return the first image
-	prevImage, prevPath, err = il.Previous()
-	if err != nil {
-		t.Errorf("could not get previous (first) image after index is zero. %s", err)
+	if loader.count(testPaths[0]) != 1 || loader.count(testPaths[1]) != 1 {
+		t.Error("images still in the window should not be loaded again")
 	}
-	if prevPath != paths[0] {
-		t.Error("got wrong next (first) image path after index is zero")
+	if _, path, _ := il.Current(); path != testPaths[1] || il.Len() != 3 {
+		t.Error("set paths should move to the given index. got", path)
 	}
-	if !compareImages(prevImage, expectImages[0]) {
-		t.Error("got wrong previous (first) image after index is zero")
+	if err := il.SetPaths(reordered, 3); err != ErrOutOfRange {
+		t.Error("setting an index outside the new paths should return ErrOutOfRange. got", err)
 	}
+}
 
-	// First image in image cache should be first image in list
-	if !compareImages(il.imageCache[0], expectImages[0]) {
-		t.Error("first image in image cache should be first image in path list")
-	}
-	// Last image in image cache should be third image in list
-	if !compareImages(il.imageCache[2], expectImages[2]) {
-		t.Error("last image in image cache should be third image in path list")
+func TestIndependentLists(t *testing.T) {
+	a, _ := New(testPaths, Options{})
+	b, _ := New(testPaths, Options{})
+	var wg sync.WaitGroup
+	for _, il := range []*ImageList{a, b} {
+		wg.Add(1)
+		go func(il *ImageList) {
+			defer wg.Done()
+			for i := 0; i < len(testPaths); i++ {
+				il.Next()
+				il.Previous()
+				il.Next()
+			}
+		}(il)
 	}
-
-	// Index should not be less than zero
-	if il.index != 0 {
-		t.Error("index should be zero")
+	a.Next()
+	wg.Wait()
+	if b.Index() != len(testPaths)-1 {
+		t.Error("moving one list should not affect another. index =", b.Index())
 	}
 }
 
@@ -295,8 +274,17 @@ func TestLoadImage(t *testing.T) {
 	}
 }
 
+func mustLoad(t *testing.T, path string) image.Image {
+	t.Helper()
+	img, err := loadImage(path)
+	if err != nil {
+		t.Fatalf("failed to load %s %s", path, err)
+	}
+	return img
+}
+
 func compareImages(img1, img2 image.Image) bool {
-	if img1.Bounds() != img2.Bounds() {
+	if img1 == nil || img2 == nil || img1.Bounds() != img2.Bounds() {
 		return false
 	}
 	r := img1.Bounds()
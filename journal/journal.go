@@ -0,0 +1,324 @@
+// Package journal records what each run of delete-duplicates, move-duplicates
+// and the review window's actions did to the file system, so that a run can
+// be undone later. Each run is saved as a YAML file in the journal directory,
+// named by its ID.
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mike-lloyd03/dedugo/cleanup"
+	"github.com/mike-lloyd03/dedugo/metadata"
+	"github.com/mike-lloyd03/dedugo/trash"
+	"gopkg.in/yaml.v2"
+)
+
+// Operation is what a run did with the files it removed.
+type Operation string
+
+const (
+	// Delete deleted files permanently, so they can't be restored.
+	Delete Operation = "delete"
+	// Trash moved files to the trash.
+	Trash Operation = "trash"
+	// Move moved files to another directory.
+	Move Operation = "move"
+)
+
+// ErrNoRuns is returned by Latest when there is no run left to undo.
+var ErrNoRuns = errors.New("there are no runs to undo")
+
+// runExt is the extension of the files runs are saved in.
+const runExt = ".yaml"
+
+// Entry is a file removed by a run.
+type Entry struct {
+	// Source is where the file was and Dest is where it was moved to.
+	Source string `yaml:"Source"`
+	Dest   string `yaml:"Destination,omitempty"`
+	// Info is the trash info file of a trashed file.
+	Info string `yaml:"TrashInfo,omitempty"`
+	Size int64  `yaml:"Size"`
+	// Hash is the SHA-256 hash of the file's contents.
+	Hash string `yaml:"Hash"`
+	// Restored is set once the file has been moved back by Undo.
+	Restored bool `yaml:"Restored,omitempty"`
+}
+
+// Run is one run of a command which removed files.
+type Run struct {
+	// ID names the file the run is saved in. It is set by Add.
+	ID        string    `yaml:"-"`
+	Operation Operation `yaml:"Operation"`
+	// Results is the results file the removed files were found in.
+	Results string    `yaml:"ResultsFile,omitempty"`
+	Time    time.Time `yaml:"Time"`
+	// Undone is when every file of the run was restored.
+	Undone time.Time `yaml:"Undone,omitempty"`
+	// Entries must stay last, since a Writer appends them to the saved run.
+	Entries []Entry `yaml:"Entries,omitempty"`
+}
+
+// Bytes returns the total size of the files removed by the run.
+func (r Run) Bytes() int64 {
+	var n int64
+	for _, e := range r.Entries {
+		n += e.Size
+	}
+	return n
+}
+
+// Restored returns the number of files of the run which have been restored.
+func (r Run) Restored() int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Restored {
+			n++
+		}
+	}
+	return n
+}
+
+// Undo moves the files removed by the run back to where they were. A file is
+// only restored if it is unchanged and nothing has been created in its place
+// since. The run is marked as undone once every file has been restored. Undo
+// returns an error for each entry which could not be restored, in the same
+// order as the entries, and nil for the ones which were restored now or
+// before.
+func (r *Run) Undo() []error {
+	errs := make([]error, len(r.Entries))
+	for i := range r.Entries {
+		e := &r.Entries[i]
+		if e.Restored {
+			continue
+		}
+		if errs[i] = e.restore(r.Operation); errs[i] == nil {
+			e.Restored = true
+		}
+	}
+	if r.Restored() == len(r.Entries) {
+		r.Undone = time.Now()
+	}
+	return errs
+}
+
+// restore moves the file back to its source.
+func (e Entry) restore(op Operation) error {
+	if op == Delete {
+		return errors.New("it was deleted permanently")
+	}
+	hash, err := metadata.Hash(e.Dest)
+	if os.IsNotExist(err) {
+		// The run may have been interrupted before the file was removed
+		if hash, err := metadata.Hash(e.Source); err == nil && hash == e.Hash {
+			return nil
+		}
+		return fmt.Errorf("%s no longer exists", e.Dest)
+	} else if err != nil {
+		return err
+	}
+	if hash != e.Hash {
+		return fmt.Errorf("%s has changed since it was removed", e.Dest)
+	}
+
+	switch op {
+	case Trash:
+		return trash.Restore(trash.Item{Path: e.Source, File: e.Dest, Info: e.Info})
+	case Move:
+		return cleanup.MoveFile(e.Dest, e.Source, e.Hash)
+	}
+	return fmt.Errorf("unknown operation %q", op)
+}
+
+// Journal is a directory of saved runs.
+type Journal struct {
+	Dir string
+}
+
+// Default returns the user's journal, $XDG_STATE_HOME/dedugo/journal or
+// ~/.local/state/dedugo/journal.
+func Default() (Journal, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return Journal{filepath.Join(dir, "dedugo", "journal")}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Journal{}, err
+	}
+	return Journal{filepath.Join(home, ".local", "state", "dedugo", "journal")}, nil
+}
+
+// Add saves a new run and sets its ID, which is made from the time of the
+// run.
+func (j Journal) Add(run *Run) error {
+	if err := os.MkdirAll(j.Dir, 0755); err != nil {
+		return err
+	}
+	base := run.Time.Format("20060102-150405")
+	for n := 1; ; n++ {
+		id := base
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		// Creating the file exclusively reserves the ID
+		file, err := os.OpenFile(j.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		file.Close()
+		run.ID = id
+		return j.Save(*run)
+	}
+}
+
+// Writer records the entries of a run as its files are removed, so that a run
+// which is interrupted can still be undone.
+type Writer struct {
+	j    Journal
+	run  Run
+	file *os.File
+}
+
+// Begin adds a run without entries and returns a Writer which appends its
+// entries.
+func (j Journal) Begin(run Run) (*Writer, error) {
+	run.Entries = nil
+	if err := j.Add(&run); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(j.path(run.ID), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		os.Remove(j.path(run.ID))
+		return nil, err
+	}
+	if _, err := file.WriteString("Entries:\n"); err != nil {
+		file.Close()
+		os.Remove(j.path(run.ID))
+		return nil, err
+	}
+	return &Writer{j: j, run: run, file: file}, nil
+}
+
+// ID returns the ID of the run being written.
+func (w *Writer) ID() string {
+	return w.run.ID
+}
+
+// Record appends an entry to the saved run. It should be called just before
+// the entry's file is removed.
+func (w *Writer) Record(e Entry) error {
+	data, err := yaml.Marshal([]Entry{e})
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Write(data); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Finish saves the run with the entries of the files which were removed, and
+// removes the run if there are none.
+func (w *Writer) Finish(entries []Entry) error {
+	w.file.Close()
+	if len(entries) == 0 {
+		return os.Remove(w.j.path(w.run.ID))
+	}
+	w.run.Entries = entries
+	return w.j.Save(w.run)
+}
+
+// Save writes a run which was added before. The file is written to a
+// temporary file first and renamed into place so that an interrupted write
+// never loses the run.
+func (j Journal) Save(run Run) error {
+	data, err := yaml.Marshal(run)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(j.Dir, "."+run.ID+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path(run.ID))
+}
+
+// Get reads the run with the given ID.
+func (j Journal) Get(id string) (Run, error) {
+	var run Run
+	if id == "" || filepath.Base(id) != id {
+		return run, fmt.Errorf("there is no run %s", id)
+	}
+	data, err := ioutil.ReadFile(j.path(id))
+	if os.IsNotExist(err) {
+		return run, fmt.Errorf("there is no run %s", id)
+	} else if err != nil {
+		return run, err
+	}
+	if err := yaml.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("reading run %s: %w", id, err)
+	}
+	run.ID = id
+	return run, nil
+}
+
+// List returns every saved run, oldest first.
+func (j Journal) List() ([]Run, error) {
+	files, err := ioutil.ReadDir(j.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != runExt {
+			continue
+		}
+		run, err := j.Get(strings.TrimSuffix(name, runExt))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(a, b int) bool { return runs[a].Time.Before(runs[b].Time) })
+	return runs, nil
+}
+
+// Latest returns the most recent run which hasn't been undone, leaving out
+// runs which deleted files permanently.
+func (j Journal) Latest() (Run, error) {
+	runs, err := j.List()
+	if err != nil {
+		return Run{}, err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Undone.IsZero() && runs[i].Operation != Delete {
+			return runs[i], nil
+		}
+	}
+	return Run{}, ErrNoRuns
+}
+
+func (j Journal) path(id string) string {
+	return filepath.Join(j.Dir, id+runExt)
+}
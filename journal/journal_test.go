@@ -0,0 +1,195 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mike-lloyd03/dedugo/metadata"
+	"github.com/mike-lloyd03/dedugo/trash"
+)
+
+// removed writes a file at path with the given contents, hashes it and moves
+// it to dest, returning its entry.
+func removed(t *testing.T, path, dest, contents string) Entry {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := metadata.Hash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		t.Fatal(err)
+	}
+	return Entry{Source: path, Dest: dest, Size: int64(len(contents)), Hash: hash}
+}
+
+func TestJournal(t *testing.T) {
+	j := Journal{filepath.Join(t.TempDir(), "journal")}
+	if _, err := j.Latest(); err != ErrNoRuns {
+		t.Error("an empty journal should have no runs. got", err)
+	}
+
+	at := time.Date(2022, 3, 1, 9, 30, 0, 0, time.UTC)
+	first := Run{Operation: Move, Time: at, Entries: []Entry{{Source: "a", Dest: "b", Size: 10}, {Source: "c", Size: 5}}}
+	second := Run{Operation: Trash, Time: at}
+	for _, run := range []*Run{&first, &second} {
+		if err := j.Add(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.ID != "20220301-093000" || second.ID != "20220301-093000-2" {
+		t.Errorf("expected runs at the same time to get different IDs. got %s and %s", first.ID, second.ID)
+	}
+
+	got, err := j.Get(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Operation != Move || len(got.Entries) != 2 || got.Entries[0].Dest != "b" || got.Bytes() != 15 {
+		t.Errorf("unexpected run read back %+v", got)
+	}
+	if _, err := j.Get("../" + first.ID); err == nil {
+		t.Error("a run should only be read from the journal directory")
+	}
+
+	second.Undone = at
+	if err := j.Save(second); err != nil {
+		t.Fatal(err)
+	}
+	if latest, err := j.Latest(); err != nil || latest.ID != first.ID {
+		t.Errorf("expected the latest run which hasn't been undone. got %+v %v", latest, err)
+	}
+	deleted := Run{Operation: Delete, Time: at.Add(time.Hour)}
+	if err := j.Add(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if latest, err := j.Latest(); err != nil || latest.ID != first.ID {
+		t.Errorf("a run which deleted files permanently can't be undone. got %+v %v", latest, err)
+	}
+	if runs, err := j.List(); err != nil || len(runs) != 3 {
+		t.Errorf("expected every run to be listed. got %d %v", len(runs), err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	j := Journal{t.TempDir()}
+	w, err := j.Begin(Run{Operation: Move, Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := Entry{Source: "a", Dest: "b", Size: 1, Hash: "x"}
+	second := Entry{Source: "c", Dest: "d", Size: 2, Hash: "y"}
+	for _, e := range []Entry{first, second} {
+		if err := w.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An interrupted run keeps every entry recorded
+	run, err := j.Get(w.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Entries) != 2 || run.Entries[1] != second {
+		t.Fatalf("expected both entries to be saved as they were recorded. got %+v", run.Entries)
+	}
+
+	if err := w.Finish([]Entry{first}); err != nil {
+		t.Fatal(err)
+	}
+	if run, err := j.Get(w.ID()); err != nil || len(run.Entries) != 1 || run.Entries[0] != first {
+		t.Errorf("expected only the removed file to be kept. got %+v %v", run, err)
+	}
+
+	empty, err := j.Begin(Run{Operation: Move, Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty.Finish(nil)
+	if _, err := j.Get(empty.ID()); err == nil {
+		t.Error("a run which removed nothing should not be kept")
+	}
+}
+
+func TestUndoInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a")
+	ioutil.WriteFile(path, []byte("a"), 0644)
+	hash, _ := metadata.Hash(path)
+	run := Run{Operation: Move, Entries: []Entry{{Source: path, Dest: filepath.Join(dir, "moved", "a"), Hash: hash}}}
+	if errs := run.Undo(); errs[0] != nil || run.Undone.IsZero() {
+		t.Error("a file which was never moved should count as restored.", errs[0])
+	}
+}
+
+func TestUndoMove(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	run := Run{Operation: Move, Entries: []Entry{
+		removed(t, filepath.Join(src, "a"), filepath.Join(dest, "a"), "a"),
+		removed(t, filepath.Join(src, "b"), filepath.Join(dest, "b"), "b"),
+		removed(t, filepath.Join(src, "c"), filepath.Join(dest, "c"), "c"),
+		removed(t, filepath.Join(src, "d"), filepath.Join(dest, "d"), "d"),
+	}}
+	// b was replaced at its source, c was edited and d was deleted
+	ioutil.WriteFile(filepath.Join(src, "b"), []byte("new b"), 0644)
+	ioutil.WriteFile(filepath.Join(dest, "c"), []byte("edited"), 0644)
+	os.Remove(filepath.Join(dest, "d"))
+
+	errs := run.Undo()
+	if errs[0] != nil || !run.Entries[0].Restored {
+		t.Error("a should have been restored.", errs[0])
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(src, "a")); string(data) != "a" {
+		t.Error("a should be back where it was")
+	}
+	for i := 1; i < 4; i++ {
+		if errs[i] == nil || run.Entries[i].Restored {
+			t.Errorf("entry %d should not have been restored", i)
+		}
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(src, "b")); string(data) != "new b" {
+		t.Error("a restored file should never replace another")
+	}
+	if !run.Undone.IsZero() || run.Restored() != 1 {
+		t.Error("a run should not be marked as undone until every file is restored")
+	}
+
+	// Undoing again only retries the files which weren't restored
+	if errs := run.Undo(); errs[0] != nil {
+		t.Error("a restored file should not be restored twice.", errs[0])
+	}
+}
+
+func TestUndoTrash(t *testing.T) {
+	dir := t.TempDir()
+	tr := trash.Trash{Home: filepath.Join(dir, "Trash"), UID: 1000}
+	path := filepath.Join(dir, "photos", "a.jpg")
+	os.Mkdir(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, []byte("a"), 0644)
+	hash, _ := metadata.Hash(path)
+	item, err := tr.Put(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := Run{Operation: Trash, Entries: []Entry{{Source: item.Path, Dest: item.File, Info: item.Info, Hash: hash}}}
+	if errs := run.Undo(); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("the file should be restored from the trash.", err)
+	}
+	if _, err := os.Stat(item.Info); !os.IsNotExist(err) {
+		t.Error("the trash info file should be removed")
+	}
+
+	deleted := Run{Operation: Delete, Entries: []Entry{{Source: path}}}
+	if errs := deleted.Undo(); errs[0] == nil {
+		t.Error("a permanently deleted file can't be restored")
+	}
+}
@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrium/goheif"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Exif holds the EXIF fields dedugo uses to tell images apart.
+type Exif struct {
+	Make  string
+	Model string
+	// CaptureTime is the original capture time including any sub-second
+	// component. It is the zero time if the image has no capture date.
+	CaptureTime time.Time
+	// HasSubSec reports whether CaptureTime includes sub-second precision.
+	HasSubSec bool
+	HasGPS    bool
+}
+
+// ReadExif reads the EXIF data from a JPEG or HEIC file.
+func ReadExif(path string) (Exif, error) {
+	info := Exif{}
+	file, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+
+	var x *exif.Exif
+	if strings.ToLower(filepath.Ext(path)) == ".heic" {
+		raw, err := goheif.ExtractExif(file)
+		if err != nil {
+			return info, err
+		}
+		x, err = exif.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return info, err
+		}
+	} else {
+		x, err = exif.Decode(file)
+		if err != nil {
+			return info, err
+		}
+	}
+
+	info.Make = tagString(x, exif.Make)
+	info.Model = tagString(x, exif.Model)
+	if t, err := x.DateTime(); err == nil {
+		info.CaptureTime = t
+		if subSec := tagString(x, exif.SubSecTimeOriginal); subSec != "" {
+			if frac, err := strconv.ParseFloat("0."+subSec, 64); err == nil {
+				info.CaptureTime = t.Add(time.Duration(frac * float64(time.Second)))
+				info.HasSubSec = true
+			}
+		}
+	}
+	if _, _, err := x.LatLong(); err == nil {
+		info.HasGPS = true
+	}
+	return info, nil
+}
+
+// Camera returns the camera make and model separated by a space.
+func (e Exif) Camera() string {
+	return strings.TrimSpace(e.Make + " " + e.Model)
+}
+
+// IsBurst reports whether two images look like separate frames of a burst or
+// bracketed sequence. Both must come from the same camera and have been
+// captured within window of each other, but not at the same instant since
+// copies of a single image share their capture time.
+func IsBurst(a, b Exif, window time.Duration) bool {
+	if a.CaptureTime.IsZero() || b.CaptureTime.IsZero() {
+		return false
+	}
+	if a.Camera() == "" || a.Camera() != b.Camera() {
+		return false
+	}
+	gap := a.CaptureTime.Sub(b.CaptureTime)
+	if gap < 0 {
+		gap = -gap
+	}
+	return gap > 0 && gap <= window
+}
+
+func tagString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	s, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(s, "\x00"))
+}
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsBurst(t *testing.T) {
+	base := time.Date(2021, 7, 4, 12, 0, 0, 0, time.UTC)
+	shot := func(offset time.Duration) Exif {
+		return Exif{Make: "Apple", Model: "iPhone 12", CaptureTime: base.Add(offset), HasSubSec: true}
+	}
+
+	if !IsBurst(shot(0), shot(300*time.Millisecond), 2*time.Second) {
+		t.Error("frames 300ms apart from the same camera should be a burst")
+	}
+	if IsBurst(shot(0), shot(0), 2*time.Second) {
+		t.Error("images captured at the same instant are copies, not a burst")
+	}
+	if IsBurst(shot(0), shot(5*time.Second), 2*time.Second) {
+		t.Error("frames further apart than the window should not be a burst")
+	}
+
+	other := shot(300 * time.Millisecond)
+	other.Model = "Pixel 5"
+	if IsBurst(shot(0), other, 2*time.Second) {
+		t.Error("frames from different cameras should not be a burst")
+	}
+	if IsBurst(shot(0), Exif{}, 2*time.Second) {
+		t.Error("images without EXIF data should not be a burst")
+	}
+}
+
+func TestReadExif(t *testing.T) {
+	if _, err := ReadExif("notAfile.jpg"); err == nil {
+		t.Error("reading a non-existant file should return an error")
+	}
+	if _, err := ReadExif("../imageList/test_images/notAnImage.jpg"); err == nil {
+		t.Error("reading a file that is not an image should return an error")
+	}
+
+	info, err := ReadExif("../imageList/test_images/Obi2.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Obi2.jpg was exported by an editor and has no camera or location data
+	if info.Camera() != "" || info.HasGPS {
+		t.Errorf("wrong EXIF data read: %+v", info)
+	}
+}
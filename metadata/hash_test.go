@@ -0,0 +1,24 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a")
+	if err := ioutil.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Hash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; got != want {
+		t.Errorf("expected %s. got %s", want, got)
+	}
+	if _, err := Hash(path + ".missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"time"
+)
+
+// Info describes an image file without decoding its pixels.
+type Info struct {
+	Path    string
+	Width   int
+	Height  int
+	Format  string
+	Size    int64
+	ModTime time.Time
+	// Exif is empty if the file has no EXIF data.
+	Exif Exif
+}
+
+// ReadInfo reads the file attributes, dimensions and EXIF data of an image.
+func ReadInfo(path string) (Info, error) {
+	info := Info{Path: path}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return info, err
+	}
+	info.Size = stat.Size()
+	info.ModTime = stat.ModTime()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return info, err
+	}
+	info.Width, info.Height, info.Format = config.Width, config.Height, format
+
+	// Plenty of images have no EXIF data so a failure here is not an error
+	info.Exif, _ = ReadExif(path)
+	return info, nil
+}
+
+// Pixels returns the number of pixels in the image.
+func (i Info) Pixels() int {
+	return i.Width * i.Height
+}
+
+// Side identifies one image of a pair.
+type Side int
+
+const (
+	// Neither means both images are equally good on an attribute.
+	Neither Side = iota
+	Left
+	Right
+)
+
+// Comparison records which of two images is the better copy to keep for each
+// attribute.
+type Comparison struct {
+	// Resolution prefers the image with more pixels.
+	Resolution Side
+	// FileSize prefers the larger file since it has usually been compressed
+	// less.
+	FileSize Side
+	// Modified prefers the file which was modified first since it is more
+	// likely to be the original.
+	Modified Side
+	// Captured prefers the image with a capture date.
+	Captured Side
+	// Camera prefers the image with a camera make or model.
+	Camera Side
+	// GPS prefers the image with a location.
+	GPS Side
+}
+
+// Compare compares the attributes of two images.
+func Compare(a, b Info) Comparison {
+	return Comparison{
+		Resolution: larger(int64(a.Pixels()), int64(b.Pixels())),
+		FileSize:   larger(a.Size, b.Size),
+		Modified:   larger(b.ModTime.Unix(), a.ModTime.Unix()),
+		Captured:   present(!a.Exif.CaptureTime.IsZero(), !b.Exif.CaptureTime.IsZero()),
+		Camera:     present(a.Exif.Camera() != "", b.Exif.Camera() != ""),
+		GPS:        present(a.Exif.HasGPS, b.Exif.HasGPS),
+	}
+}
+
+func larger(a, b int64) Side {
+	switch {
+	case a > b:
+		return Left
+	case b > a:
+		return Right
+	}
+	return Neither
+}
+
+func present(a, b bool) Side {
+	switch {
+	case a && !b:
+		return Left
+	case b && !a:
+		return Right
+	}
+	return Neither
+}
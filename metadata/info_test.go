@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadInfo(t *testing.T) {
+	info, err := ReadInfo("../imageList/test_images/Obi1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "jpeg" {
+		t.Error("expected jpeg format. got", info.Format)
+	}
+	if info.Width == 0 || info.Height == 0 || info.Size == 0 {
+		t.Errorf("expected dimensions and size to be read: %+v", info)
+	}
+
+	if _, err := ReadInfo("notAfile.jpg"); err == nil {
+		t.Error("reading a non-existant file should return an error")
+	}
+	if _, err := ReadInfo("../imageList/test_images/notAnImage.jpg"); err == nil {
+		t.Error("reading a file that is not an image should return an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Now()
+	a := Info{Width: 4000, Height: 3000, Size: 2000000, ModTime: now.Add(-time.Hour),
+		Exif: Exif{Make: "Apple", Model: "iPhone 12", CaptureTime: now, HasGPS: true}}
+	b := Info{Width: 1000, Height: 750, Size: 3000000, ModTime: now}
+
+	c := Compare(a, b)
+	want := Comparison{Resolution: Left, FileSize: Right, Modified: Left, Captured: Left, Camera: Left, GPS: Left}
+	if c != want {
+		t.Errorf("expected %+v. got %+v", want, c)
+	}
+
+	if c := Compare(a, a); c != (Comparison{}) {
+		t.Errorf("identical images should have no preference. got %+v", c)
+	}
+}
@@ -0,0 +1,101 @@
+package review
+
+// Cluster is a group of images connected to each other by pairs. When one
+// photo has several copies they all end up in the same cluster.
+type Cluster struct {
+	// Images lists each image in the cluster once, starting with the
+	// reference image of the pair the cluster was built from.
+	Images []string
+	// Pairs are the indices into ImagePairs of every pair between images in
+	// the cluster.
+	Pairs []int
+}
+
+// Cluster returns every image reachable from the pair at index by following
+// pairs which share an image.
+func (r Results) Cluster(index int) Cluster {
+	byImage := make(map[string][]int)
+	for i, p := range r.ImagePairs {
+		byImage[p.RefImage] = append(byImage[p.RefImage], i)
+		byImage[p.DupeImage] = append(byImage[p.DupeImage], i)
+	}
+
+	c := Cluster{}
+	seenImages := make(map[string]bool)
+	seenPairs := make(map[int]bool)
+	start := r.ImagePairs[index]
+	pending := []string{start.RefImage, start.DupeImage}
+	for len(pending) > 0 {
+		img := pending[0]
+		pending = pending[1:]
+		if seenImages[img] {
+			continue
+		}
+		seenImages[img] = true
+		c.Images = append(c.Images, img)
+		for _, i := range byImage[img] {
+			if seenPairs[i] {
+				continue
+			}
+			seenPairs[i] = true
+			c.Pairs = append(c.Pairs, i)
+			p := r.ImagePairs[i]
+			pending = append(pending, p.RefImage, p.DupeImage)
+		}
+	}
+	return c
+}
+
+// ResolveCluster records the decisions made on a whole cluster at once.
+// keeper is the image to keep and duplicates are the images to remove. A pair
+// whose reference image is removed is marked as keeping its duplicate image,
+// so the pair's images and their recorded states stay as they were scanned
+// and the delete and move commands remove the right one. Pairs between the
+// keeper and an image which is not a duplicate are marked not duplicate and
+// other pairs are left unchanged. The indices of the changed pairs are
+// returned.
+func (r *Results) ResolveCluster(c Cluster, keeper string, duplicates []string, reviewer string) []int {
+	remove := make(map[string]bool)
+	for _, d := range duplicates {
+		if d != keeper {
+			remove[d] = true
+		}
+	}
+
+	changed := make([]int, 0)
+	covered := make(map[string]bool)
+	// Pairs between two removed images are decided last so that they can
+	// remove an image no other pair removes.
+	both := make([]int, 0)
+	for _, i := range c.Pairs {
+		p := &r.ImagePairs[i]
+		switch {
+		case remove[p.RefImage] && remove[p.DupeImage]:
+			both = append(both, i)
+			continue
+		case remove[p.DupeImage]:
+			p.SetReview(Duplicate, reviewer)
+		case remove[p.RefImage]:
+			p.SetKeep(KeepDuplicate, reviewer)
+		case p.RefImage == keeper || p.DupeImage == keeper:
+			p.SetReview(NotDuplicate, reviewer)
+		default:
+			continue
+		}
+		if p.Review == Duplicate {
+			covered[p.Removed()] = true
+		}
+		changed = append(changed, i)
+	}
+	for _, i := range both {
+		p := &r.ImagePairs[i]
+		if covered[p.DupeImage] && !covered[p.RefImage] {
+			p.SetKeep(KeepDuplicate, reviewer)
+		} else {
+			p.SetReview(Duplicate, reviewer)
+		}
+		covered[p.Removed()] = true
+		changed = append(changed, i)
+	}
+	return changed
+}
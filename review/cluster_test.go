@@ -0,0 +1,86 @@
+package review
+
+import (
+	"reflect"
+	"testing"
+)
+
+func clusterResults() Results {
+	return Results{ImagePairs: []Pair{
+		{RefImage: "a.jpg", DupeImage: "a_copy.jpg", Review: Unreviewed},
+		{RefImage: "x.jpg", DupeImage: "y.jpg", Review: Unreviewed},
+		{RefImage: "a_small.jpg", DupeImage: "a.jpg", Review: Unreviewed},
+		{RefImage: "a_copy.jpg", DupeImage: "a_small.jpg", Review: Unreviewed},
+		{RefImage: "a_crop.jpg", DupeImage: "a_copy.jpg", Review: Unreviewed},
+	}}
+}
+
+func TestCluster(t *testing.T) {
+	r := clusterResults()
+	c := r.Cluster(0)
+	wantImages := []string{"a.jpg", "a_copy.jpg", "a_small.jpg", "a_crop.jpg"}
+	if !reflect.DeepEqual(c.Images, wantImages) {
+		t.Errorf("expected images %v. got %v", wantImages, c.Images)
+	}
+	if !reflect.DeepEqual(c.Pairs, []int{0, 2, 3, 4}) {
+		t.Errorf("expected pairs [0 2 3 4]. got %v", c.Pairs)
+	}
+
+	if c := r.Cluster(1); len(c.Images) != 2 || len(c.Pairs) != 1 {
+		t.Errorf("an unconnected pair should be its own cluster: %+v", c)
+	}
+}
+
+func TestResolveCluster(t *testing.T) {
+	r := clusterResults()
+	c := r.Cluster(0)
+	changed := r.ResolveCluster(c, "a.jpg", []string{"a_copy.jpg", "a_small.jpg"}, "obi")
+	if len(changed) != 4 {
+		t.Errorf("expected 4 changed pairs. got %v", changed)
+	}
+
+	removed := make(map[string]bool)
+	for _, i := range c.Pairs {
+		p := r.ImagePairs[i]
+		if p.Review == Duplicate {
+			removed[p.Removed()] = true
+			if p.Removed() == "a.jpg" {
+				t.Errorf("the keeper should never be removed: %+v", p)
+			}
+		}
+		if p.Reviewer != "obi" {
+			t.Errorf("every pair in the cluster should be reviewed: %+v", p)
+		}
+	}
+	if !removed["a_copy.jpg"] || !removed["a_small.jpg"] || len(removed) != 2 {
+		t.Error("each duplicate should be removed by some pair. got", removed)
+	}
+
+	// a_crop.jpg was not marked so its pair with a_copy.jpg removes a_copy.jpg
+	crop := r.ImagePairs[4]
+	if crop.Removed() != "a_copy.jpg" || crop.Review != Duplicate {
+		t.Errorf("a pair between a kept and removed image should remove the latter: %+v", crop)
+	}
+
+	// The pair removing its reference image keeps its images in place
+	small := r.ImagePairs[2]
+	if small.RefImage != "a_small.jpg" || small.Keep != KeepDuplicate || small.Removed() != "a_small.jpg" {
+		t.Errorf("the pair should keep its duplicate image instead of being swapped: %+v", small)
+	}
+
+	if r.ImagePairs[1].Review != Unreviewed {
+		t.Error("pairs outside the cluster should not change")
+	}
+}
+
+func TestResolveClusterNotDuplicate(t *testing.T) {
+	r := clusterResults()
+	c := r.Cluster(0)
+	r.ResolveCluster(c, "a_copy.jpg", nil, "obi")
+	if r.ImagePairs[0].Review != NotDuplicate || r.ImagePairs[3].Review != NotDuplicate {
+		t.Error("pairs with the keeper should be marked not duplicate when nothing is removed")
+	}
+	if r.ImagePairs[2].Review != Unreviewed {
+		t.Error("pairs not involving the keeper or a duplicate should not change")
+	}
+}
@@ -0,0 +1,70 @@
+package review
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mike-lloyd03/dedugo/metadata"
+)
+
+// FileState records an image file as it was when its pair was found, so that
+// changes made to it before its duplicates are removed can be noticed.
+type FileState struct {
+	Size    int64     `yaml:"Size"`
+	ModTime time.Time `yaml:"ModTime"`
+	// Hash is the SHA-256 hash of the file's contents.
+	Hash string `yaml:"Hash"`
+}
+
+// Snapshot returns the current state of the file at path.
+func Snapshot(path string) (FileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileState{}, err
+	}
+	hash, err := metadata.Hash(path)
+	if err != nil {
+		return FileState{}, err
+	}
+	return FileState{Size: info.Size(), ModTime: info.ModTime(), Hash: hash}, nil
+}
+
+// Check returns an error describing how the file at path differs from the
+// recorded state. Files of the same size and modification time are assumed
+// to be unchanged. Otherwise the contents are hashed, so a file which was only
+// touched or copied is not reported.
+func (f FileState) Check(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s no longer exists", path)
+	} else if err != nil {
+		return err
+	}
+	if info.Size() != f.Size {
+		return fmt.Errorf("%s has changed since the scan", path)
+	}
+	if info.ModTime().Equal(f.ModTime) {
+		return nil
+	}
+	hash, err := metadata.Hash(path)
+	if err != nil {
+		return err
+	}
+	if hash != f.Hash {
+		return fmt.Errorf("%s has changed since the scan", path)
+	}
+	return nil
+}
+
+// FileOf returns the recorded state of the pair's image at path, or nil if the
+// pair was found before states were recorded.
+func (p Pair) FileOf(path string) *FileState {
+	switch path {
+	case p.RefImage:
+		return p.RefFile
+	case p.DupeImage:
+		return p.DupeFile
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package review
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.jpg")
+	if err := ioutil.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := Snapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Size != 3 || state.Hash == "" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if err := state.Check(path); err != nil {
+		t.Error("an unchanged file should pass.", err)
+	}
+
+	later := state.ModTime.Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if err := state.Check(path); err != nil {
+		t.Error("a file which was only touched should pass.", err)
+	}
+
+	// Same size and contents of a different image
+	ioutil.WriteFile(path, []byte("xyz"), 0644)
+	os.Chtimes(path, later.Add(time.Hour), later.Add(time.Hour))
+	if err := state.Check(path); err == nil {
+		t.Error("a file with different contents should fail")
+	}
+	ioutil.WriteFile(path, []byte("abcd"), 0644)
+	if err := state.Check(path); err == nil {
+		t.Error("a file of a different size should fail")
+	}
+	os.Remove(path)
+	if err := state.Check(path); err == nil {
+		t.Error("a missing file should fail")
+	}
+
+	p := Pair{RefImage: "a", DupeImage: "b", RefFile: &state}
+	if p.FileOf("a") != &state || p.FileOf("b") != nil || p.FileOf("c") != nil {
+		t.Error("FileOf should return the state recorded for each image")
+	}
+}
@@ -0,0 +1,242 @@
+package review
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortKey is the order pairs are reviewed in.
+type SortKey string
+
+const (
+	// SortNone keeps the order pairs were written to the results file in.
+	SortNone SortKey = ""
+	// SortConfidence reviews the most confident pairs first.
+	SortConfidence SortKey = "confidence"
+	// SortDirectory groups pairs by the directory of the reference image.
+	SortDirectory SortKey = "directory"
+	// SortFilename orders pairs by the file name of the reference image.
+	SortFilename SortKey = "filename"
+)
+
+// SortKeys lists every sort order.
+var SortKeys = []SortKey{SortNone, SortConfidence, SortDirectory, SortFilename}
+
+// ParseSortKey returns the sort order with the given name. "none" is accepted
+// for SortNone.
+func ParseSortKey(name string) (SortKey, bool) {
+	if name == "none" {
+		return SortNone, true
+	}
+	for _, k := range SortKeys {
+		if string(k) == name {
+			return k, true
+		}
+	}
+	return SortNone, false
+}
+
+// Filter selects and orders the pairs a reviewer works through. It is saved in
+// the results file so that the same view is restored in the next session.
+type Filter struct {
+	Sort SortKey `yaml:"Sort,omitempty"`
+	// States limits the queue to pairs in one of these states. Empty means
+	// every state.
+	States        []State `yaml:"States,omitempty"`
+	MinConfidence int     `yaml:"MinConfidence,omitempty"`
+	// MaxConfidence is the highest confidence included. 0 means no limit.
+	MaxConfidence int `yaml:"MaxConfidence,omitempty"`
+	// PathPrefix limits the queue to pairs where either image path starts
+	// with the prefix.
+	PathPrefix string `yaml:"PathPrefix,omitempty"`
+	// MaxDistance is the largest distance between a pair's images which is
+	// included. 0 means no limit.
+	MaxDistance float32 `yaml:"MaxDistance,omitempty"`
+}
+
+// Match reports whether the pair passes the filter.
+func (f Filter) Match(p Pair) bool {
+	if len(f.States) > 0 {
+		found := false
+		for _, s := range f.States {
+			if p.Review == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if p.Confidence < f.MinConfidence {
+		return false
+	}
+	if f.MaxConfidence > 0 && p.Confidence > f.MaxConfidence {
+		return false
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(p.RefImage, f.PathPrefix) && !strings.HasPrefix(p.DupeImage, f.PathPrefix) {
+		return false
+	}
+	if f.MaxDistance > 0 && p.Distance > f.MaxDistance {
+		return false
+	}
+	return true
+}
+
+// String describes the filter for display.
+func (f Filter) String() string {
+	desc := make([]string, 0)
+	if f.Sort != SortNone {
+		desc = append(desc, "sorted by "+string(f.Sort))
+	}
+	if len(f.States) > 0 {
+		states := make([]string, len(f.States))
+		for i, s := range f.States {
+			states[i] = string(s)
+		}
+		desc = append(desc, strings.Join(states, " or "))
+	}
+	if f.MinConfidence > 0 || f.MaxConfidence > 0 {
+		max := "max"
+		if f.MaxConfidence > 0 {
+			max = strconv.Itoa(f.MaxConfidence)
+		}
+		desc = append(desc, fmt.Sprintf("confidence %d-%s", f.MinConfidence, max))
+	}
+	if f.PathPrefix != "" {
+		desc = append(desc, "under "+f.PathPrefix)
+	}
+	if f.MaxDistance > 0 {
+		desc = append(desc, fmt.Sprintf("distance up to %.0f", f.MaxDistance))
+	}
+	if len(desc) == 0 {
+		return "all pairs"
+	}
+	return strings.Join(desc, ", ")
+}
+
+// Matching returns the indices into ImagePairs of the pairs which pass f, in
+// file order. Unlike Queue, the current pair is only included if it passes.
+func (r Results) Matching(f Filter) []int {
+	indices := make([]int, 0)
+	for i, p := range r.ImagePairs {
+		if f.Match(p) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// MaxDistance returns the largest distance between the images of any pair,
+// or 0 if the results were written before distances were recorded.
+func (r Results) MaxDistance() float32 {
+	var max float32
+	for _, p := range r.ImagePairs {
+		if p.Distance > max {
+			max = p.Distance
+		}
+	}
+	return max
+}
+
+// Queue returns the indices into ImagePairs of the pairs which pass the
+// results' filter, in review order. The current pair is always included even
+// if it no longer passes the filter so that a reviewer's place is not lost
+// when they change it.
+func (r Results) Queue() []int {
+	queue := make([]int, 0, len(r.ImagePairs))
+	for i, p := range r.ImagePairs {
+		if i == r.StartIdx || r.Filter.Match(p) {
+			queue = append(queue, i)
+		}
+	}
+
+	pairs := r.ImagePairs
+	var less func(a, b Pair) bool
+	switch r.Filter.Sort {
+	case SortConfidence:
+		less = func(a, b Pair) bool {
+			if a.Confidence != b.Confidence {
+				return a.Confidence > b.Confidence
+			}
+			return a.Distance < b.Distance
+		}
+	case SortDirectory:
+		less = func(a, b Pair) bool {
+			if da, db := filepath.Dir(a.RefImage), filepath.Dir(b.RefImage); da != db {
+				return da < db
+			}
+			return filepath.Base(a.RefImage) < filepath.Base(b.RefImage)
+		}
+	case SortFilename:
+		less = func(a, b Pair) bool {
+			return filepath.Base(a.RefImage) < filepath.Base(b.RefImage)
+		}
+	default:
+		return queue
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return less(pairs[queue[i]], pairs[queue[j]])
+	})
+	return queue
+}
+
+// Position returns the position of the pair at index in queue, or -1 if it
+// is not in the queue.
+func Position(queue []int, index int) int {
+	for pos, i := range queue {
+		if i == index {
+			return pos
+		}
+	}
+	return -1
+}
+
+// Progress counts the review decisions made on a set of pairs.
+type Progress struct {
+	Total         int
+	Reviewed      int
+	Duplicates    int
+	NotDuplicates int
+	Skipped       int
+}
+
+// Progress counts the review decisions made on the pairs at the given indices.
+func (r Results) Progress(indices []int) Progress {
+	p := Progress{Total: len(indices)}
+	for _, i := range indices {
+		switch r.ImagePairs[i].Review {
+		case Duplicate:
+			p.Duplicates++
+		case NotDuplicate:
+			p.NotDuplicates++
+		case Skipped:
+			p.Skipped++
+		default:
+			continue
+		}
+		p.Reviewed++
+	}
+	return p
+}
+
+// String formats the progress for display, for example
+// "reviewed 312 / 1,480, 45 confirmed".
+func (p Progress) String() string {
+	return fmt.Sprintf("reviewed %s / %s, %s confirmed", formatCount(p.Reviewed), formatCount(p.Total), formatCount(p.Duplicates))
+}
+
+// formatCount formats n with thousands separators.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package review
+
+import (
+	"reflect"
+	"testing"
+)
+
+func queueResults() Results {
+	return Results{ImagePairs: []Pair{
+		{RefImage: "b/2.jpg", DupeImage: "x/2.jpg", Confidence: 3, Distance: 6000, Review: Unreviewed},
+		{RefImage: "a/3.jpg", DupeImage: "x/3.jpg", Confidence: 5, Distance: 100, Review: Duplicate},
+		{RefImage: "b/1.jpg", DupeImage: "y/1.jpg", Confidence: 5, Distance: 50, Review: NotDuplicate},
+		{RefImage: "a/4.jpg", DupeImage: "y/4.jpg", Confidence: 1, Distance: 12000, Review: Unreviewed},
+	}}
+}
+
+func TestQueueSort(t *testing.T) {
+	r := queueResults()
+	cases := map[SortKey][]int{
+		SortNone:       {0, 1, 2, 3},
+		SortConfidence: {2, 1, 0, 3},
+		SortDirectory:  {1, 3, 2, 0},
+		SortFilename:   {2, 0, 1, 3},
+	}
+	for key, want := range cases {
+		r.Filter.Sort = key
+		if got := r.Queue(); !reflect.DeepEqual(got, want) {
+			t.Errorf("sorting by %q: expected %v. got %v", key, want, got)
+		}
+	}
+}
+
+func TestQueueFilter(t *testing.T) {
+	r := queueResults()
+	cases := []struct {
+		filter Filter
+		want   []int
+	}{
+		{Filter{States: []State{Unreviewed}}, []int{0, 3}},
+		{Filter{States: []State{Duplicate, NotDuplicate}}, []int{0, 1, 2}},
+		{Filter{MinConfidence: 3}, []int{0, 1, 2}},
+		{Filter{MaxConfidence: 3}, []int{0, 3}},
+		{Filter{PathPrefix: "y/"}, []int{0, 2, 3}},
+		{Filter{PathPrefix: "a/", MinConfidence: 2}, []int{0, 1}},
+		{Filter{MaxDistance: 100}, []int{0, 1, 2}},
+		{Filter{MaxDistance: 6000, States: []State{Unreviewed}}, []int{0}},
+	}
+	for _, c := range cases {
+		// The current pair (index 0) is always kept in the queue
+		r.Filter = c.filter
+		if got := r.Queue(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("filter %s: expected %v. got %v", c.filter, c.want, got)
+		}
+	}
+}
+
+func TestMatching(t *testing.T) {
+	r := queueResults()
+	r.StartIdx = 3
+	if got := r.Matching(Filter{MaxDistance: 6000}); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Error("expected only the pairs within the distance, even if the current pair isn't. got", got)
+	}
+	if max := r.MaxDistance(); max != 12000 {
+		t.Error("expected the largest distance to be 12000. got", max)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	r := queueResults()
+	p := r.Progress(r.Queue())
+	if p.Total != 4 || p.Reviewed != 2 || p.Duplicates != 1 || p.NotDuplicates != 1 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+	p = Progress{Total: 1480, Reviewed: 312, Duplicates: 45}
+	if s := p.String(); s != "reviewed 312 / 1,480, 45 confirmed" {
+		t.Error("unexpected progress string", s)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -1500: "-1,500"} {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%d): expected %s. got %s", n, want, got)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package review
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mike-lloyd03/dedugo/verify"
+	"gopkg.in/yaml.v2"
+)
+
+// State is the decision a reviewer made about a pair.
+type State string
+
+const (
+	Unreviewed   State = "unreviewed"
+	Duplicate    State = "duplicate"
+	NotDuplicate State = "not-duplicate"
+	Skipped      State = "skipped"
+)
+
+// States lists every review state.
+var States = []State{Unreviewed, Duplicate, NotDuplicate, Skipped}
+
+// Keep is which image of a duplicate pair the reviewer chose to keep.
+type Keep string
+
+const (
+	// KeepReference keeps the reference image and removes the duplicate. It
+	// is the default.
+	KeepReference Keep = ""
+	// KeepDuplicate keeps the duplicate image and removes the reference.
+	KeepDuplicate Keep = "duplicate"
+	// KeepBoth keeps both images.
+	KeepBoth Keep = "both"
+)
+
+type Pair struct {
+	RefImage   string  `yaml:"ReferenceImage"`
+	DupeImage  string  `yaml:"DuplicateImage"`
+	Confirmed  bool    `yaml:"Confirmed?"`
+	Confidence int     `yaml:"Confidence"`
+	Distance   float32 `yaml:"Distance"`
+	Burst      bool    `yaml:"Burst"`
+
+	Review     State     `yaml:"Review"`
+	Keep       Keep      `yaml:"Keep,omitempty"`
+	Reviewer   string    `yaml:"Reviewer,omitempty"`
+	ReviewedAt time.Time `yaml:"ReviewedAt,omitempty"`
+
+	Verification *verify.Scores `yaml:"Verification,omitempty"`
+
+	// RefFile and DupeFile are the states of the images when the pair was
+	// found. They are nil in results files written before they were recorded.
+	RefFile  *FileState `yaml:"ReferenceFile,omitempty"`
+	DupeFile *FileState `yaml:"DuplicateFile,omitempty"`
+}
+
+type Results struct {
+	RefDir     string `yaml:"ReferenceDirectory"`
+	EvalDir    string `yaml:"EvaluationDirectory"`
+	StartIdx   int    `yaml:"StartIndex"`
+	Filter     Filter `yaml:"Filter,omitempty"`
+	ImagePairs []Pair `yaml:"ImagePairs"`
+}
+
+// ParseState returns the review state with the given name.
+func ParseState(name string) (State, bool) {
+	for _, s := range States {
+		if string(s) == name {
+			return s, true
+		}
+	}
+	return Unreviewed, false
+}
+
+// Description returns the review state formatted for display.
+func (s State) Description() string {
+	switch s {
+	case Duplicate:
+		return "Confirmed duplicate"
+	case NotDuplicate:
+		return "Not a duplicate"
+	case Skipped:
+		return "Skipped"
+	}
+	return "Not yet reviewed"
+}
+
+// Decision describes the review state of the pair for display, including
+// which image is kept when it isn't the reference image.
+func (p Pair) Decision() string {
+	if p.Review != Duplicate {
+		return p.Review.Description()
+	}
+	switch p.Keep {
+	case KeepDuplicate:
+		return "Confirmed duplicate, keeping the duplicate image"
+	case KeepBoth:
+		return "Confirmed duplicate, keeping both images"
+	}
+	return p.Review.Description()
+}
+
+// Removed returns the image that removing the pair's duplicate removes, or an
+// empty string if both images are kept.
+func (p Pair) Removed() string {
+	switch p.Keep {
+	case KeepDuplicate:
+		return p.RefImage
+	case KeepBoth:
+		return ""
+	}
+	return p.DupeImage
+}
+
+// Kept returns the image kept in place of the removed one.
+func (p Pair) Kept() string {
+	if p.Keep == KeepDuplicate {
+		return p.DupeImage
+	}
+	return p.RefImage
+}
+
+// SetReview records a review decision for the pair, keeping the reference
+// image of a duplicate. Setting the state to Unreviewed clears the reviewer
+// and time.
+func (p *Pair) SetReview(state State, reviewer string) {
+	p.Review = state
+	p.Keep = KeepReference
+	p.Confirmed = state == Duplicate
+	if state == Unreviewed {
+		p.Reviewer = ""
+		p.ReviewedAt = time.Time{}
+		return
+	}
+	p.Reviewer = reviewer
+	p.ReviewedAt = time.Now().Round(time.Second)
+}
+
+// SetKeep records a duplicate decision for the pair which keeps the given
+// image.
+func (p *Pair) SetKeep(keep Keep, reviewer string) {
+	p.SetReview(Duplicate, reviewer)
+	p.Keep = keep
+}
+
+// Read reads a results file.
+func Read(path string) (Results, error) {
+	results := Results{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return results, err
+	}
+	if err := yaml.Unmarshal(data, &results); err != nil {
+		return results, err
+	}
+	migrateReviews(&results)
+	return results, nil
+}
+
+// Write writes a results file. The file is written to a temporary file first
+// and renamed into place so that an interrupted write never leaves a partial
+// results file behind.
+func Write(results Results, path string) error {
+	data, err := yaml.Marshal(results)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// migrateReviews fills in the review state of pairs from results files written
+// before review states were recorded. Confirmed pairs are duplicates and pairs
+// before the start index were passed over by the reviewer.
+func migrateReviews(results *Results) {
+	for i, p := range results.ImagePairs {
+		if p.Review != "" {
+			continue
+		}
+		switch {
+		case p.Confirmed:
+			results.ImagePairs[i].Review = Duplicate
+		case i < results.StartIdx:
+			results.ImagePairs[i].Review = NotDuplicate
+		default:
+			results.ImagePairs[i].Review = Unreviewed
+		}
+	}
+}
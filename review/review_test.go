@@ -0,0 +1,128 @@
+package review
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetReview(t *testing.T) {
+	p := Pair{Review: Unreviewed}
+
+	p.SetReview(Duplicate, "obi")
+	if !p.Confirmed || p.Reviewer != "obi" || p.ReviewedAt.IsZero() {
+		t.Errorf("marking a duplicate should confirm the pair and record the reviewer: %+v", p)
+	}
+
+	p.SetReview(NotDuplicate, "jango")
+	if p.Confirmed || p.Reviewer != "jango" {
+		t.Errorf("marking not a duplicate should unconfirm the pair: %+v", p)
+	}
+
+	p.SetReview(Unreviewed, "kylo")
+	if p.Confirmed || p.Reviewer != "" || !p.ReviewedAt.IsZero() {
+		t.Errorf("unmarking a pair should clear the reviewer and time: %+v", p)
+	}
+}
+
+func TestSetKeep(t *testing.T) {
+	p := Pair{RefImage: "ref.jpg", DupeImage: "dupe.jpg", Review: Unreviewed}
+	if p.Removed() != "dupe.jpg" || p.Kept() != "ref.jpg" {
+		t.Error("the reference image should be kept by default")
+	}
+
+	p.SetKeep(KeepDuplicate, "obi")
+	if p.Review != Duplicate || !p.Confirmed || p.Removed() != "ref.jpg" || p.Kept() != "dupe.jpg" {
+		t.Errorf("keeping the duplicate should remove the reference image: %+v", p)
+	}
+	if p.Decision() == Duplicate.Description() {
+		t.Error("the decision should say which image is kept")
+	}
+
+	p.SetKeep(KeepBoth, "obi")
+	if p.Removed() != "" {
+		t.Error("keeping both images should remove neither")
+	}
+
+	p.SetReview(Duplicate, "obi")
+	if p.Keep != KeepReference {
+		t.Error("a new decision should go back to keeping the reference image")
+	}
+}
+
+func TestParseState(t *testing.T) {
+	for _, s := range States {
+		got, ok := ParseState(string(s))
+		if !ok || got != s {
+			t.Errorf("could not parse %s", s)
+		}
+	}
+	if _, ok := ParseState("maybe"); ok {
+		t.Error("parsing an unknown state should fail")
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.yaml")
+	results := Results{
+		RefDir:   "ref",
+		EvalDir:  "eval",
+		StartIdx: 1,
+		ImagePairs: []Pair{
+			{RefImage: "ref/a.jpg", DupeImage: "eval/a.jpg", Confidence: 5, Distance: 120, Review: Unreviewed},
+			{RefImage: "ref/b.jpg", DupeImage: "eval/b.jpg", Confidence: 3, Distance: 6000, Review: Unreviewed},
+		},
+	}
+	results.ImagePairs[0].SetReview(Duplicate, "obi")
+
+	if err := Write(results, path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StartIdx != 1 || len(got.ImagePairs) != 2 {
+		t.Fatalf("results were not read back correctly: %+v", got)
+	}
+	p := got.ImagePairs[0]
+	if p.Review != Duplicate || p.Reviewer != "obi" || !p.ReviewedAt.Equal(results.ImagePairs[0].ReviewedAt) {
+		t.Errorf("review was not read back correctly: %+v", p)
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(files) != 1 {
+		t.Error("writing should not leave temporary files behind")
+	}
+
+	if _, err := Read("notAfile.yaml"); err == nil {
+		t.Error("reading a non-existant file should return an error")
+	}
+}
+
+func TestMigrateReviews(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.yaml")
+	legacy := `StartIndex: 2
+ImagePairs:
+- ReferenceImage: a
+  DuplicateImage: b
+  Confirmed?: false
+- ReferenceImage: c
+  DuplicateImage: d
+  Confirmed?: true
+- ReferenceImage: e
+  DuplicateImage: f
+  Confirmed?: false
+`
+	ioutil.WriteFile(path, []byte(legacy), 0644)
+	results, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []State{NotDuplicate, Duplicate, Unreviewed}
+	for i, s := range expect {
+		if results.ImagePairs[i].Review != s {
+			t.Errorf("pair %d should be %s. got %s", i, s, results.ImagePairs[i].Review)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package review
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// TimeFunc returns when the image at path was taken, or false if it isn't
+// known.
+type TimeFunc func(path string) (time.Time, bool)
+
+// Similar returns the indices of the unreviewed pairs which are likely to
+// deserve the same decision as the pair at index. These are the pairs in the
+// same cluster, whose images were matched to the pair's images, and the pairs
+// whose images are in the same two directories as the pair's images and were
+// taken within window of them. Directories and times are only compared if
+// taken is not nil and knows when both of the pair's images were taken.
+func (r Results) Similar(index int, window time.Duration, taken TimeFunc) []int {
+	similar := make(map[int]bool)
+	for _, i := range r.Cluster(index).Pairs {
+		similar[i] = true
+	}
+
+	p := r.ImagePairs[index]
+	refTime, refOk := timeOf(taken, p.RefImage)
+	dupeTime, dupeOk := timeOf(taken, p.DupeImage)
+	if refOk && dupeOk {
+		refDir, dupeDir := filepath.Dir(p.RefImage), filepath.Dir(p.DupeImage)
+		near := func(path, dir string, t time.Time) bool {
+			if filepath.Dir(path) != dir {
+				return false
+			}
+			other, ok := taken(path)
+			return ok && within(other, t, window)
+		}
+		for i, q := range r.ImagePairs {
+			if q.Review != Unreviewed || similar[i] {
+				continue
+			}
+			// The pair may have been found the other way round
+			if near(q.RefImage, refDir, refTime) && near(q.DupeImage, dupeDir, dupeTime) ||
+				near(q.DupeImage, refDir, refTime) && near(q.RefImage, dupeDir, dupeTime) {
+				similar[i] = true
+			}
+		}
+	}
+
+	indices := make([]int, 0)
+	for i, q := range r.ImagePairs {
+		if similar[i] && i != index && q.Review == Unreviewed {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+func timeOf(taken TimeFunc, path string) (time.Time, bool) {
+	if taken == nil {
+		return time.Time{}, false
+	}
+	return taken(path)
+}
+
+// within reports whether a and b are no more than window apart.
+func within(a, b time.Time, window time.Duration) bool {
+	d := a.Sub(b)
+	return d <= window && d >= -window
+}
@@ -0,0 +1,48 @@
+package review
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSimilar(t *testing.T) {
+	r := Results{ImagePairs: []Pair{
+		{RefImage: "trip/1.jpg", DupeImage: "phone/1.jpg", Review: NotDuplicate},
+		// Same shoot, found the other way round
+		{RefImage: "phone/2.jpg", DupeImage: "trip/2.jpg", Review: Unreviewed},
+		// Same shoot but already decided
+		{RefImage: "trip/3.jpg", DupeImage: "phone/3.jpg", Review: Duplicate},
+		// Same directories but a day later
+		{RefImage: "trip/4.jpg", DupeImage: "phone/4.jpg", Review: Unreviewed},
+		// Same time but another directory
+		{RefImage: "trip/5.jpg", DupeImage: "other/5.jpg", Review: Unreviewed},
+		// Shares an image with the decided pair
+		{RefImage: "trip/1.jpg", DupeImage: "other/6.jpg", Review: Unreviewed},
+		// No capture time
+		{RefImage: "trip/7.jpg", DupeImage: "phone/7.jpg", Review: Unreviewed},
+	}}
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	times := map[string]time.Time{
+		"trip/1.jpg": start, "phone/1.jpg": start.Add(time.Minute),
+		"phone/2.jpg": start.Add(20 * time.Minute), "trip/2.jpg": start.Add(15 * time.Minute),
+		"trip/3.jpg": start, "phone/3.jpg": start,
+		"trip/4.jpg": start.Add(24 * time.Hour), "phone/4.jpg": start.Add(24 * time.Hour),
+		"trip/5.jpg": start, "other/5.jpg": start,
+		"other/6.jpg": start.Add(24 * time.Hour),
+	}
+	taken := func(path string) (time.Time, bool) {
+		t, ok := times[path]
+		return t, ok
+	}
+
+	if got := r.Similar(0, time.Hour, taken); !reflect.DeepEqual(got, []int{1, 5}) {
+		t.Errorf("expected pairs 1 and 5. got %v", got)
+	}
+	if got := r.Similar(0, time.Hour, nil); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("without capture times only the cluster should be used. got %v", got)
+	}
+	if got := r.Similar(6, time.Hour, taken); len(got) != 0 {
+		t.Errorf("a pair without capture times should only match its cluster. got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package scan
+
+import "strings"
+
+// Profile is a named set of matching options for people who would rather not
+// tune each option themselves.
+type Profile struct {
+	Name        string
+	Description string
+
+	MinConfidence int
+	Verify        bool
+	VerifyAction  VerifyAction
+}
+
+// Profiles lists the built in matching profiles from the strictest to the
+// most lenient.
+var Profiles = []Profile{
+	{
+		Name:          "exact",
+		Description:   "Only copies which look the same, such as resized or re-saved photos. Pairs which fail a pixel by pixel check are dropped.",
+		MinConfidence: 4,
+		Verify:        true,
+		VerifyAction:  Discard,
+	},
+	{
+		Name:          "balanced",
+		Description:   "Copies and lightly edited versions. Pairs which fail a pixel by pixel check are given a lower confidence.",
+		MinConfidence: 2,
+		Verify:        true,
+		VerifyAction:  Demote,
+	},
+	{
+		Name:          "thorough",
+		Description:   "Anything which might be a copy, including cropped or heavily edited photos. Expect many more pairs to review.",
+		MinConfidence: 1,
+	},
+}
+
+// DefaultProfile is the profile suggested to people who haven't chosen one.
+const DefaultProfile = "balanced"
+
+// FindProfile returns the profile with the given name, ignoring case.
+func FindProfile(name string) (Profile, bool) {
+	for _, p := range Profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Apply sets the options the profile controls.
+func (p Profile) Apply(opts *Options) {
+	opts.MinConfidence = p.MinConfidence
+	opts.Verify = p.Verify
+	if p.Verify {
+		opts.VerifyAction = p.VerifyAction
+	}
+}
@@ -0,0 +1,552 @@
+// Package scan finds potential duplicate images. It is shared by the
+// find-duplicates and eval commands and the scan screen of the GUI so that a
+// scan finds the same pairs however it is started.
+package scan
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/adrium/goheif"
+	"github.com/mike-lloyd03/dedugo/metadata"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/verify"
+	images "github.com/vitali-fedulov/images3"
+)
+
+// VerifyAction is what happens to pairs which fail verification.
+type VerifyAction string
+
+const (
+	// Demote lowers the confidence of pairs which fail verification by one.
+	Demote VerifyAction = "demote"
+	// Discard removes pairs which fail verification.
+	Discard VerifyAction = "discard"
+	// KeepScores only records the verification scores.
+	KeepScores VerifyAction = "keep"
+)
+
+// Stage is a step of a scan.
+type Stage string
+
+const (
+	Walking   Stage = "Looking for images in"
+	Hashing   Stage = "Reading images in"
+	Comparing Stage = "Comparing images"
+	Verifying Stage = "Verifying potential duplicates"
+	Bursts    Stage = "Looking for burst shots"
+	Recording Stage = "Recording image files"
+)
+
+// Progress reports how far a scan has got. Dir is the directory being walked
+// or hashed. Total is 0 while the number of items is not yet known.
+type Progress struct {
+	Stage Stage
+	Dir   string
+	Done  int
+	Total int
+}
+
+func (p Progress) String() string {
+	s := string(p.Stage)
+	if p.Dir != "" {
+		s += " " + p.Dir
+	}
+	if p.Total > 0 {
+		s += fmt.Sprintf(": %d / %d", p.Done, p.Total)
+	}
+	return s
+}
+
+// DefaultBands are the upper distance boundaries for confidence 5 down to
+// confidence 1 used when no calibrated bands are given.
+var DefaultBands = []int{2000, 5000, 8000, 11000, 14000}
+
+// DefaultVerifySize is the longest edge images are scaled to for verification
+// unless another size is given.
+const DefaultVerifySize = 512
+
+// Options control how a scan matches images.
+type Options struct {
+	// MinConfidence is the minimum confidence score (1-5) for a pair to be
+	// reported.
+	MinConfidence int
+	// Bands are the upper distance boundaries for each confidence score.
+	// DefaultBands is used if it doesn't have one boundary per score.
+	Bands []int
+
+	// Verify re-compares each pair pixel by pixel and records the scores.
+	Verify          bool
+	VerifyThreshold float64
+	VerifyAction    VerifyAction
+	// VerifySize is the longest edge images are scaled to for verification.
+	// 0 uses DefaultVerifySize.
+	VerifySize int
+
+	// BurstWindow is the maximum gap between EXIF capture times for a pair to
+	// be tagged as a burst. 0 disables burst detection.
+	BurstWindow time.Duration
+
+	// Workers is the number of images read at once. 0 uses one per CPU.
+	Workers int
+	// Progress is called as the scan makes progress. It is never called
+	// concurrently.
+	Progress func(Progress)
+}
+
+// Image is a scanned image and its icon for comparison.
+type Image struct {
+	Path string
+	Icon images.IconT
+}
+
+// Result is the outcome of a scan.
+type Result struct {
+	// Pairs are the potential duplicates keyed by their reference and
+	// duplicate paths.
+	Pairs map[string]review.Pair
+	// Bursts are the pairs in Pairs which were tagged as bursts.
+	Bursts map[string]review.Pair
+	// RefImages and EvalImages are the number of images read from each
+	// directory.
+	RefImages  int
+	EvalImages int
+	// Unreadable lists the images which could not be decoded and were left
+	// out of the scan.
+	Unreadable []string
+}
+
+// Find compares every image in evalDir with every image in refDir. The scan
+// stops with the context's error if the context is cancelled.
+func Find(ctx context.Context, refDir, evalDir string, opts Options) (Result, error) {
+	s := newScanner(opts)
+	var result Result
+
+	refImages, err := s.readDir(ctx, refDir, &result)
+	if err != nil {
+		return result, err
+	}
+	result.RefImages = len(refImages)
+	evalImages, err := s.readDir(ctx, evalDir, &result)
+	if err != nil {
+		return result, err
+	}
+	result.EvalImages = len(evalImages)
+
+	err = s.compare(ctx, refImages, func(i int) []Image { return evalImages })
+	if err != nil {
+		return result, err
+	}
+	return s.finish(ctx, result)
+}
+
+// Within compares every image in dir with every other image in dir.
+func Within(ctx context.Context, dir string, opts Options) (Result, error) {
+	s := newScanner(opts)
+	var result Result
+
+	imgs, err := s.readDir(ctx, dir, &result)
+	if err != nil {
+		return result, err
+	}
+	result.RefImages, result.EvalImages = len(imgs), len(imgs)
+
+	err = s.compare(ctx, imgs, func(i int) []Image { return imgs[i+1:] })
+	if err != nil {
+		return result, err
+	}
+	return s.finish(ctx, result)
+}
+
+// scanner holds the state shared by the workers of one scan.
+type scanner struct {
+	opts  Options
+	bands []int
+
+	m     sync.Mutex
+	pairs map[string]review.Pair
+	// progress is the last progress reported.
+	progress Progress
+}
+
+func newScanner(opts Options) *scanner {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.VerifySize <= 0 {
+		opts.VerifySize = DefaultVerifySize
+	}
+	if opts.VerifyAction == "" {
+		opts.VerifyAction = Demote
+	}
+	bands := opts.Bands
+	if len(bands) != len(DefaultBands) {
+		bands = DefaultBands
+	}
+	return &scanner{opts: opts, bands: bands, pairs: make(map[string]review.Pair)}
+}
+
+// start reports the beginning of a stage.
+func (s *scanner) start(stage Stage, dir string, total int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.progress = Progress{Stage: stage, Dir: dir, Total: total}
+	s.report()
+}
+
+// step reports that one more item of the current stage is done.
+func (s *scanner) step() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.progress.Done++
+	s.report()
+}
+
+// report calls the progress callback. It must be called with s.m held.
+func (s *scanner) report() {
+	if s.opts.Progress != nil {
+		s.opts.Progress(s.progress)
+	}
+}
+
+// finish verifies the pairs found, records the state of their images and tags
+// bursts.
+func (s *scanner) finish(ctx context.Context, result Result) (Result, error) {
+	if s.opts.Verify {
+		if err := s.verify(ctx); err != nil {
+			return result, err
+		}
+	}
+	if err := s.record(ctx); err != nil {
+		return result, err
+	}
+	result.Pairs = s.pairs
+	result.Bursts = make(map[string]review.Pair)
+	if s.opts.BurstWindow > 0 {
+		s.start(Bursts, "", 0)
+		result.Bursts = tagBursts(s.pairs, s.opts.BurstWindow)
+	}
+	return result, ctx.Err()
+}
+
+// readDir reads and makes icons of every image in dir. Images which can't be
+// decoded are added to result.Unreadable.
+func (s *scanner) readDir(ctx context.Context, dir string, result *Result) ([]Image, error) {
+	s.start(Walking, dir, 0)
+	paths, err := imagePaths(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	numWorkers := s.opts.Workers
+	if len(paths) < numWorkers {
+		numWorkers = len(paths)
+	}
+	log.Printf("Beginning scan of %s with %d workers.\n", dir, numWorkers)
+	s.start(Hashing, dir, len(paths))
+
+	indexChan := make(chan int)
+	var wg sync.WaitGroup
+	var imgs []Image
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				path := paths[i]
+				img, err := Open(path)
+				if err != nil {
+					log.Printf("Error opening %s: %s", path, err)
+					s.m.Lock()
+					result.Unreadable = append(result.Unreadable, path)
+					s.m.Unlock()
+				} else {
+					icon := images.Icon(img, path)
+					s.m.Lock()
+					imgs = append(imgs, Image{path, icon})
+					s.m.Unlock()
+				}
+				s.step()
+			}
+		}()
+	}
+	err = send(ctx, indexChan, len(paths))
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Finished scan. Found %d images. Elapsed time: %s\n", len(imgs), time.Now().Sub(startTime).Round(10*time.Millisecond))
+	return imgs, nil
+}
+
+// compare compares each of refImages with the images returned by against.
+func (s *scanner) compare(ctx context.Context, refImages []Image, against func(i int) []Image) error {
+	s.start(Comparing, "", len(refImages))
+	indexChan := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				s.compareImage(refImages[i], against(i))
+				s.step()
+			}
+		}()
+	}
+	err := send(ctx, indexChan, len(refImages))
+	wg.Wait()
+	return err
+}
+
+// compareImage records each of evalImages which is similar to refImg.
+func (s *scanner) compareImage(refImg Image, evalImages []Image) {
+	for _, evalImg := range evalImages {
+		distance := Distance(images.EucMetric(refImg.Icon, evalImg.Icon))
+		confidence := Confidence(distance, s.bands)
+		if confidence >= s.opts.MinConfidence {
+			s.m.Lock()
+			s.pairs[refImg.Path+","+evalImg.Path] = review.Pair{RefImage: refImg.Path, DupeImage: evalImg.Path, Confidence: confidence, Distance: distance}
+			s.m.Unlock()
+		}
+	}
+}
+
+// verify compares each pair pixel by pixel and stores the scores in the pair.
+// Pairs below the verification threshold are demoted or discarded according
+// to the verify action.
+func (s *scanner) verify(ctx context.Context) error {
+	keys := make([]string, 0, len(s.pairs))
+	for key := range s.pairs {
+		keys = append(keys, key)
+	}
+	startTime := time.Now()
+	log.Printf("Beginning verification of %d pairs with %d workers.\n", len(keys), s.opts.Workers)
+	s.start(Verifying, "", len(keys))
+
+	indexChan := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				s.verifyPair(keys[i])
+				s.step()
+			}
+		}()
+	}
+	err := send(ctx, indexChan, len(keys))
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	for key, p := range s.pairs {
+		if p.Verification == nil || p.Verification.SSIM >= s.opts.VerifyThreshold {
+			continue
+		}
+		switch s.opts.VerifyAction {
+		case Discard:
+			log.Printf("Discarding %s and %s. SSIM = %.3f\n", p.RefImage, p.DupeImage, p.Verification.SSIM)
+			delete(s.pairs, key)
+		case Demote:
+			p.Confidence--
+			if p.Confidence < s.opts.MinConfidence {
+				log.Printf("Discarding %s and %s after demotion. SSIM = %.3f\n", p.RefImage, p.DupeImage, p.Verification.SSIM)
+				delete(s.pairs, key)
+			} else {
+				s.pairs[key] = p
+			}
+		}
+	}
+	log.Printf("Finished verification. Elapsed time: %s\n", time.Now().Sub(startTime).Round(10*time.Millisecond))
+	return nil
+}
+
+func (s *scanner) verifyPair(key string) {
+	s.m.Lock()
+	p := s.pairs[key]
+	s.m.Unlock()
+
+	refImg, err := Open(p.RefImage)
+	if err != nil {
+		log.Printf("Error opening %s: %s", p.RefImage, err)
+		return
+	}
+	dupeImg, err := Open(p.DupeImage)
+	if err != nil {
+		log.Printf("Error opening %s: %s", p.DupeImage, err)
+		return
+	}
+	scores := verify.Compare(refImg, dupeImg, s.opts.VerifySize)
+	p.Verification = &scores
+
+	s.m.Lock()
+	s.pairs[key] = p
+	s.m.Unlock()
+}
+
+// record stores the size, modification time and hash of the images of each
+// pair in the pair, so that changes made before duplicates are removed can be
+// noticed. Images which can't be read are left unrecorded.
+func (s *scanner) record(ctx context.Context) error {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, p := range s.pairs {
+		for _, path := range []string{p.RefImage, p.DupeImage} {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	s.start(Recording, "", len(paths))
+
+	states := make([]*review.FileState, len(paths))
+	indexChan := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				state, err := review.Snapshot(paths[i])
+				if err != nil {
+					log.Printf("Error recording %s: %s", paths[i], err)
+				} else {
+					states[i] = &state
+				}
+				s.step()
+			}
+		}()
+	}
+	err := send(ctx, indexChan, len(paths))
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	byPath := make(map[string]*review.FileState, len(paths))
+	for i, path := range paths {
+		byPath[path] = states[i]
+	}
+	for key, p := range s.pairs {
+		p.RefFile, p.DupeFile = byPath[p.RefImage], byPath[p.DupeImage]
+		s.pairs[key] = p
+	}
+	return nil
+}
+
+// send sends the indices 0 to n-1 to ch and closes it, stopping early if the
+// context is cancelled.
+func send(ctx context.Context, ch chan<- int, n int) error {
+	defer close(ch)
+	for i := 0; i < n; i++ {
+		select {
+		case ch <- i:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// tagBursts marks every pair in pairs whose images look like separate frames
+// of a burst and returns those pairs.
+func tagBursts(pairs map[string]review.Pair, window time.Duration) map[string]review.Pair {
+	exifCache := make(map[string]metadata.Exif)
+	readExif := func(path string) metadata.Exif {
+		info, found := exifCache[path]
+		if !found {
+			info, _ = metadata.ReadExif(path)
+			exifCache[path] = info
+		}
+		return info
+	}
+
+	bursts := make(map[string]review.Pair)
+	for key, p := range pairs {
+		if metadata.IsBurst(readExif(p.RefImage), readExif(p.DupeImage), window) {
+			p.Burst = true
+			pairs[key] = p
+			bursts[key] = p
+		}
+	}
+	return bursts
+}
+
+var imgFormats = map[string]struct{}{
+	".jpg":  {},
+	".jpeg": {},
+	".heic": {},
+	".png":  {},
+}
+
+// IsImage reports whether path has the extension of an image format dedugo
+// can read.
+func IsImage(path string) bool {
+	_, found := imgFormats[strings.ToLower(filepath.Ext(path))]
+	return found
+}
+
+// ImagePaths returns the path of every image below dir.
+func ImagePaths(dir string) ([]string, error) {
+	return imagePaths(context.Background(), dir)
+}
+
+func imagePaths(ctx context.Context, dir string) ([]string, error) {
+	paths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.IsDir() && IsImage(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// Open opens and decodes an image file.
+func Open(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// Distance returns the average of the per-channel distances between two
+// image icons.
+func Distance(m1, m2, m3 float32) float32 {
+	return (m1 + m2 + m3) / 3
+}
+
+// Confidence returns the confidence that two images are similar on a scale of
+// 0-5 with 5 being the highest confidence. bands are the upper distance
+// boundaries for confidence 5 down to confidence 1.
+func Confidence(distance float32, bands []int) int {
+	for i, b := range bands {
+		if distance < float32(b) {
+			return len(bands) - i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+package scan
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeImage writes a gradient PNG to path. Images with different shades are
+// never similar.
+func writeImage(t *testing.T, path string, shade uint8) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i] = uint8(x * 4)
+			img.Pix[i+1] = shade
+			img.Pix[i+2] = uint8(y*5) ^ shade
+			img.Pix[i+3] = 255
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeDirs creates a reference directory with images a and b and an
+// evaluation directory with a copy of a, an unrelated image and a file which
+// isn't an image.
+func writeDirs(t *testing.T) (refDir, evalDir string) {
+	refDir, evalDir = t.TempDir(), t.TempDir()
+	writeImage(t, filepath.Join(refDir, "a.png"), 0)
+	writeImage(t, filepath.Join(refDir, "b.png"), 200)
+	os.Mkdir(filepath.Join(evalDir, "sub"), 0755)
+	writeImage(t, filepath.Join(evalDir, "sub", "a copy.PNG"), 0)
+	writeImage(t, filepath.Join(evalDir, "c.png"), 100)
+	ioutil.WriteFile(filepath.Join(evalDir, "notes.txt"), []byte("not an image"), 0644)
+	return refDir, evalDir
+}
+
+func TestFind(t *testing.T) {
+	refDir, evalDir := writeDirs(t)
+	broken := filepath.Join(evalDir, "broken.jpg")
+	ioutil.WriteFile(broken, []byte("not a jpeg"), 0644)
+
+	var progress []Progress
+	result, err := Find(context.Background(), refDir, evalDir, Options{
+		MinConfidence: 5,
+		Verify:        true,
+		Progress:      func(p Progress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RefImages != 2 || result.EvalImages != 2 {
+		t.Errorf("expected 2 images in each directory. got %d and %d", result.RefImages, result.EvalImages)
+	}
+	if len(result.Unreadable) != 1 || result.Unreadable[0] != broken {
+		t.Error("expected the broken image to be reported. got", result.Unreadable)
+	}
+	copyPath := filepath.Join(evalDir, "sub", "a copy.PNG")
+	p, ok := result.Pairs[filepath.Join(refDir, "a.png")+","+copyPath]
+	if len(result.Pairs) != 1 || !ok {
+		t.Fatalf("expected only a and its copy to be paired. got %+v", result.Pairs)
+	}
+	if p.Confidence != 5 || p.Verification == nil || p.Verification.SSIM < 0.99 {
+		t.Errorf("expected an identical, verified pair. got %+v", p)
+	}
+	if p.RefFile == nil || p.DupeFile == nil || p.RefFile.Hash != p.DupeFile.Hash || p.DupeFile.Check(copyPath) != nil {
+		t.Errorf("expected the state of both images to be recorded. got %+v and %+v", p.RefFile, p.DupeFile)
+	}
+
+	last := progress[len(progress)-1]
+	if last.Stage != Recording || last.Done != 2 || last.Total != 2 {
+		t.Errorf("expected recording of the pair's two images to be reported last. got %+v", last)
+	}
+	for i := 1; i < len(progress); i++ {
+		prev, cur := progress[i-1], progress[i]
+		if cur.Stage == prev.Stage && cur.Dir == prev.Dir && cur.Done != prev.Done+1 {
+			t.Fatalf("progress should count up one at a time. got %+v after %+v", cur, prev)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	_, evalDir := writeDirs(t)
+	writeImage(t, filepath.Join(evalDir, "c again.png"), 100)
+
+	result, err := Within(context.Background(), evalDir, Options{MinConfidence: 5, Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pairs) != 1 || result.RefImages != 3 {
+		t.Errorf("expected c and its copy to be the only pair among 3 images. got %+v", result)
+	}
+}
+
+func TestFindCancelled(t *testing.T) {
+	refDir, evalDir := writeDirs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, err := Find(ctx, refDir, evalDir, Options{
+		MinConfidence: 1,
+		Workers:       1,
+		Progress: func(p Progress) {
+			calls++
+			if p.Stage == Hashing {
+				cancel()
+			}
+		},
+	})
+	if err != context.Canceled {
+		t.Fatal("expected the scan to be cancelled. got", err)
+	}
+	if calls > 4 {
+		t.Errorf("the scan should stop soon after being cancelled. got %d progress reports", calls)
+	}
+}
+
+func TestImagePaths(t *testing.T) {
+	_, evalDir := writeDirs(t)
+	paths, err := ImagePaths(evalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Error("expected the two images and not the text file. got", paths)
+	}
+	if _, err := ImagePaths(filepath.Join(evalDir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	for _, c := range []struct {
+		distance float32
+		want     int
+	}{{0, 5}, {1999, 5}, {2000, 4}, {13999, 1}, {14000, 0}} {
+		if got := Confidence(c.distance, DefaultBands); got != c.want {
+			t.Errorf("distance %v: expected confidence %d. got %d", c.distance, c.want, got)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	p, ok := FindProfile("Exact")
+	if !ok {
+		t.Fatal("profiles should be found regardless of case")
+	}
+	opts := Options{VerifyAction: Demote}
+	p.Apply(&opts)
+	if opts.MinConfidence != 4 || !opts.Verify || opts.VerifyAction != Discard {
+		t.Errorf("unexpected options from the exact profile %+v", opts)
+	}
+	if _, ok := FindProfile(DefaultProfile); !ok {
+		t.Error("the default profile should exist")
+	}
+}
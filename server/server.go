@@ -0,0 +1,311 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/adrium/goheif"
+	"github.com/mike-lloyd03/dedugo/review"
+	"github.com/mike-lloyd03/dedugo/session"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Server serves a web interface for reviewing a results file. Decisions are
+// recorded by a review session, which writes them back to the results file as
+// soon as they are made.
+type Server struct {
+	reviewer string
+
+	m       sync.Mutex
+	session *session.Session
+}
+
+// PairJSON is a pair as returned by the API.
+type PairJSON struct {
+	Index      int          `json:"index"`
+	RefImage   string       `json:"refImage"`
+	DupeImage  string       `json:"dupeImage"`
+	Confidence int          `json:"confidence"`
+	Distance   float32      `json:"distance"`
+	Burst      bool         `json:"burst"`
+	Review     review.State `json:"review"`
+	Keep       review.Keep  `json:"keep,omitempty"`
+	Reviewer   string       `json:"reviewer,omitempty"`
+	ReviewedAt *time.Time   `json:"reviewedAt,omitempty"`
+}
+
+// PairsJSON is the response to a request for the list of pairs.
+type PairsJSON struct {
+	Total      int        `json:"total"`
+	StartIndex int        `json:"startIndex"`
+	Pairs      []PairJSON `json:"pairs"`
+}
+
+// ReviewRequest is the body of a request to record a decision. Keep picks the
+// image a duplicate keeps. When it is left out, a pair which was already a
+// duplicate keeps the same image as before.
+type ReviewRequest struct {
+	State    review.State `json:"state"`
+	Keep     *review.Keep `json:"keep,omitempty"`
+	Reviewer string       `json:"reviewer"`
+}
+
+// PositionRequest is the body of a request to save the reviewer's position.
+type PositionRequest struct {
+	Index int `json:"index"`
+}
+
+// New reads the results file at path and returns a Server for it. reviewer is
+// recorded with decisions which do not name their own reviewer. It returns
+// session.ErrNoPairs if there are no pairs to review.
+func New(path, reviewer string) (*Server, error) {
+	// Browsers load the images themselves
+	s, err := session.New(session.FileStore(path), nil, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{reviewer: reviewer, session: s}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexHTML)
+	case r.URL.Path == "/api/pairs":
+		s.handlePairs(w, r)
+	case r.URL.Path == "/api/position":
+		s.handlePosition(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/pairs/"):
+		s.handlePair(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handlePairs returns the pairs matching the minConfidence, maxConfidence and
+// state query parameters.
+func (s *Server) handlePairs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	minConf, err := intParam(q.Get("minConfidence"), 0)
+	if err != nil {
+		http.Error(w, "invalid minConfidence", http.StatusBadRequest)
+		return
+	}
+	maxConf, err := intParam(q.Get("maxConfidence"), 5)
+	if err != nil {
+		http.Error(w, "invalid maxConfidence", http.StatusBadRequest)
+		return
+	}
+	var states map[review.State]bool
+	if q.Get("state") != "" {
+		states = make(map[review.State]bool)
+		for _, name := range strings.Split(q.Get("state"), ",") {
+			state, ok := review.ParseState(name)
+			if !ok {
+				http.Error(w, "invalid state "+name, http.StatusBadRequest)
+				return
+			}
+			states[state] = true
+		}
+	}
+
+	s.m.Lock()
+	results := s.session.Results()
+	resp := PairsJSON{Total: len(results.ImagePairs), StartIndex: results.StartIdx, Pairs: make([]PairJSON, 0)}
+	for i, p := range results.ImagePairs {
+		if p.Confidence < minConf || p.Confidence > maxConf {
+			continue
+		}
+		if states != nil && !states[p.Review] {
+			continue
+		}
+		resp.Pairs = append(resp.Pairs, pairJSON(i, p))
+	}
+	s.m.Unlock()
+
+	writeJSON(w, resp)
+}
+
+// handlePair serves /api/pairs/{index}/ref, /api/pairs/{index}/dupe and
+// /api/pairs/{index}/review.
+func (s *Server) handlePair(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/pairs/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	i, err := strconv.Atoi(parts[0])
+
+	s.m.Lock()
+	pairs := s.session.Results().ImagePairs
+	if err != nil || i < 0 || i >= len(pairs) {
+		s.m.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	p := pairs[i]
+	s.m.Unlock()
+
+	switch parts[1] {
+	case "ref":
+		serveImage(w, r, p.RefImage)
+	case "dupe":
+		serveImage(w, r, p.DupeImage)
+	case "review":
+		s.handleReview(w, r, i)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleReview(w http.ResponseWriter, r *http.Request, i int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := ReviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := review.ParseState(string(req.State)); !ok {
+		http.Error(w, "invalid state "+string(req.State), http.StatusBadRequest)
+		return
+	}
+	if req.Keep != nil {
+		if req.State != review.Duplicate {
+			http.Error(w, "only duplicates can keep an image", http.StatusBadRequest)
+			return
+		}
+		switch *req.Keep {
+		case review.KeepReference, review.KeepDuplicate, review.KeepBoth:
+		default:
+			http.Error(w, "invalid keep "+string(*req.Keep), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Reviewer == "" {
+		req.Reviewer = s.reviewer
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.session.SetReviewer(req.Reviewer)
+	p := s.session.Results().ImagePairs[i]
+	var err error
+	switch {
+	case req.Keep != nil:
+		err = s.session.KeepAt(i, *req.Keep)
+	case req.State == review.Duplicate && p.Review == review.Duplicate:
+		err = s.session.KeepAt(i, p.Keep)
+	default:
+		err = s.session.DecideAt(i, req.State)
+	}
+	if err != nil {
+		http.Error(w, "could not save results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, pairJSON(i, s.session.Results().ImagePairs[i]))
+}
+
+// handlePosition saves the index of the pair being reviewed so that the next
+// session starts there.
+func (s *Server) handlePosition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := PositionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	moved, err := s.session.SeekIndex(req.Index)
+	if !moved {
+		http.Error(w, "index out of range", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "could not save results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveImage sends JPEG, PNG and GIF files as they are and converts any other
+// format to JPEG so that browsers can display it.
+func serveImage(w http.ResponseWriter, r *http.Request, path string) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		http.ServeFile(w, r, path)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		http.Error(w, "could not decode image", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+}
+
+func pairJSON(i int, p review.Pair) PairJSON {
+	pj := PairJSON{
+		Index:      i,
+		RefImage:   p.RefImage,
+		DupeImage:  p.DupeImage,
+		Confidence: p.Confidence,
+		Distance:   p.Distance,
+		Burst:      p.Burst,
+		Review:     p.Review,
+		Keep:       p.Keep,
+		Reviewer:   p.Reviewer,
+	}
+	if !p.ReviewedAt.IsZero() {
+		t := p.ReviewedAt
+		pj.ReviewedAt = &t
+	}
+	return pj
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func intParam(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("not a number")
+	}
+	return i, nil
+}
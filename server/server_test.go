@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+// setup writes a results file with three pairs and starts a test server for it.
+func setup(t *testing.T) (*httptest.Server, string) {
+	dir := t.TempDir()
+
+	// An image with an extension browsers can't display
+	converted := filepath.Join(dir, "converted.heic")
+	f, err := os.Create(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	f.Close()
+
+	path := filepath.Join(dir, "results.yaml")
+	results := review.Results{
+		StartIdx: 1,
+		ImagePairs: []review.Pair{
+			{RefImage: "../imageList/test_images/Obi1.jpg", DupeImage: converted, Confidence: 5, Review: review.Unreviewed},
+			{RefImage: "../imageList/test_images/Jango3.jpg", DupeImage: "../imageList/test_images/Jango4.jpg", Confidence: 2, Review: review.Unreviewed},
+			{RefImage: "../imageList/test_images/Kylo5.jpg", DupeImage: "../imageList/test_images/Kylo6.jpg", Confidence: 4, Review: review.Duplicate},
+		},
+	}
+	if err := review.Write(results, path); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(path, "obi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts, path
+}
+
+func getPairs(t *testing.T, url string) PairsJSON {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s returned %s", url, resp.Status)
+	}
+	pairs := PairsJSON{}
+	json.NewDecoder(resp.Body).Decode(&pairs)
+	return pairs
+}
+
+func TestIndex(t *testing.T) {
+	ts, _ := setup(t)
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Error("index page was not served")
+	}
+}
+
+func TestListPairs(t *testing.T) {
+	ts, _ := setup(t)
+
+	pairs := getPairs(t, ts.URL+"/api/pairs")
+	if pairs.Total != 3 || len(pairs.Pairs) != 3 || pairs.StartIndex != 1 {
+		t.Errorf("wrong pair list: %+v", pairs)
+	}
+
+	pairs = getPairs(t, ts.URL+"/api/pairs?minConfidence=3&maxConfidence=4")
+	if len(pairs.Pairs) != 1 || pairs.Pairs[0].Index != 2 {
+		t.Errorf("confidence filter returned wrong pairs: %+v", pairs.Pairs)
+	}
+
+	pairs = getPairs(t, ts.URL+"/api/pairs?state=unreviewed")
+	if len(pairs.Pairs) != 2 || pairs.Total != 3 {
+		t.Errorf("state filter returned wrong pairs: %+v", pairs.Pairs)
+	}
+
+	resp, _ := http.Get(ts.URL + "/api/pairs?state=maybe")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("filtering by an unknown state should be a bad request")
+	}
+}
+
+func TestReview(t *testing.T) {
+	ts, path := setup(t)
+
+	body := bytes.NewBufferString(`{"state": "not-duplicate", "reviewer": "jango"}`)
+	resp, err := http.Post(ts.URL+"/api/pairs/1/review", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := PairJSON{}
+	json.NewDecoder(resp.Body).Decode(&p)
+	resp.Body.Close()
+	if p.Review != review.NotDuplicate || p.Reviewer != "jango" || p.ReviewedAt == nil {
+		t.Errorf("review was not recorded: %+v", p)
+	}
+
+	// Decisions are saved to the results file immediately
+	results, err := review.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.ImagePairs[1].Review != review.NotDuplicate {
+		t.Error("review was not saved to the results file")
+	}
+
+	// The server's reviewer is used if the request doesn't name one
+	resp, _ = http.Post(ts.URL+"/api/pairs/0/review", "application/json", bytes.NewBufferString(`{"state": "duplicate"}`))
+	json.NewDecoder(resp.Body).Decode(&p)
+	resp.Body.Close()
+	if p.Reviewer != "obi" {
+		t.Error("default reviewer was not recorded. got", p.Reviewer)
+	}
+
+	// Confirming a duplicate again keeps the image chosen before
+	resp, _ = http.Post(ts.URL+"/api/pairs/0/review", "application/json", bytes.NewBufferString(`{"state": "duplicate", "keep": "duplicate"}`))
+	resp.Body.Close()
+	resp, _ = http.Post(ts.URL+"/api/pairs/0/review", "application/json", bytes.NewBufferString(`{"state": "duplicate"}`))
+	json.NewDecoder(resp.Body).Decode(&p)
+	resp.Body.Close()
+	if p.Keep != review.KeepDuplicate {
+		t.Error("the kept image should not change when a duplicate is reviewed again. got", p.Keep)
+	}
+	results, _ = review.Read(path)
+	if results.ImagePairs[0].Keep != review.KeepDuplicate {
+		t.Error("the kept image was not saved to the results file")
+	}
+	resp, _ = http.Post(ts.URL+"/api/pairs/0/review", "application/json", bytes.NewBufferString(`{"state": "skipped", "keep": "both"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("only duplicates should keep an image")
+	}
+
+	resp, _ = http.Post(ts.URL+"/api/pairs/0/review", "application/json", bytes.NewBufferString(`{"state": "maybe"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("an unknown state should be a bad request")
+	}
+	resp, _ = http.Post(ts.URL+"/api/pairs/9/review", "application/json", bytes.NewBufferString(`{"state": "duplicate"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("reviewing a pair out of range should be not found")
+	}
+	resp, _ = http.Get(ts.URL + "/api/pairs/0/review")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Error("reviews must be posted")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	ts, path := setup(t)
+
+	resp, err := http.Post(ts.URL+"/api/position", "application/json", bytes.NewBufferString(`{"index": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatal("position was not saved.", resp.Status)
+	}
+	results, _ := review.Read(path)
+	if results.StartIdx != 2 {
+		t.Error("start index was not saved. got", results.StartIdx)
+	}
+
+	resp, _ = http.Post(ts.URL+"/api/position", "application/json", bytes.NewBufferString(`{"index": 3}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("a position out of range should be a bad request")
+	}
+}
+
+func TestImages(t *testing.T) {
+	ts, _ := setup(t)
+
+	resp, err := http.Get(ts.URL + "/api/pairs/0/ref")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/jpeg" {
+		t.Error("reference image was not served")
+	}
+
+	resp, _ = http.Get(ts.URL + "/api/pairs/0/dupe")
+	_, _, err = image.Decode(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "image/jpeg" || err != nil {
+		t.Error("image should have been converted to JPEG.", err)
+	}
+
+	for _, path := range []string{"/api/pairs/3/ref", "/api/pairs/x/ref", "/api/pairs/0/other"} {
+		resp, _ = http.Get(ts.URL + path)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s should be not found", path)
+		}
+	}
+}
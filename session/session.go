@@ -0,0 +1,498 @@
+// Package session implements reviewing a results file independently of any
+// user interface. A Session owns the review queue, navigation, decisions,
+// undo, image prefetching and saving, so the GUI, terminal and prompt
+// reviewers only need to draw its state and forward user input to it.
+package session
+
+import (
+	"errors"
+	"image"
+	"path/filepath"
+	"sync"
+	"time"
+
+	imagelist "github.com/mike-lloyd03/dedugo/imageList"
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+// DefaultPrefetch is the number of pairs either side of the current one whose
+// images are loaded in the background unless SetPrefetch is called.
+const DefaultPrefetch = 1
+
+// ErrNoPairs is returned by New when the results contain no pairs to review.
+var ErrNoPairs = errors.New("there are no image pairs to review")
+
+// Store loads and saves the results being reviewed.
+type Store interface {
+	Load() (review.Results, error)
+	Save(review.Results) error
+}
+
+// FileStore stores results in the results file at the given path.
+type FileStore string
+
+// Load reads the results file.
+func (f FileStore) Load() (review.Results, error) {
+	return review.Read(string(f))
+}
+
+// Save writes the results file.
+func (f FileStore) Save(results review.Results) error {
+	return review.Write(results, string(f))
+}
+
+// Loader decodes the image at path.
+type Loader func(path string) (image.Image, error)
+
+// Images holds the decoded images of a pair along with any error from loading
+// either of them.
+type Images struct {
+	Ref     image.Image
+	Dupe    image.Image
+	RefErr  error
+	DupeErr error
+}
+
+type undo struct {
+	index int
+	pair  review.Pair
+	// direct is set for decisions made on the pair itself rather than
+	// copied to it by ApplyDecision or ResolveCluster.
+	direct bool
+}
+
+// Session is a review of one results file. It is not safe for concurrent use,
+// except that Save may be called from any goroutine, for example to save a
+// filter once a control has stopped changing.
+type Session struct {
+	store    Store
+	load     Loader
+	reviewer string
+
+	// mu is held while the results change and while they are saved, so that
+	// a concurrent Save never reads them half changed and saves are written
+	// in the order they were made.
+	mu      sync.Mutex
+	results review.Results
+	// queue is the filtered and sorted order pairs are reviewed in. It is
+	// only rebuilt when the filter changes so that pairs which no longer
+	// match after a decision can still be revisited.
+	queue   []int
+	history []undo
+	// images holds the reference and duplicate image paths of every pair in
+	// the queue, in queue order. It is nil if no loader was given.
+	images *imagelist.ImageList
+	// full is like images, loading the images at full resolution with
+	// fullLoad. It is only created once FullImages is called.
+	full     *imagelist.ImageList
+	fullLoad Loader
+	// prefetchOpts holds the limits set with SetPrefetch for both image
+	// lists.
+	prefetchOpts imagelist.Options
+}
+
+// New loads the results from store and starts a review at the pair the
+// results were last left on. Decisions are recorded with the reviewer's name
+// and images are loaded with load. If load is nil, no images are loaded.
+func New(store Store, load Loader, reviewer string) (*Session, error) {
+	results, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(results.ImagePairs) == 0 {
+		return nil, ErrNoPairs
+	}
+	if results.StartIdx < 0 {
+		results.StartIdx = 0
+	} else if results.StartIdx >= len(results.ImagePairs) {
+		results.StartIdx = len(results.ImagePairs) - 1
+	}
+
+	s := &Session{
+		store:    store,
+		load:     load,
+		reviewer: reviewer,
+		results:  results,
+	}
+	s.queue = results.Queue()
+	s.SetPrefetch(DefaultPrefetch, 0)
+	return s, nil
+}
+
+// SetPrefetch sets the number of pairs either side of the current one whose
+// images are loaded in the background and the memory the loaded images may
+// use in bytes. A limit of zero means no limit. Images which have already
+// been loaded are dropped.
+func (s *Session) SetPrefetch(pairs int, maxBytes int64) {
+	if pairs < 0 {
+		pairs = 0
+	}
+	behind := 2 * pairs
+	if behind == 0 {
+		// The image list uses its default window for zero
+		behind = -1
+	}
+	s.prefetchOpts = imagelist.Options{Behind: behind, Ahead: 2*pairs + 1, MaxBytes: maxBytes}
+	s.images = s.newImageList(s.load)
+	if s.full != nil {
+		s.full = s.newImageList(s.fullLoad)
+	}
+	s.prefetch()
+}
+
+// SetFullLoader sets how FullImages decodes images at full resolution.
+func (s *Session) SetFullLoader(load Loader) {
+	s.fullLoad = load
+	s.DropFullImages()
+}
+
+// newImageList returns a list of the images of the pairs in the queue which
+// are loaded with load and prefetched within the limits set by SetPrefetch. It
+// returns nil if load is nil.
+func (s *Session) newImageList(load Loader) *imagelist.ImageList {
+	if load == nil {
+		return nil
+	}
+	opts := s.prefetchOpts
+	opts.Load = load
+	list, _ := imagelist.New(s.paths(), opts)
+	return list
+}
+
+// Results returns the results being reviewed. They must not be modified.
+func (s *Session) Results() review.Results {
+	return s.results
+}
+
+// Pair returns the current pair.
+func (s *Session) Pair() review.Pair {
+	return s.results.ImagePairs[s.results.StartIdx]
+}
+
+// Index returns the index of the current pair in the results.
+func (s *Session) Index() int {
+	return s.results.StartIdx
+}
+
+// Position returns the position of the current pair in the review queue.
+func (s *Session) Position() int {
+	return review.Position(s.queue, s.results.StartIdx)
+}
+
+// Len returns the number of pairs in the review queue.
+func (s *Session) Len() int {
+	return len(s.queue)
+}
+
+// AtStart reports whether the current pair is the first in the queue.
+func (s *Session) AtStart() bool {
+	return s.Position() <= 0
+}
+
+// AtEnd reports whether the current pair is the last in the queue.
+func (s *Session) AtEnd() bool {
+	return s.Position() >= len(s.queue)-1
+}
+
+// Progress counts the decisions made on the pairs in the queue.
+func (s *Session) Progress() review.Progress {
+	return s.results.Progress(s.queue)
+}
+
+// Filter returns the filter the queue was built with.
+func (s *Session) Filter() review.Filter {
+	return s.results.Filter
+}
+
+// Next moves to the next pair in the queue. It returns false without moving
+// if the current pair is the last one.
+func (s *Session) Next() (bool, error) {
+	return s.Seek(s.Position() + 1)
+}
+
+// Previous moves to the previous pair in the queue. It returns false without
+// moving if the current pair is the first one.
+func (s *Session) Previous() (bool, error) {
+	return s.Seek(s.Position() - 1)
+}
+
+// Seek moves to the pair at pos in the queue. It returns false without moving
+// if there is no such pair.
+func (s *Session) Seek(pos int) (bool, error) {
+	if pos < 0 || pos >= len(s.queue) {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results.StartIdx = s.queue[pos]
+	s.prefetch()
+	return true, s.save()
+}
+
+// SeekIndex moves to the pair at index i in the results, for reviewers which
+// keep their own list of pairs. The pair need not be in the queue. It returns
+// false without moving if there is no such pair.
+func (s *Session) SeekIndex(i int) (bool, error) {
+	if i < 0 || i >= len(s.results.ImagePairs) {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results.StartIdx = i
+	s.prefetch()
+	return true, s.save()
+}
+
+// SetReviewer changes the name recorded with later decisions.
+func (s *Session) SetReviewer(reviewer string) {
+	s.reviewer = reviewer
+}
+
+// Decide records state for the current pair and moves on to the next one,
+// unless the pair is being unmarked or is the last in the queue.
+func (s *Session) Decide(state review.State) error {
+	return s.record(func(p *review.Pair) { p.SetReview(state, s.reviewer) }, state != review.Unreviewed)
+}
+
+// Keep marks the current pair as a duplicate which keeps the given image and
+// moves on to the next one, unless the pair is the last in the queue.
+func (s *Session) Keep(keep review.Keep) error {
+	return s.record(func(p *review.Pair) { p.SetKeep(keep, s.reviewer) }, true)
+}
+
+// DecideAt records state for the pair at index i in the results without
+// moving, for reviewers which keep their own position.
+func (s *Session) DecideAt(i int, state review.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.change(i, func(p *review.Pair) { p.SetReview(state, s.reviewer) })
+	return s.save()
+}
+
+// KeepAt marks the pair at index i in the results as a duplicate which keeps
+// the given image, without moving.
+func (s *Session) KeepAt(i int, keep review.Keep) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.change(i, func(p *review.Pair) { p.SetKeep(keep, s.reviewer) })
+	return s.save()
+}
+
+// record applies a decision to the current pair so that it can be undone, and
+// moves on to the next pair if advance is set.
+func (s *Session) record(decide func(*review.Pair), advance bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.change(s.results.StartIdx, decide)
+	if advance && !s.AtEnd() {
+		s.results.StartIdx = s.queue[s.Position()+1]
+		s.prefetch()
+	}
+	return s.save()
+}
+
+// change applies a decision to the pair at index i so that it can be undone.
+func (s *Session) change(i int, decide func(*review.Pair)) {
+	s.history = append(s.history, undo{i, s.results.ImagePairs[i], true})
+	decide(&s.results.ImagePairs[i])
+}
+
+// Unmark clears the decision for the current pair.
+func (s *Session) Unmark() error {
+	return s.Decide(review.Unreviewed)
+}
+
+// Undo restores the pair changed by the most recent decision and returns to
+// it. It returns false if there is nothing to undo.
+func (s *Session) Undo() (bool, error) {
+	if len(s.history) == 0 {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := s.history[len(s.history)-1]
+	s.history = s.history[:len(s.history)-1]
+	s.results.ImagePairs[last.index] = last.pair
+	if review.Position(s.queue, last.index) >= 0 {
+		s.results.StartIdx = last.index
+	}
+	// The pair returned to may be far from the images loaded
+	s.reload()
+	return true, s.save()
+}
+
+// SetFilter rebuilds the review queue with a new filter. The current pair
+// stays selected.
+func (s *Session) SetFilter(f review.Filter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setFilter(f)
+	return s.save()
+}
+
+// PreviewFilter rebuilds the review queue like SetFilter without saving the
+// filter, for controls which change it many times in a row. The filter is
+// saved by the next call to Save or to a method which records a decision.
+func (s *Session) PreviewFilter(f review.Filter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setFilter(f)
+}
+
+func (s *Session) setFilter(f review.Filter) {
+	s.results.Filter = f
+	s.queue = s.results.Queue()
+	s.reload()
+}
+
+// Save writes the results. Unlike the other methods, it may be called from
+// any goroutine.
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// Cluster returns every image connected to the current pair.
+func (s *Session) Cluster() review.Cluster {
+	return s.results.Cluster(s.results.StartIdx)
+}
+
+// ResolveCluster records a decision on every pair in the current pair's
+// cluster. See review.Results.ResolveCluster. Each changed pair can be undone
+// separately.
+func (s *Session) ResolveCluster(keeper string, duplicates []string) error {
+	c := s.Cluster()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := make(map[int]review.Pair)
+	for _, i := range c.Pairs {
+		before[i] = s.results.ImagePairs[i]
+	}
+	for _, i := range s.results.ResolveCluster(c, keeper, duplicates, s.reviewer) {
+		s.history = append(s.history, undo{i, before[i], false})
+	}
+	return s.save()
+}
+
+// LastDecided returns the index of the pair given the most recent decision
+// which has not been undone, or false if there is none. Pairs changed by
+// ApplyDecision or ResolveCluster are passed over, so that the decision they
+// copied can be applied again without drifting to pairs the reviewer never
+// looked at.
+func (s *Session) LastDecided() (int, bool) {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i].direct {
+			return s.history[i].index, true
+		}
+	}
+	return 0, false
+}
+
+// Similar returns the unreviewed pairs which are likely to deserve the same
+// decision as the pair at index. See review.Results.Similar.
+func (s *Session) Similar(index int, window time.Duration, taken review.TimeFunc) []int {
+	return s.results.Similar(index, window, taken)
+}
+
+// ApplyDecision copies the decision made on the pair at index to each of the
+// pairs in indices. A pair whose images are in the opposite directories keeps
+// the image from the same directory as the decided pair does. Each changed
+// pair can be undone separately.
+func (s *Session) ApplyDecision(index int, indices []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	decided := s.results.ImagePairs[index]
+	refDir, dupeDir := filepath.Dir(decided.RefImage), filepath.Dir(decided.DupeImage)
+	for _, i := range indices {
+		p := &s.results.ImagePairs[i]
+		s.history = append(s.history, undo{i, *p, false})
+		if decided.Review != review.Duplicate {
+			p.SetReview(decided.Review, s.reviewer)
+			continue
+		}
+		keep := decided.Keep
+		if refDir != dupeDir && filepath.Dir(p.RefImage) == dupeDir && filepath.Dir(p.DupeImage) == refDir {
+			switch keep {
+			case review.KeepReference:
+				keep = review.KeepDuplicate
+			case review.KeepDuplicate:
+				keep = review.KeepReference
+			}
+		}
+		p.SetKeep(keep, s.reviewer)
+	}
+	return s.save()
+}
+
+// Images returns the images of the current pair, waiting for them to load if
+// they have not been prefetched yet.
+func (s *Session) Images() Images {
+	return s.get(s.images)
+}
+
+// FullImages returns the images of the current pair decoded with the loader
+// given to SetFullLoader, waiting for them to load if necessary. From the
+// first call until DropFullImages, the full resolution images of the pairs
+// around the current one are prefetched as well, within the limits set by
+// SetPrefetch.
+func (s *Session) FullImages() Images {
+	if s.full == nil {
+		s.full = s.newImageList(s.fullLoad)
+		s.prefetch()
+	}
+	return s.get(s.full)
+}
+
+// DropFullImages stops prefetching full resolution images and releases the
+// ones loaded.
+func (s *Session) DropFullImages() {
+	s.full = nil
+}
+
+// get returns the images of the current pair from list.
+func (s *Session) get(list *imagelist.ImageList) Images {
+	var images Images
+	if list == nil {
+		return images
+	}
+	pos := s.Position()
+	images.Ref, _, images.RefErr = list.Get(2 * pos)
+	images.Dupe, _, images.DupeErr = list.Get(2*pos + 1)
+	return images
+}
+
+// paths lists the reference and duplicate image paths of the pairs in the
+// queue.
+func (s *Session) paths() []string {
+	paths := make([]string, 0, 2*len(s.queue))
+	for _, i := range s.queue {
+		p := s.results.ImagePairs[i]
+		paths = append(paths, p.RefImage, p.DupeImage)
+	}
+	return paths
+}
+
+// prefetch starts loading the images of the pairs around the current one.
+func (s *Session) prefetch() {
+	for _, list := range []*imagelist.ImageList{s.images, s.full} {
+		if list != nil {
+			list.Seek(2 * s.Position())
+		}
+	}
+}
+
+// reload updates the image list after the queue or the images of a pair
+// change. Images which are still needed are kept.
+func (s *Session) reload() {
+	for _, list := range []*imagelist.ImageList{s.images, s.full} {
+		if list != nil {
+			list.SetPaths(s.paths(), 2*s.Position())
+		}
+	}
+}
+
+// save writes the results. mu must be held.
+func (s *Session) save() error {
+	return s.store.Save(s.results)
+}
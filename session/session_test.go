@@ -0,0 +1,419 @@
+package session
+
+import (
+	"errors"
+	"image"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mike-lloyd03/dedugo/review"
+)
+
+// memoryStore keeps results in memory and counts how often they are saved.
+type memoryStore struct {
+	results review.Results
+	saves   int
+	err     error
+}
+
+func (m *memoryStore) Load() (review.Results, error) {
+	return m.results, nil
+}
+
+func (m *memoryStore) Save(r review.Results) error {
+	m.saves++
+	m.results = r
+	return m.err
+}
+
+// fakeLoader returns a 1x1 image for every path except those named
+// missing.jpg and records which paths were loaded.
+type fakeLoader struct {
+	mu     sync.Mutex
+	loaded map[string]int
+}
+
+func (f *fakeLoader) load(path string) (image.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loaded == nil {
+		f.loaded = make(map[string]int)
+	}
+	f.loaded[path]++
+	if filepath.Base(path) == "missing.jpg" {
+		return nil, errors.New("image could not be opened")
+	}
+	return image.NewGray(image.Rect(0, 0, 1, 1)), nil
+}
+
+func testResults() review.Results {
+	return review.Results{ImagePairs: []review.Pair{
+		{RefImage: "a.jpg", DupeImage: "a_copy.jpg", Confidence: 5, Review: review.Unreviewed},
+		{RefImage: "b.jpg", DupeImage: "b_copy.jpg", Confidence: 2, Review: review.Unreviewed},
+		{RefImage: "c.jpg", DupeImage: "missing.jpg", Confidence: 4, Review: review.Unreviewed},
+	}}
+}
+
+func newTestSession(t *testing.T, results review.Results) (*Session, *memoryStore, *fakeLoader) {
+	t.Helper()
+	store := &memoryStore{results: results}
+	loader := &fakeLoader{}
+	s, err := New(store, loader.load, "obi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, store, loader
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&memoryStore{}, (&fakeLoader{}).load, "obi"); err != ErrNoPairs {
+		t.Error("expected ErrNoPairs for empty results. got", err)
+	}
+
+	// A start index past the end is clamped to the last pair
+	r := testResults()
+	r.StartIdx = 10
+	s, _, _ := newTestSession(t, r)
+	if s.Index() != 2 || !s.AtEnd() {
+		t.Error("start index should be clamped to the last pair. got", s.Index())
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	if !s.AtStart() || s.AtEnd() || s.Len() != 3 {
+		t.Fatal("session should start at the first of 3 pairs")
+	}
+	if moved, _ := s.Previous(); moved {
+		t.Error("moving before the first pair should fail")
+	}
+
+	for i := 1; i < 3; i++ {
+		if moved, err := s.Next(); !moved || err != nil {
+			t.Fatal("moving to the next pair failed", err)
+		}
+	}
+	if s.Index() != 2 || !s.AtEnd() {
+		t.Error("expected to be at the last pair. got", s.Index())
+	}
+	// Moving past the end must not change the position
+	if moved, _ := s.Next(); moved || s.Index() != 2 {
+		t.Error("moving past the last pair should fail")
+	}
+	if store.results.StartIdx != 2 {
+		t.Error("the position should be saved. got", store.results.StartIdx)
+	}
+
+	if moved, _ := s.Seek(0); !moved || s.Index() != 0 {
+		t.Error("seeking to the first pair failed")
+	}
+	if moved, _ := s.Seek(3); moved {
+		t.Error("seeking past the end should fail")
+	}
+}
+
+func TestDecide(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	if err := s.Decide(review.Duplicate); err != nil {
+		t.Fatal(err)
+	}
+	p := store.results.ImagePairs[0]
+	if p.Review != review.Duplicate || p.Reviewer != "obi" {
+		t.Errorf("decision should be saved with the reviewer: %+v", p)
+	}
+	if s.Index() != 1 {
+		t.Error("a decision should move to the next pair. got", s.Index())
+	}
+
+	s.Seek(2)
+	s.Decide(review.NotDuplicate)
+	if s.Index() != 2 {
+		t.Error("a decision on the last pair should not move")
+	}
+	s.Unmark()
+	if s.Pair().Review != review.Unreviewed || s.Index() != 2 {
+		t.Error("unmarking should clear the decision without moving")
+	}
+	if p := s.Progress(); p.Reviewed != 1 || p.Duplicates != 1 || p.Total != 3 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+}
+
+func TestKeep(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	s.Keep(review.KeepDuplicate)
+	p := store.results.ImagePairs[0]
+	if p.Review != review.Duplicate || p.Keep != review.KeepDuplicate || s.Index() != 1 {
+		t.Errorf("keeping the duplicate should mark the pair and move on: %+v", p)
+	}
+	s.Undo()
+	if p := s.Pair(); p.Review != review.Unreviewed || p.Keep != review.KeepReference {
+		t.Errorf("undo should restore the pair: %+v", p)
+	}
+}
+
+func TestDecideAt(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	s.SetReviewer("jango")
+	s.KeepAt(2, review.KeepBoth)
+	s.DecideAt(1, review.NotDuplicate)
+	if p := store.results.ImagePairs[2]; p.Review != review.Duplicate || p.Keep != review.KeepBoth || p.Reviewer != "jango" {
+		t.Errorf("the pair should be kept by the given reviewer: %+v", p)
+	}
+	if store.results.ImagePairs[1].Review != review.NotDuplicate || s.Index() != 0 {
+		t.Error("deciding a pair by index should not move")
+	}
+	if moved, _ := s.SeekIndex(2); !moved || s.Index() != 2 || store.results.StartIdx != 2 {
+		t.Error("seeking an index should move to and save it")
+	}
+	if moved, _ := s.SeekIndex(3); moved {
+		t.Error("seeking past the last pair should not move")
+	}
+	s.Undo()
+	if s.Pair().Review != review.Unreviewed || s.Index() != 1 {
+		t.Error("decisions by index should be undone like any other")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	s, _, _ := newTestSession(t, testResults())
+	if undone, _ := s.Undo(); undone {
+		t.Error("there should be nothing to undo")
+	}
+	s.Decide(review.Duplicate)
+	s.Decide(review.Skipped)
+	if undone, _ := s.Undo(); !undone || s.Index() != 1 || s.Pair().Review != review.Unreviewed {
+		t.Error("undo should return to and restore the last pair decided")
+	}
+	s.Undo()
+	if s.Index() != 0 || s.Pair().Review != review.Unreviewed {
+		t.Error("undo should restore the first pair decided")
+	}
+}
+
+func TestSetFilter(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	s.SetFilter(review.Filter{Sort: review.SortConfidence, MinConfidence: 3})
+	// The current pair always stays in the queue
+	if s.Len() != 2 || s.Position() != 0 {
+		t.Errorf("expected 2 pairs with the current one first. got %d at %d", s.Len(), s.Position())
+	}
+	s.Next()
+	if s.Index() != 2 {
+		t.Error("the next pair should be the next most confident. got", s.Index())
+	}
+	if store.results.Filter.MinConfidence != 3 {
+		t.Error("the filter should be saved")
+	}
+
+	// Pairs decided during the session stay in the queue
+	s.SetFilter(review.Filter{States: []review.State{review.Unreviewed}})
+	s.Seek(0)
+	s.Decide(review.Duplicate)
+	s.Previous()
+	if s.Index() != 0 {
+		t.Error("a decided pair should still be reachable. got", s.Index())
+	}
+
+	s.PreviewFilter(review.Filter{MinConfidence: 5})
+	if s.Filter().MinConfidence != 5 || store.results.Filter.MinConfidence == 5 {
+		t.Error("a previewed filter should apply without being saved")
+	}
+	s.Save()
+	if store.results.Filter.MinConfidence != 5 {
+		t.Error("Save should write the previewed filter")
+	}
+}
+
+func TestImages(t *testing.T) {
+	s, _, loader := newTestSession(t, testResults())
+	images := s.Images()
+	if images.Ref == nil || images.Dupe == nil || images.RefErr != nil {
+		t.Errorf("expected both images to load: %+v", images)
+	}
+
+	s.Seek(2)
+	images = s.Images()
+	if images.DupeErr == nil || images.Ref == nil {
+		t.Errorf("a missing image should be reported without failing the other: %+v", images)
+	}
+
+	// Moving back and forth within the prefetch window should not reload
+	s.Previous()
+	s.Images()
+	s.Next()
+	s.Images()
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	if loader.loaded["c.jpg"] != 1 || loader.loaded["b.jpg"] != 1 {
+		t.Error("prefetched images should only be loaded once. got", loader.loaded)
+	}
+}
+
+func TestFullImages(t *testing.T) {
+	s, _, display := newTestSession(t, testResults())
+	full := &fakeLoader{}
+	s.SetFullLoader(full.load)
+	if images := s.FullImages(); images.Ref == nil || images.Dupe == nil {
+		t.Errorf("expected both images to load at full resolution: %+v", images)
+	}
+	s.Next()
+	s.FullImages()
+	s.DropFullImages()
+	s.Next()
+	s.Images()
+
+	full.mu.Lock()
+	defer full.mu.Unlock()
+	if full.loaded["b.jpg"] != 1 {
+		t.Error("the next pair should be prefetched at full resolution. got", full.loaded)
+	}
+	if s.full != nil {
+		t.Error("full resolution images should not be prefetched once dropped")
+	}
+	display.mu.Lock()
+	defer display.mu.Unlock()
+	if display.loaded["c.jpg"] != 1 {
+		t.Error("display images should still load. got", display.loaded)
+	}
+}
+
+func TestSetPrefetch(t *testing.T) {
+	s, _, loader := newTestSession(t, testResults())
+	s.SetPrefetch(0, 0)
+	for _, pos := range []int{1, 0, 1} {
+		s.Seek(pos)
+		if images := s.Images(); images.Ref == nil || images.Dupe == nil {
+			t.Errorf("pair %d: expected both images to load without prefetching: %+v", pos, images)
+		}
+	}
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	if loader.loaded["b.jpg"] < 2 {
+		t.Error("images should be dropped as soon as their pair is left. got", loader.loaded)
+	}
+}
+
+func TestResolveCluster(t *testing.T) {
+	r := testResults()
+	r.ImagePairs = append(r.ImagePairs, review.Pair{RefImage: "a_small.jpg", DupeImage: "a.jpg", Review: review.Unreviewed})
+	s, store, _ := newTestSession(t, r)
+	if c := s.Cluster(); len(c.Images) != 3 {
+		t.Fatal("expected 3 images in the cluster. got", c.Images)
+	}
+	if err := s.ResolveCluster("a.jpg", []string{"a_copy.jpg", "a_small.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	kept := store.results.ImagePairs[3]
+	if kept.Removed() != "a_small.jpg" || kept.Review != review.Duplicate || kept.Keep != review.KeepDuplicate {
+		t.Errorf("the pair should keep a.jpg and remove a_small.jpg: %+v", kept)
+	}
+
+	s.Undo()
+	s.Undo()
+	if p := s.Results().ImagePairs[3]; p.Keep != review.KeepReference || p.Review != review.Unreviewed {
+		t.Errorf("undo should restore the original pair: %+v", p)
+	}
+}
+
+func TestApplyDecision(t *testing.T) {
+	r := review.Results{ImagePairs: []review.Pair{
+		{RefImage: "trip/1.jpg", DupeImage: "phone/1.jpg", Review: review.Unreviewed},
+		{RefImage: "trip/2.jpg", DupeImage: "phone/2.jpg", Review: review.Unreviewed},
+		{RefImage: "phone/3.jpg", DupeImage: "trip/3.jpg", Review: review.Unreviewed},
+	}}
+	s, store, _ := newTestSession(t, r)
+	if _, ok := s.LastDecided(); ok {
+		t.Error("no pair has been decided yet")
+	}
+	if err := s.Keep(review.KeepDuplicate); err != nil {
+		t.Fatal(err)
+	}
+	decided, ok := s.LastDecided()
+	if !ok || decided != 0 {
+		t.Fatal("expected the first pair to be the last decided. got", decided)
+	}
+	if err := s.ApplyDecision(decided, []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each pair should keep the image from the phone directory
+	for i, p := range store.results.ImagePairs {
+		if p.Review != review.Duplicate || p.Reviewer != "obi" || filepath.Dir(p.Kept()) != "phone" {
+			t.Errorf("pair %d should keep its phone image: %+v", i, p)
+		}
+	}
+
+	if i, _ := s.LastDecided(); i != 0 {
+		t.Error("pairs the decision was applied to should not become the last decided. got", i)
+	}
+
+	s.Undo()
+	if p := s.Results().ImagePairs[2]; p.Review != review.Unreviewed {
+		t.Errorf("undo should restore the last pair changed: %+v", p)
+	}
+	s.Seek(1)
+	s.DecideAt(2, review.NotDuplicate)
+	if i, _ := s.LastDecided(); i != 2 {
+		t.Error("expected the pair decided by index to be the last decided. got", i)
+	}
+	s.Undo()
+	s.Undo()
+	if i, _ := s.LastDecided(); i != 0 {
+		t.Error("expected the first pair to be the last decided after undoing. got", i)
+	}
+}
+
+// TestSaveConcurrently saves from another goroutine while decisions are made.
+// Run it with -race.
+func TestSaveConcurrently(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.Save()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		s.Decide(review.Duplicate)
+		s.PreviewFilter(review.Filter{MinConfidence: i % 3})
+		s.Undo()
+		s.Keep(review.KeepBoth)
+		s.Seek(0)
+	}
+	<-done
+	s.Save()
+	if p := store.results.ImagePairs[0]; p.Review != review.Duplicate || p.Keep != review.KeepBoth {
+		t.Errorf("the last decision should be saved: %+v", p)
+	}
+}
+
+func TestSaveError(t *testing.T) {
+	s, store, _ := newTestSession(t, testResults())
+	store.err = errors.New("disk full")
+	if err := s.Decide(review.Duplicate); err == nil {
+		t.Error("save errors should be returned")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.yaml")
+	store := FileStore(path)
+	if err := store.Save(testResults()); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(store, (&fakeLoader{}).load, "obi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Decide(review.Duplicate)
+	r, err := review.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StartIdx != 1 || r.ImagePairs[0].Review != review.Duplicate {
+		t.Errorf("decisions should be written to the results file: %+v", r)
+	}
+}
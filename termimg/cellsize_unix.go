@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package termimg
+
+import "golang.org/x/sys/unix"
+
+// CellSize returns the width and height of a terminal cell in pixels. If the
+// terminal does not report its pixel size, a typical cell size is returned.
+func CellSize(fd int) (int, int) {
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil || ws.Xpixel == 0 || ws.Ypixel == 0 || ws.Col == 0 || ws.Row == 0 {
+		return defaultCellWidth, defaultCellHeight
+	}
+	return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row)
+}
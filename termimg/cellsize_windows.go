@@ -0,0 +1,7 @@
+package termimg
+
+// CellSize returns a typical terminal cell size in pixels since the console
+// does not report it.
+func CellSize(fd int) (int, int) {
+	return defaultCellWidth, defaultCellHeight
+}
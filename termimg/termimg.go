@@ -0,0 +1,257 @@
+package termimg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Protocol is a method of displaying images in a terminal.
+type Protocol int
+
+const (
+	// Blocks draws images with colored half block characters and works in
+	// any terminal with 24-bit color.
+	Blocks Protocol = iota
+	Kitty
+	ITerm
+	Sixel
+)
+
+const (
+	kittyChunkSize    = 4096
+	defaultCellWidth  = 10
+	defaultCellHeight = 20
+)
+
+// ParseProtocol returns the protocol with the given name. "auto" detects the
+// protocol from the environment.
+func ParseProtocol(name string) (Protocol, error) {
+	switch strings.ToLower(name) {
+	case "auto", "":
+		return Detect(), nil
+	case "blocks":
+		return Blocks, nil
+	case "kitty":
+		return Kitty, nil
+	case "iterm":
+		return ITerm, nil
+	case "sixel":
+		return Sixel, nil
+	}
+	return Blocks, fmt.Errorf("unknown graphics protocol: %s", name)
+}
+
+// Detect guesses the best supported protocol from environment variables set by
+// common terminals. Sixel support cannot be detected reliably this way so it
+// must be requested explicitly.
+func Detect() Protocol {
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty":
+		return Kitty
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return ITerm
+	}
+	return Blocks
+}
+
+// Fit returns the size of an image with bounds r scaled to fit within w by h
+// while keeping its aspect ratio.
+func Fit(r image.Rectangle, w, h int) (int, int) {
+	if r.Dx() == 0 || r.Dy() == 0 {
+		return 0, 0
+	}
+	scale := float64(w) / float64(r.Dx())
+	if s := float64(h) / float64(r.Dy()); s < scale {
+		scale = s
+	}
+	fw, fh := int(float64(r.Dx())*scale), int(float64(r.Dy())*scale)
+	if fw < 1 {
+		fw = 1
+	}
+	if fh < 1 {
+		fh = 1
+	}
+	return fw, fh
+}
+
+// BlockLines renders the image to fit within cols by rows terminal cells using
+// upper half block characters, where each cell shows two vertically stacked
+// pixels. Every returned line is padded with spaces to exactly cols cells.
+func BlockLines(img image.Image, cols, rows int) []string {
+	w, h := Fit(img.Bounds(), cols, rows*2)
+	scaled := scale(img, w, h)
+
+	lines := make([]string, rows)
+	for row := 0; row < rows; row++ {
+		var sb strings.Builder
+		y := row * 2
+		for x := 0; x < w && y < h; x++ {
+			top := color.RGBAModel.Convert(scaled.At(x, y)).(color.RGBA)
+			if y+1 < h {
+				bottom := color.RGBAModel.Convert(scaled.At(x, y+1)).(color.RGBA)
+				fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			} else {
+				fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm\x1b[49m▀", top.R, top.G, top.B)
+			}
+		}
+		drawn := w
+		if y >= h {
+			drawn = 0
+		}
+		sb.WriteString("\x1b[0m")
+		sb.WriteString(strings.Repeat(" ", cols-drawn))
+		lines[row] = sb.String()
+	}
+	return lines
+}
+
+// Encode returns the escape sequence which displays the image to fit within
+// cols by rows cells using a graphics protocol. cellWidth and cellHeight are
+// the size of a terminal cell in pixels and are used to scale images for
+// protocols which draw at native resolution.
+func Encode(img image.Image, p Protocol, cols, rows, cellWidth, cellHeight int) (string, error) {
+	switch p {
+	case Kitty:
+		data, err := encodePNG(img, cols*cellWidth, rows*cellHeight)
+		if err != nil {
+			return "", err
+		}
+		return kittySequence(data, cols, rows), nil
+	case ITerm:
+		data, err := encodePNG(img, cols*cellWidth, rows*cellHeight)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a",
+			len(data), cols, rows, base64.StdEncoding.EncodeToString(data)), nil
+	case Sixel:
+		w, h := Fit(img.Bounds(), cols*cellWidth, rows*cellHeight)
+		return SixelSequence(scale(img, w, h)), nil
+	}
+	return strings.Join(BlockLines(img, cols, rows), "\n"), nil
+}
+
+// ClearKitty returns the escape sequence which deletes every image displayed
+// with the kitty protocol.
+func ClearKitty() string {
+	return "\x1b_Ga=d\x1b\\"
+}
+
+// kittySequence transmits PNG data in chunks and displays it over cols by rows
+// cells without moving the cursor.
+func kittySequence(data []byte, cols, rows int) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var sb strings.Builder
+	for i := 0; i < len(encoded); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(encoded) {
+			end = len(encoded)
+			more = 0
+		}
+		if i == 0 {
+			fmt.Fprintf(&sb, "\x1b_Ga=T,f=100,c=%d,r=%d,C=1,q=2,m=%d;%s\x1b\\", cols, rows, more, encoded[i:end])
+		} else {
+			fmt.Fprintf(&sb, "\x1b_Gm=%d;%s\x1b\\", more, encoded[i:end])
+		}
+	}
+	return sb.String()
+}
+
+// SixelSequence encodes the image as sixel graphics using a 216 color palette.
+func SixelSequence(img image.Image) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	indexes := make([]int, w*h)
+	used := make(map[int]bool)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+			i := int(c.R)*5/255*36 + int(c.G)*5/255*6 + int(c.B)*5/255
+			indexes[y*w+x] = i
+			used[i] = true
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\x1bPq\"1;1;%d;%d", w, h)
+	for i := 0; i < 216; i++ {
+		if used[i] {
+			fmt.Fprintf(&sb, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+		}
+	}
+
+	for y0 := 0; y0 < h; y0 += 6 {
+		bandColors := make([]int, 0)
+		seen := make(map[int]bool)
+		for y := y0; y < y0+6 && y < h; y++ {
+			for x := 0; x < w; x++ {
+				if i := indexes[y*w+x]; !seen[i] {
+					seen[i] = true
+					bandColors = append(bandColors, i)
+				}
+			}
+		}
+		for n, c := range bandColors {
+			fmt.Fprintf(&sb, "#%d", c)
+			row := make([]byte, w)
+			for x := 0; x < w; x++ {
+				bits := 0
+				for k := 0; k < 6 && y0+k < h; k++ {
+					if indexes[(y0+k)*w+x] == c {
+						bits |= 1 << k
+					}
+				}
+				row[x] = byte(63 + bits)
+			}
+			writeRuns(&sb, row)
+			if n < len(bandColors)-1 {
+				sb.WriteByte('$')
+			}
+		}
+		sb.WriteByte('-')
+	}
+	sb.WriteString("\x1b\\")
+	return sb.String()
+}
+
+// writeRuns writes sixel characters using run length encoding for repeats.
+func writeRuns(sb *strings.Builder, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(sb, "!%d%c", n, row[i])
+		} else {
+			sb.Write(row[i:j])
+		}
+		i = j
+	}
+}
+
+func encodePNG(img image.Image, maxWidth, maxHeight int) ([]byte, error) {
+	w, h := Fit(img.Bounds(), maxWidth, maxHeight)
+	if w < img.Bounds().Dx() {
+		img = scale(img, w, h)
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func scale(img image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
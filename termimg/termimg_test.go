@@ -0,0 +1,90 @@
+package termimg
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestFit(t *testing.T) {
+	w, h := Fit(image.Rect(0, 0, 400, 200), 100, 100)
+	if w != 100 || h != 50 {
+		t.Errorf("expected 100x50. got %dx%d", w, h)
+	}
+	w, h = Fit(image.Rect(0, 0, 200, 400), 100, 100)
+	if w != 50 || h != 100 {
+		t.Errorf("expected 50x100. got %dx%d", w, h)
+	}
+}
+
+func TestBlockLines(t *testing.T) {
+	lines := BlockLines(solid(20, 10, color.RGBA{255, 0, 0, 255}), 10, 5)
+	if len(lines) != 5 {
+		t.Fatal("expected 5 lines. got", len(lines))
+	}
+	// A 2:1 image fits 10 cells wide and 5 pixels tall, which is 3 rows
+	if strings.Count(lines[0], "▀") != 10 {
+		t.Error("first line should be 10 cells wide")
+	}
+	if !strings.Contains(lines[0], "\x1b[38;2;255;0;0m") {
+		t.Error("block should be drawn in red")
+	}
+	if !strings.Contains(lines[2], "\x1b[49m") {
+		t.Error("last row of an odd height image should have a default background")
+	}
+	if lines[4] != "\x1b[0m"+strings.Repeat(" ", 10) {
+		t.Errorf("rows below the image should be padded with spaces. got %q", lines[4])
+	}
+}
+
+func TestSixelSequence(t *testing.T) {
+	seq := SixelSequence(solid(8, 7, color.RGBA{0, 0, 255, 255}))
+	if !strings.HasPrefix(seq, "\x1bPq\"1;1;8;7") || !strings.HasSuffix(seq, "\x1b\\") {
+		t.Error("sixel sequence should start with the raster attributes and end with ST")
+	}
+	// Pure blue is palette index 5
+	if !strings.Contains(seq, "#5;2;0;0;100") {
+		t.Error("palette should define blue")
+	}
+	// First band has all six rows set, the second band only the first row
+	if !strings.Contains(seq, "#5!8~-") || !strings.Contains(seq, "#5!8@-") {
+		t.Errorf("unexpected sixel data %q", seq)
+	}
+}
+
+func TestKittySequence(t *testing.T) {
+	data := make([]byte, kittyChunkSize)
+	seq := kittySequence(data, 20, 10)
+	chunks := strings.Count(seq, "\x1b_G")
+	// 4096 bytes is 5464 base64 characters so it must be split in two
+	if chunks != 2 {
+		t.Fatal("expected 2 chunks. got", chunks)
+	}
+	if !strings.HasPrefix(seq, "\x1b_Ga=T,f=100,c=20,r=10,C=1,q=2,m=1;") {
+		t.Error("first chunk should have the display parameters")
+	}
+	if !strings.Contains(seq, "\x1b_Gm=0;") {
+		t.Error("last chunk should set m=0")
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	p, err := ParseProtocol("sixel")
+	if err != nil || p != Sixel {
+		t.Error("could not parse sixel protocol")
+	}
+	if _, err := ParseProtocol("notAProtocol"); err == nil {
+		t.Error("parsing an unknown protocol should return an error")
+	}
+}
@@ -0,0 +1,264 @@
+package testset
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mike-lloyd03/dedugo/evaluate"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Transform produces a variant of a source image.
+type Transform struct {
+	Name    string
+	Ext     string
+	Quality int
+	Apply   func(image.Image) image.Image
+}
+
+// Transforms lists every available transform in the order they are generated.
+var Transforms = []Transform{
+	{Name: "resize-50", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return Resize(img, 0.5) }},
+	{Name: "resize-25", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return Resize(img, 0.25) }},
+	{Name: "jpeg-q75", Ext: ".jpg", Quality: 75, Apply: identity},
+	{Name: "jpeg-q50", Ext: ".jpg", Quality: 50, Apply: identity},
+	{Name: "jpeg-q20", Ext: ".jpg", Quality: 20, Apply: identity},
+	{Name: "crop-90", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return Crop(img, 0.9) }},
+	{Name: "crop-75", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return Crop(img, 0.75) }},
+	{Name: "rotate-90", Ext: ".jpg", Quality: 90, Apply: Rotate90},
+	{Name: "rotate-180", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return Rotate90(Rotate90(img)) }},
+	{Name: "flip-h", Ext: ".jpg", Quality: 90, Apply: FlipHorizontal},
+	{Name: "flip-v", Ext: ".jpg", Quality: 90, Apply: FlipVertical},
+	{Name: "color-shift", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return ShiftColor(img, 25, 0, -25) }},
+	{Name: "grayscale", Ext: ".jpg", Quality: 90, Apply: Grayscale},
+	{Name: "watermark", Ext: ".jpg", Quality: 90, Apply: func(img image.Image) image.Image { return Watermark(img, "dedugo") }},
+	{Name: "png", Ext: ".png", Apply: identity},
+}
+
+// Lookup returns the transforms with the given names. All transforms are
+// returned if names is empty.
+func Lookup(names []string) ([]Transform, error) {
+	if len(names) == 0 {
+		return Transforms, nil
+	}
+	selected := make([]Transform, 0, len(names))
+	for _, n := range names {
+		found := false
+		for _, t := range Transforms {
+			if t.Name == n {
+				selected = append(selected, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown transform: %s", n)
+		}
+	}
+	return selected, nil
+}
+
+// Generate copies the source file into outDir as name plus its original
+// extension and writes one variant of img for each transform. The returned
+// group lists every written file relative to outDir.
+func Generate(img image.Image, srcPath, outDir, name string, transforms []Transform) (evaluate.Group, error) {
+	group := evaluate.Group{Name: name}
+
+	origName := name + strings.ToLower(filepath.Ext(srcPath))
+	if err := copyFile(srcPath, filepath.Join(outDir, origName)); err != nil {
+		return group, err
+	}
+	group.Images = append(group.Images, evaluate.Variant{Path: origName})
+
+	for _, t := range transforms {
+		variantName := name + "_" + t.Name + t.Ext
+		err := writeImage(t.Apply(img), filepath.Join(outDir, variantName), t.Ext, t.Quality)
+		if err != nil {
+			return group, fmt.Errorf("could not write %s: %s", variantName, err)
+		}
+		group.Images = append(group.Images, evaluate.Variant{Path: variantName, Transform: t.Name})
+	}
+	return group, nil
+}
+
+// Resize scales the image by the given factor.
+func Resize(img image.Image, scale float64) image.Image {
+	b := img.Bounds()
+	w := int(float64(b.Dx())*scale + 0.5)
+	h := int(float64(b.Dy())*scale + 0.5)
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// Crop keeps the centered portion of the image covering the given fraction of
+// its width and height.
+func Crop(img image.Image, fraction float64) image.Image {
+	b := img.Bounds()
+	w := int(float64(b.Dx()) * fraction)
+	h := int(float64(b.Dy()) * fraction)
+	x0 := b.Min.X + (b.Dx()-w)/2
+	y0 := b.Min.Y + (b.Dy()-h)/2
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// Rotate90 rotates the image 90 degrees clockwise.
+func Rotate90(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.Y-1-y, x-b.Min.X, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+// FlipHorizontal mirrors the image left to right.
+func FlipHorizontal(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.X-1-x, y-b.Min.Y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+// FlipVertical mirrors the image top to bottom.
+func FlipVertical(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(x-b.Min.X, b.Max.Y-1-y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+// ShiftColor adds the given offsets to the red, green and blue channels.
+func ShiftColor(img image.Image, r, g, b int) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			dst.SetRGBA(x-bounds.Min.X, y-bounds.Min.Y, color.RGBA{
+				R: clamp(int(c.R) + r),
+				G: clamp(int(c.G) + g),
+				B: clamp(int(c.B) + b),
+				A: c.A,
+			})
+		}
+	}
+	return dst
+}
+
+// Grayscale removes all color from the image.
+func Grayscale(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// Watermark draws semi-transparent text across the lower right of the image.
+// The text is scaled to a quarter of the image width.
+func Watermark(img image.Image, text string) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+
+	face := basicfont.Face7x13
+	textWidth := font.MeasureString(face, text).Ceil()
+	textImg := image.NewRGBA(image.Rect(0, 0, textWidth, face.Height))
+	d := font.Drawer{
+		Dst:  textImg,
+		Src:  image.NewUniform(color.RGBA{255, 255, 255, 160}),
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	d.DrawString(text)
+
+	scale := float64(b.Dx()) / 4 / float64(textWidth)
+	w := int(float64(textWidth) * scale)
+	h := int(float64(face.Height) * scale)
+	margin := b.Dx() / 40
+	target := image.Rect(b.Dx()-w-margin, b.Dy()-h-margin, b.Dx()-margin, b.Dy()-margin)
+	draw.BiLinear.Scale(dst, target, textImg, textImg.Bounds(), draw.Over, nil)
+	return dst
+}
+
+func writeImage(img image.Image, path, ext string, quality int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext {
+	case ".jpg":
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: quality})
+	case ".png":
+		err = png.Encode(file, img)
+	default:
+		err = errors.New("unsupported output format " + ext)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func identity(img image.Image) image.Image {
+	return img
+}
+
+func clamp(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
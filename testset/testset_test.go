@@ -0,0 +1,117 @@
+package testset
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testImage returns a 4x2 image where each pixel has a unique color.
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 60), uint8(y * 120), 10, 255})
+		}
+	}
+	return img
+}
+
+func TestRotateAndFlip(t *testing.T) {
+	img := testImage()
+
+	rotated := Rotate90(img)
+	if rotated.Bounds().Dx() != 2 || rotated.Bounds().Dy() != 4 {
+		t.Fatal("rotated image should be 2x4. is", rotated.Bounds())
+	}
+	// The bottom left pixel moves to the top left when rotating clockwise
+	if rotated.At(0, 0) != img.At(0, 1) {
+		t.Error("image was not rotated clockwise")
+	}
+
+	flipped := FlipHorizontal(img)
+	if flipped.At(0, 0) != img.At(3, 0) {
+		t.Error("image was not flipped horizontally")
+	}
+	flipped = FlipVertical(img)
+	if flipped.At(0, 0) != img.At(0, 1) {
+		t.Error("image was not flipped vertically")
+	}
+}
+
+func TestResizeAndCrop(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 60))
+
+	resized := Resize(img, 0.5)
+	if resized.Bounds().Dx() != 50 || resized.Bounds().Dy() != 30 {
+		t.Error("resized image should be 50x30. is", resized.Bounds())
+	}
+	cropped := Crop(img, 0.5)
+	if cropped.Bounds().Dx() != 50 || cropped.Bounds().Dy() != 30 {
+		t.Error("cropped image should be 50x30. is", cropped.Bounds())
+	}
+}
+
+func TestShiftColor(t *testing.T) {
+	img := testImage()
+	shifted := ShiftColor(img, 200, -10, 0)
+	c := shifted.At(3, 0).(color.RGBA)
+	if c.R != 255 || c.G != 0 || c.B != 10 {
+		t.Error("color was not shifted and clamped correctly", c)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	all, err := Lookup(nil)
+	if err != nil || len(all) != len(Transforms) {
+		t.Error("an empty lookup should return every transform")
+	}
+	selected, err := Lookup([]string{"png", "flip-h"})
+	if err != nil || len(selected) != 2 || selected[0].Name != "png" {
+		t.Error("lookup did not return the named transforms", selected)
+	}
+	if _, err := Lookup([]string{"notATransform"}); err == nil {
+		t.Error("looking up an unknown transform should return an error")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "source.JPG")
+	src, err := os.Create(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 80, 40))
+	if err := jpeg.Encode(src, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+
+	outDir := filepath.Join(dir, "out")
+	os.Mkdir(outDir, 0755)
+	transforms, _ := Lookup([]string{"resize-50", "watermark", "png"})
+	group, err := Generate(img, srcPath, outDir, "source", transforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"source.jpg", "source_resize-50.jpg", "source_watermark.jpg", "source_png.png"}
+	if len(group.Images) != len(expect) {
+		t.Fatalf("expected %d images in group. got %d", len(expect), len(group.Images))
+	}
+	for i, v := range group.Images {
+		if v.Path != expect[i] {
+			t.Errorf("expected %s. got %s", expect[i], v.Path)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, v.Path)); err != nil {
+			t.Errorf("%s was not written", v.Path)
+		}
+	}
+	if group.Images[0].Transform != "" || group.Images[3].Transform != "png" {
+		t.Error("variants were not labeled with their transform")
+	}
+}
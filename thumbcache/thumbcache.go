@@ -0,0 +1,290 @@
+// Package thumbcache decodes images at display resolution and caches the
+// results on disk using the freedesktop.org thumbnail cache layout, so the
+// thumbnails are shared with file managers and image viewers and reviewing a
+// results file again doesn't need to decode every original.
+//
+// See https://specifications.freedesktop.org/thumbnail-spec/ for the layout.
+package thumbcache
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// Size is the largest edge of a thumbnail in pixels. Each size is cached in
+// its own directory.
+type Size int
+
+const (
+	Normal  Size = 128
+	Large   Size = 256
+	XLarge  Size = 512
+	XXLarge Size = 1024
+)
+
+// Sizes lists the cached sizes from smallest to largest.
+var Sizes = []Size{Normal, Large, XLarge, XXLarge}
+
+// dir returns the name of the cache directory for the size.
+func (s Size) dir() string {
+	switch s {
+	case Normal:
+		return "normal"
+	case Large:
+		return "large"
+	case XLarge:
+		return "x-large"
+	case XXLarge:
+		return "xx-large"
+	}
+	return ""
+}
+
+// sizeFor returns the smallest cached size which is at least max pixels, or
+// false if max is larger than every cached size.
+func sizeFor(max int) (Size, bool) {
+	for _, s := range Sizes {
+		if int(s) >= max {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// Cache is a thumbnail cache rooted at Dir. The zero Cache scales images
+// without caching them.
+type Cache struct {
+	Dir string
+}
+
+// Default returns the user's thumbnail cache, $XDG_CACHE_HOME/thumbnails or
+// ~/.cache/thumbnails.
+func Default() (Cache, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return Cache{filepath.Join(dir, "thumbnails")}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Cache{}, err
+	}
+	return Cache{filepath.Join(home, ".cache", "thumbnails")}, nil
+}
+
+// URI returns the file URI the cache identifies the image at path by.
+func URI(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	return u.String(), nil
+}
+
+// Path returns where the thumbnail of the image at path is cached at size.
+func (c Cache) Path(path string, size Size) (string, error) {
+	uri, err := URI(path)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum([]byte(uri))
+	return filepath.Join(c.Dir, size.dir(), hex.EncodeToString(sum[:])+".png"), nil
+}
+
+// Load returns the image at path scaled to fit within max by max pixels.
+// Images which are already that small are returned at full size. A cached
+// thumbnail is used if it is up to date with the image, otherwise one is
+// created. Thumbnails larger than the largest cached size are not cached.
+// Failing to write the cache doesn't stop the image being returned.
+func (c Cache) Load(path string, max int) (image.Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.New("image could not be opened")
+	}
+	size, cached := sizeFor(max)
+	cached = cached && c.Dir != ""
+	if cached {
+		max = int(size)
+		if img, err := c.read(path, size, info); err == nil {
+			return img, nil
+		}
+	}
+
+	img, err := decodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Dx() <= max && b.Dy() <= max {
+		return img, nil
+	}
+	thumb := Scale(img, max)
+	if cached {
+		c.write(path, size, info, thumb, b.Size())
+	}
+	return thumb, nil
+}
+
+// Scale scales img so that its longest edge is max pixels.
+func Scale(img image.Image, max int) *image.RGBA {
+	b := img.Bounds()
+	w, h := max, max
+	if b.Dx() > b.Dy() {
+		h = b.Dy() * max / b.Dx()
+	} else {
+		w = b.Dx() * max / b.Dy()
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// read decodes the cached thumbnail of the image at path if it was created
+// from the current version of the image.
+func (c Cache) read(path string, size Size, info os.FileInfo) (image.Image, error) {
+	thumbPath, err := c.Path(path, size)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(thumbPath)
+	if err != nil {
+		return nil, err
+	}
+	text, err := textChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	uri, _ := URI(path)
+	if text["Thumb::URI"] != uri || text["Thumb::MTime"] != strconv.FormatInt(info.ModTime().Unix(), 10) {
+		return nil, errors.New("thumbnail is out of date")
+	}
+	if s, ok := text["Thumb::Size"]; ok && s != strconv.FormatInt(info.Size(), 10) {
+		return nil, errors.New("thumbnail is out of date")
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// write stores a thumbnail of the image at path in the cache. The file is
+// written under a temporary name and renamed so other programs never see a
+// partial thumbnail.
+func (c Cache) write(path string, size Size, info os.FileInfo, thumb image.Image, original image.Point) error {
+	thumbPath, err := c.Path(path, size)
+	if err != nil {
+		return err
+	}
+	uri, _ := URI(path)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumb); err != nil {
+		return err
+	}
+	data, err := addTextChunks(buf.Bytes(), [][2]string{
+		{"Thumb::URI", uri},
+		{"Thumb::MTime", strconv.FormatInt(info.ModTime().Unix(), 10)},
+		{"Thumb::Size", strconv.FormatInt(info.Size(), 10)},
+		{"Thumb::Image::Width", strconv.Itoa(original.X)},
+		{"Thumb::Image::Height", strconv.Itoa(original.Y)},
+		{"Software", "dedugo"},
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(thumbPath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "dedugo-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), thumbPath)
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("image could not be opened")
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errors.New("image could not be decoded")
+	}
+	return img, nil
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// addTextChunks inserts tEXt chunks with the given keywords and values after
+// the IHDR chunk of an encoded PNG.
+func addTextChunks(data []byte, text [][2]string) ([]byte, error) {
+	// The signature is followed by the 13 byte IHDR chunk with its 4 byte
+	// length, type and CRC
+	ihdrEnd := len(pngSignature) + 12 + 13
+	if len(data) < ihdrEnd || !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+	var out bytes.Buffer
+	out.Write(data[:ihdrEnd])
+	for _, kv := range text {
+		chunk := []byte("tEXt" + kv[0] + "\x00" + kv[1])
+		binary.Write(&out, binary.BigEndian, uint32(len(chunk)-4))
+		out.Write(chunk)
+		binary.Write(&out, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	}
+	out.Write(data[ihdrEnd:])
+	return out.Bytes(), nil
+}
+
+// textChunks returns the keywords and values of the tEXt chunks in an encoded
+// PNG.
+func textChunks(data []byte) (map[string]string, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+	text := make(map[string]string)
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		length := binary.BigEndian.Uint32(rest)
+		if uint64(length)+12 > uint64(len(rest)) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		kind := string(rest[4:8])
+		if kind == "tEXt" {
+			chunk := rest[8 : 8+length]
+			if i := bytes.IndexByte(chunk, 0); i >= 0 {
+				text[string(chunk[:i])] = string(chunk[i+1:])
+			}
+		}
+		if kind == "IEND" {
+			break
+		}
+		rest = rest[12+length:]
+	}
+	return text, nil
+}
@@ -0,0 +1,116 @@
+package thumbcache
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	c := Cache{filepath.Join(dir, "thumbnails")}
+	path := filepath.Join(dir, "big image.png")
+	writePNG(t, path, 2000, 1000)
+
+	img, err := c.Load(path, 800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 800 pixels is served from the 1024 pixel cache
+	if img.Bounds().Dx() != 1024 || img.Bounds().Dy() != 512 {
+		t.Error("expected a 1024x512 thumbnail. got", img.Bounds())
+	}
+	thumbPath, _ := c.Path(path, XXLarge)
+	data, err := ioutil.ReadFile(thumbPath)
+	if err != nil {
+		t.Fatal("the thumbnail should be cached.", err)
+	}
+	text, err := textChunks(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text["Thumb::URI"], "file:///") || !strings.HasSuffix(text["Thumb::URI"], "/big%20image.png") {
+		t.Error("unexpected thumbnail URI", text["Thumb::URI"])
+	}
+	if text["Thumb::Image::Width"] != "2000" || text["Thumb::MTime"] == "" {
+		t.Errorf("unexpected thumbnail attributes %v", text)
+	}
+	if info, _ := os.Stat(thumbPath); info.Mode().Perm() != 0600 {
+		t.Error("thumbnails should only be readable by the user. got", info.Mode())
+	}
+
+	// An up to date thumbnail is used instead of decoding the image
+	info, _ := os.Stat(path)
+	if err := c.write(path, XXLarge, info, image.NewGray(image.Rect(0, 0, 5, 5)), image.Pt(2000, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if img, _ := c.Load(path, 1024); img.Bounds().Dx() != 5 {
+		t.Error("the cached thumbnail should be used. got", img.Bounds())
+	}
+
+	// Changing the image makes the thumbnail out of date
+	later := info.ModTime().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if img, _ := c.Load(path, 1024); img.Bounds().Dx() != 1024 {
+		t.Error("an out of date thumbnail should be replaced. got", img.Bounds())
+	}
+}
+
+func TestLoadUncached(t *testing.T) {
+	dir := t.TempDir()
+	c := Cache{filepath.Join(dir, "thumbnails")}
+	small := filepath.Join(dir, "small.png")
+	writePNG(t, small, 300, 200)
+	big := filepath.Join(dir, "big.png")
+	writePNG(t, big, 3000, 1500)
+
+	if img, _ := c.Load(small, 512); img.Bounds().Dx() != 300 {
+		t.Error("images smaller than the thumbnail should be returned at full size. got", img.Bounds())
+	}
+	if img, _ := c.Load(big, 2000); img.Bounds().Dx() != 2000 || img.Bounds().Dy() != 1000 {
+		t.Error("expected the image scaled to 2000x1000. got", img.Bounds())
+	}
+	if img, _ := (Cache{}).Load(big, 100); img.Bounds().Dx() != 100 {
+		t.Error("the zero cache should scale to exactly the size asked for. got", img.Bounds())
+	}
+	if _, err := os.Stat(c.Dir); !os.IsNotExist(err) {
+		t.Error("nothing should have been cached.", err)
+	}
+
+	if _, err := c.Load(filepath.Join(dir, "missing.png"), 512); err == nil {
+		t.Error("loading a missing image should return an error")
+	}
+	ioutil.WriteFile(filepath.Join(dir, "bad.png"), []byte("not an image"), 0644)
+	if _, err := c.Load(filepath.Join(dir, "bad.png"), 512); err == nil {
+		t.Error("loading a file that is not an image should return an error")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache")
+	if c, _ := Default(); c.Dir != "/tmp/cache/thumbnails" {
+		t.Error("the cache should follow XDG_CACHE_HOME. got", c.Dir)
+	}
+	// The cache file name is the MD5 of the URI, as in the specification
+	c := Cache{"/cache"}
+	if p, _ := c.Path("/home/jens/photos/me.png", Normal); p != "/cache/normal/c6ee772d9e49320e97ec29a7eb5b1697.png" {
+		t.Error("unexpected thumbnail path", p)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package trash
+
+import "golang.org/x/sys/unix"
+
+// deviceOf returns the ID of the device holding path. It can be replaced in
+// tests to simulate other mounts.
+var deviceOf = func(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
@@ -0,0 +1,6 @@
+package trash
+
+// deviceOf returns ErrUnsupported as Windows has no freedesktop.org trash.
+var deviceOf = func(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
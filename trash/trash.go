@@ -0,0 +1,264 @@
+// Package trash moves files to the trash following the freedesktop.org Trash
+// specification, so that a file removed by mistake can be restored from any
+// desktop file manager.
+//
+// Files in the user's home trash are moved to $XDG_DATA_HOME/Trash. Files on
+// other mounts are moved to the trash directory at the top of their mount,
+// $topdir/.Trash/$uid if the administrator has set up a shared .Trash
+// directory, or $topdir/.Trash-$uid otherwise. Files are never copied between
+// devices, so trashing a file whose mount has no usable trash directory fails.
+//
+// See https://specifications.freedesktop.org/trash-spec/ for the layout.
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is returned on systems without freedesktop.org trash
+// directories.
+var ErrUnsupported = errors.New("the trash is not supported on this system")
+
+// infoExt is the extension of the files describing each trashed file.
+const infoExt = ".trashinfo"
+
+// Trash moves files to the user's trash directories.
+type Trash struct {
+	// Home is the home trash directory.
+	Home string
+	// UID is the user's ID, which names their trash directory on other
+	// mounts.
+	UID int
+}
+
+// Default returns the trash of the current user, whose home trash is
+// $XDG_DATA_HOME/Trash or ~/.local/share/Trash.
+func Default() (Trash, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return Trash{filepath.Join(dir, "Trash"), os.Getuid()}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Trash{}, err
+	}
+	return Trash{filepath.Join(home, ".local", "share", "Trash"), os.Getuid()}, nil
+}
+
+// Item is a trashed file.
+type Item struct {
+	// Path is where the file was before it was trashed.
+	Path string
+	// File is where the trashed file is now and Info is the file describing
+	// it.
+	File string
+	Info string
+}
+
+// Put moves the file at path to the trash.
+func (t Trash) Put(path string) (Item, error) {
+	item, err := t.Reserve(path)
+	if err != nil {
+		return Item{}, err
+	}
+	if err := item.Move(); err != nil {
+		item.Release()
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Reserve picks where the file at path will go in the trash and writes its
+// info file, without moving the file. The item must then be moved with Move
+// or given up with Release.
+func (t Trash) Reserve(path string) (Item, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Item{}, err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return Item{}, err
+	}
+	dir, topdir, err := t.dirFor(abs)
+	if err != nil {
+		return Item{}, err
+	}
+	// Files in a mount's trash are recorded relative to the top of the mount
+	// so that they can be restored if it is mounted somewhere else
+	recorded := abs
+	if topdir != "" {
+		if recorded, err = filepath.Rel(topdir, abs); err != nil {
+			return Item{}, err
+		}
+	}
+
+	item := Item{Path: abs}
+	var info *os.File
+	for n := 1; ; n++ {
+		name := trashName(filepath.Base(abs), n)
+		item.File = filepath.Join(dir, "files", name)
+		item.Info = filepath.Join(dir, "info", name+infoExt)
+		// Creating the info file exclusively reserves the name
+		info, err = os.OpenFile(item.Info, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return Item{}, err
+		}
+		if _, err := os.Lstat(item.File); err == nil {
+			// A file was left in the trash without its info file
+			info.Close()
+			os.Remove(item.Info)
+			continue
+		}
+		break
+	}
+
+	_, err = fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", escape(recorded), time.Now().Format("2006-01-02T15:04:05"))
+	if cerr := info.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		item.Release()
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Move moves a reserved item's file into the trash.
+func (i Item) Move() error {
+	return os.Rename(i.Path, i.File)
+}
+
+// Release gives up a reserved item which was not moved by removing its info
+// file.
+func (i Item) Release() error {
+	return os.Remove(i.Info)
+}
+
+// Restore moves a trashed file back to where it was and removes its info
+// file. It fails if another file has since been created at the original path.
+func Restore(item Item) error {
+	if _, err := os.Lstat(item.Path); err == nil {
+		return fmt.Errorf("%s already exists", item.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(item.File, item.Path); err != nil {
+		return err
+	}
+	return os.Remove(item.Info)
+}
+
+// dirFor returns the trash directory for the file at path, creating it if
+// needed, and the top directory of the file's mount if it isn't the home
+// trash.
+func (t Trash) dirFor(path string) (dir, topdir string, err error) {
+	// The file's directory entry is on the same device as its directory,
+	// even if the file is a symlink to another device
+	dev, err := deviceOf(filepath.Dir(path))
+	if err != nil {
+		return "", "", err
+	}
+	if homeDev, err := deviceOf(existingParent(t.Home)); err == nil && homeDev == dev {
+		return t.Home, "", makeTrashDir(t.Home)
+	}
+
+	topdir, err = topDir(path, dev)
+	if err != nil {
+		return "", "", err
+	}
+	uid := strconv.Itoa(t.UID)
+	// The administrator may have created a shared trash directory, which
+	// must be a real directory with the sticky bit set
+	shared := filepath.Join(topdir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dir = filepath.Join(shared, uid)
+		if err := makeTrashDir(dir); err == nil && onDevice(dir, dev) {
+			return dir, topdir, nil
+		}
+	}
+	dir = filepath.Join(topdir, ".Trash-"+uid)
+	if info, err := os.Lstat(dir); err == nil && (!info.IsDir() || info.Mode()&os.ModeSymlink != 0) {
+		return "", "", fmt.Errorf("%s is not a directory", dir)
+	}
+	if err := makeTrashDir(dir); err != nil {
+		return "", "", fmt.Errorf("no trash directory could be created for %s: %w", path, err)
+	}
+	if !onDevice(dir, dev) {
+		return "", "", fmt.Errorf("%s is not on the same device as %s", dir, path)
+	}
+	return dir, topdir, nil
+}
+
+// topDir returns the top directory of the mount holding path, which is on
+// device dev.
+func topDir(path string, dev uint64) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		parentDev, err := deviceOf(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentDev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// existingParent returns path or its closest parent which exists.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func onDevice(path string, dev uint64) bool {
+	d, err := deviceOf(path)
+	return err == nil && d == dev
+}
+
+// makeTrashDir creates the files and info directories of a trash directory.
+func makeTrashDir(dir string) error {
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashName returns the name the nth file with the given name is trashed
+// under, for example "photo.jpg", "photo.2.jpg", "photo.3.jpg".
+func trashName(name string, n int) string {
+	if n == 1 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// escape encodes a path for a trash info file.
+func escape(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	return u.EscapedPath()
+}
@@ -0,0 +1,163 @@
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(path), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeMount makes every path below dir look like it is on another device.
+func fakeMount(t *testing.T, dir string) {
+	real := deviceOf
+	deviceOf = func(path string) (uint64, error) {
+		dev, err := real(path)
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			dev++
+		}
+		return dev, err
+	}
+	t.Cleanup(func() { deviceOf = real })
+}
+
+func readInfo(t *testing.T, item Item) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(item.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPutHome(t *testing.T) {
+	dir := t.TempDir()
+	tr := Trash{Home: filepath.Join(dir, "data", "Trash"), UID: 1000}
+	path := filepath.Join(dir, "my photos", "a.jpg")
+	writeFile(t, path)
+
+	item, err := tr.Put(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.File != filepath.Join(tr.Home, "files", "a.jpg") || item.Info != filepath.Join(tr.Home, "info", "a.jpg.trashinfo") {
+		t.Errorf("expected the file in the home trash. got %+v", item)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the file should have been moved")
+	}
+	info := readInfo(t, item)
+	if !strings.HasPrefix(info, "[Trash Info]\nPath="+filepath.ToSlash(dir)+"/my%20photos/a.jpg\nDeletionDate=") {
+		t.Errorf("unexpected trash info %q", info)
+	}
+
+	// A second file with the same name gets a new name
+	writeFile(t, path)
+	second, err := tr.Put(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(second.File) != "a.2.jpg" || filepath.Base(second.Info) != "a.2.jpg.trashinfo" {
+		t.Errorf("expected the second file to be renamed. got %+v", second)
+	}
+
+	if err := Restore(item); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("the file should have been restored.", err)
+	}
+	if _, err := os.Stat(item.Info); !os.IsNotExist(err) {
+		t.Error("the info file should be removed when a file is restored")
+	}
+	if err := Restore(second); err == nil {
+		t.Error("a restored file should never replace an existing one")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	dir := t.TempDir()
+	tr := Trash{Home: filepath.Join(dir, "Trash"), UID: 1000}
+	path := filepath.Join(dir, "a.jpg")
+	writeFile(t, path)
+
+	item, err := tr.Reserve(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("reserving should not move the file.", err)
+	}
+	if second, err := tr.Reserve(path); err != nil || second.File == item.File {
+		t.Errorf("a reserved name should not be given out twice. got %+v %v", second, err)
+	}
+	if err := item.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(item.Info); !os.IsNotExist(err) {
+		t.Error("releasing should remove the info file")
+	}
+}
+
+func TestPutMount(t *testing.T) {
+	dir := t.TempDir()
+	tr := Trash{Home: filepath.Join(dir, "home", "Trash"), UID: 1000}
+	mount := filepath.Join(dir, "mnt")
+	fakeMount(t, mount)
+
+	path := filepath.Join(mount, "photos", "b.jpg")
+	writeFile(t, path)
+	item, err := tr.Put(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.File != filepath.Join(mount, ".Trash-1000", "files", "b.jpg") {
+		t.Errorf("expected the file in the mount's trash. got %+v", item)
+	}
+	if info := readInfo(t, item); !strings.Contains(info, "\nPath=photos/b.jpg\n") {
+		t.Errorf("the path should be relative to the top of the mount. got %q", info)
+	}
+	if fi, err := os.Stat(filepath.Join(mount, ".Trash-1000")); err != nil || fi.Mode().Perm() != 0700 {
+		t.Error("the mount's trash should only be readable by the user.", err)
+	}
+
+	// A shared .Trash without the sticky bit is not trusted
+	shared := filepath.Join(mount, ".Trash")
+	os.Mkdir(shared, 0777)
+	writeFile(t, path)
+	if item, err := tr.Put(path); err != nil || !strings.HasPrefix(item.File, filepath.Join(mount, ".Trash-1000")) {
+		t.Errorf("expected the user's trash directory to be used. got %+v %v", item, err)
+	}
+
+	os.Chmod(shared, 0777|os.ModeSticky)
+	writeFile(t, path)
+	item, err = tr.Put(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.File != filepath.Join(shared, "1000", "files", "b.jpg") {
+		t.Errorf("expected the shared trash directory to be used. got %+v", item)
+	}
+}
+
+func TestTrashName(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		n    int
+		want string
+	}{{"a.jpg", 1, "a.jpg"}, {"a.jpg", 3, "a.3.jpg"}, {"README", 2, "README.2"}} {
+		if got := trashName(c.name, c.n); got != c.want {
+			t.Errorf("expected %s. got %s", c.want, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package verify
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// heatmapGain amplifies small differences so that recompression artifacts are
+// visible in the heatmap.
+const heatmapGain = 4
+
+// heatmapDim darkens the grayscale background so that differences stand out.
+const heatmapDim = 3
+
+// Align scales img to the dimensions of r so that it can be compared pixel by
+// pixel with an image of that size.
+func Align(img image.Image, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// Heatmap scales both images so that the longest edge of the first is size
+// pixels and the second matches its dimensions, then returns an image of
+// their per-pixel differences. Identical areas show a dimmed grayscale copy
+// of the first image and differences are drawn from red through yellow to
+// white as they grow. A size of 0 keeps the first image at full size.
+func Heatmap(a, b image.Image, size int) *image.RGBA {
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	if size > 0 {
+		w, h = scaledSize(a.Bounds(), size)
+	}
+	r := image.Rect(0, 0, w, h)
+	ra, rb := Align(a, r), Align(b, r)
+
+	out := image.NewRGBA(r)
+	for i := 0; i < len(out.Pix); i += 4 {
+		d := 0
+		for c := 0; c < 3; c++ {
+			if v := absDiff(ra.Pix[i+c], rb.Pix[i+c]); v > d {
+				d = v
+			}
+		}
+		t := math.Min(1, float64(d*heatmapGain)/255)
+		gray := (0.299*float64(ra.Pix[i]) + 0.587*float64(ra.Pix[i+1]) + 0.114*float64(ra.Pix[i+2])) / heatmapDim
+		hot := heat(t)
+		out.Pix[i] = blend(gray, float64(hot.R), t)
+		out.Pix[i+1] = blend(gray, float64(hot.G), t)
+		out.Pix[i+2] = blend(gray, float64(hot.B), t)
+		out.Pix[i+3] = 255
+	}
+	return out
+}
+
+// heat maps t in [0, 1] onto a black, red, yellow, white color scale.
+func heat(t float64) color.RGBA {
+	v := t * 3
+	return color.RGBA{
+		R: uint8(255 * math.Min(1, v)),
+		G: uint8(255 * math.Max(0, math.Min(1, v-1))),
+		B: uint8(255 * math.Max(0, math.Min(1, v-2))),
+		A: 255,
+	}
+}
+
+func blend(from, to, t float64) uint8 {
+	return uint8(math.Round(from*(1-t) + to*t))
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
@@ -0,0 +1,47 @@
+package verify
+
+import (
+	"image"
+	"testing"
+)
+
+func TestHeatmapIdentical(t *testing.T) {
+	img := gradient(64, 48)
+	h := Heatmap(img, img, 0)
+	if h.Bounds().Dx() != 64 || h.Bounds().Dy() != 48 {
+		t.Fatal("heatmap should match the size of the first image. got", h.Bounds())
+	}
+	for i := 0; i < len(h.Pix); i += 4 {
+		if h.Pix[i] != h.Pix[i+1] || h.Pix[i+1] != h.Pix[i+2] {
+			t.Fatal("identical images should produce a grayscale heatmap. got", h.Pix[i:i+4])
+		}
+	}
+}
+
+func TestHeatmapDifference(t *testing.T) {
+	a := gradient(64, 48)
+	b := gradient(64, 48)
+	// Paint a white square into the second image
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 20; x++ {
+			i := b.PixOffset(x, y)
+			b.Pix[i], b.Pix[i+1], b.Pix[i+2] = 255, 255, 255
+		}
+	}
+	h := Heatmap(a, b, 0)
+	changed := h.RGBAAt(15, 15)
+	if changed.R != 255 {
+		t.Error("changed pixels should be highlighted. got", changed)
+	}
+	same := h.RGBAAt(50, 40)
+	if same.R != same.G || same.G != same.B {
+		t.Error("unchanged pixels should be gray. got", same)
+	}
+}
+
+func TestHeatmapAlignsSizes(t *testing.T) {
+	h := Heatmap(gradient(128, 96), gradient(64, 48), 64)
+	if h.Bounds() != image.Rect(0, 0, 64, 48) {
+		t.Error("heatmap should be scaled to the requested size. got", h.Bounds())
+	}
+}
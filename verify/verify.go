@@ -0,0 +1,116 @@
+package verify
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	window = 8
+	c1     = (0.01 * 255) * (0.01 * 255)
+	c2     = (0.03 * 255) * (0.03 * 255)
+
+	// changeThreshold is the per-pixel luminance difference above which a
+	// pixel is counted as changed.
+	changeThreshold = 16
+)
+
+// Scores holds the result of comparing two images pixel by pixel.
+type Scores struct {
+	// SSIM is the mean structural similarity index of the luminance of the
+	// two images. 1 means identical.
+	SSIM float64 `yaml:"SSIM"`
+	// MeanDifference is the mean absolute luminance difference on a scale of
+	// 0-255.
+	MeanDifference float64 `yaml:"MeanDifference"`
+	// ChangedPixels is the fraction of pixels whose luminance differs by more
+	// than a small tolerance.
+	ChangedPixels float64 `yaml:"ChangedPixels"`
+}
+
+// Compare scales both images so that the longest edge of the first is size
+// pixels and the second matches its dimensions, then computes their
+// similarity.
+func Compare(a, b image.Image, size int) Scores {
+	w, h := scaledSize(a.Bounds(), size)
+	la := luminance(a, w, h)
+	lb := luminance(b, w, h)
+
+	var diffSum float64
+	changed := 0
+	for i := range la {
+		d := math.Abs(la[i] - lb[i])
+		diffSum += d
+		if d > changeThreshold {
+			changed++
+		}
+	}
+
+	return Scores{
+		SSIM:           ssim(la, lb, w, h),
+		MeanDifference: diffSum / float64(len(la)),
+		ChangedPixels:  float64(changed) / float64(len(la)),
+	}
+}
+
+// ssim returns the mean structural similarity over non-overlapping windows.
+func ssim(a, b []float64, w, h int) float64 {
+	var total float64
+	count := 0
+	for y0 := 0; y0+window <= h; y0 += window {
+		for x0 := 0; x0+window <= w; x0 += window {
+			var sumA, sumB, sumAA, sumBB, sumAB float64
+			for y := y0; y < y0+window; y++ {
+				for x := x0; x < x0+window; x++ {
+					va, vb := a[y*w+x], b[y*w+x]
+					sumA += va
+					sumB += vb
+					sumAA += va * va
+					sumBB += vb * vb
+					sumAB += va * vb
+				}
+			}
+			n := float64(window * window)
+			meanA, meanB := sumA/n, sumB/n
+			varA := sumAA/n - meanA*meanA
+			varB := sumBB/n - meanB*meanB
+			cov := sumAB/n - meanA*meanB
+			total += ((2*meanA*meanB + c1) * (2*cov + c2)) /
+				((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// luminance scales the image to w by h and returns the luminance of each
+// pixel on a scale of 0-255.
+func luminance(img image.Image, w, h int) []float64 {
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Src, nil)
+	l := make([]float64, w*h)
+	for i, v := range gray.Pix {
+		l[i] = float64(v)
+	}
+	return l
+}
+
+// scaledSize returns the dimensions of r scaled so that its longest edge is
+// size pixels. Images smaller than size are not enlarged.
+func scaledSize(r image.Rectangle, size int) (int, int) {
+	w, h := r.Dx(), r.Dy()
+	long := w
+	if h > long {
+		long = h
+	}
+	if long <= size {
+		return w, h
+	}
+	scale := float64(size) / float64(long)
+	return int(math.Max(1, math.Round(float64(w)*scale))), int(math.Max(1, math.Round(float64(h)*scale)))
+}
@@ -0,0 +1,68 @@
+package verify
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradient returns an image with a horizontal gradient and a vertical stripe
+// pattern so that it has some structure to compare.
+func gradient(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / w)
+			if (y*12/h)%2 == 0 {
+				v /= 2
+			}
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestCompareIdentical(t *testing.T) {
+	img := gradient(64, 48)
+	s := Compare(img, img, 64)
+	if s.SSIM < 0.999 {
+		t.Error("identical images should have an SSIM of 1. got", s.SSIM)
+	}
+	if s.MeanDifference != 0 || s.ChangedPixels != 0 {
+		t.Errorf("identical images should have no difference: %+v", s)
+	}
+}
+
+func TestCompareScaled(t *testing.T) {
+	// A larger copy of the same image should still score highly
+	s := Compare(gradient(64, 48), gradient(128, 96), 64)
+	if s.SSIM < 0.8 {
+		t.Error("a scaled copy should have a high SSIM. got", s.SSIM)
+	}
+}
+
+func TestCompareDifferent(t *testing.T) {
+	a := gradient(64, 48)
+	b := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range b.Pix {
+		b.Pix[i] = 255
+	}
+	s := Compare(a, b, 64)
+	if s.SSIM > 0.5 {
+		t.Error("different images should have a low SSIM. got", s.SSIM)
+	}
+	if s.ChangedPixels < 0.9 {
+		t.Error("most pixels should be counted as changed. got", s.ChangedPixels)
+	}
+}
+
+func TestScaledSize(t *testing.T) {
+	w, h := scaledSize(image.Rect(0, 0, 4000, 3000), 400)
+	if w != 400 || h != 300 {
+		t.Errorf("expected 400x300. got %dx%d", w, h)
+	}
+	w, h = scaledSize(image.Rect(0, 0, 100, 50), 400)
+	if w != 100 || h != 50 {
+		t.Errorf("small images should not be enlarged. got %dx%d", w, h)
+	}
+}